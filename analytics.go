@@ -0,0 +1,450 @@
+package arxiv
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Citation graph analytics.
+//
+// ComputeGraphAnalytics works over the whole citations view: it ranks every
+// paper with PageRank, globally and within each category, and finds each
+// paper's related papers by co-citation (papers often cited together) and
+// bibliographic coupling (papers sharing references). The results are
+// stored, so PageRank and Related are cheap lookups, but they go stale as
+// papers are indexed until the analytics are computed again.
+
+const analyticsStateKey = "graph_analytics"
+
+const (
+	// DefaultDamping is the default PageRank damping factor.
+	DefaultDamping = 0.85
+
+	// DefaultRelated is the default number of related papers stored for
+	// each paper.
+	DefaultRelated = 20
+
+	// DefaultHubLimit is the default AnalyticsOptions.HubLimit.
+	DefaultHubLimit = 1000
+)
+
+// PageRank iterates until the ranks change by less than pageRankTolerance
+// in total, or pageRankIterations times.
+const (
+	pageRankTolerance  = 1e-6
+	pageRankIterations = 100
+)
+
+// AnalyticsOptions configures ComputeGraphAnalytics.
+type AnalyticsOptions struct {
+	// Damping is the PageRank damping factor (default DefaultDamping)
+	Damping float64
+
+	// Related is the number of related papers stored for each paper
+	// (default DefaultRelated)
+	Related int
+
+	// HubLimit bounds the cost of relating papers: references cited by
+	// more papers, and papers with more references, are passed over when
+	// counting co-citations and shared references (default
+	// DefaultHubLimit). Such hubs relate nearly everything to everything.
+	HubLimit int
+
+	// Progress callback, called as each stage advances: "pagerank" counts
+	// the graphs ranked (the global one and one per category), "related"
+	// the papers whose related papers were found
+	Progress func(stage string, done, total int)
+}
+
+// AnalyticsStats summarizes a ComputeGraphAnalytics run.
+type AnalyticsStats struct {
+	Papers     int // Papers in the citation graph, cached or not
+	Citations  int // Citations between them
+	Categories int // Categories ranked on their own
+	Related    int // Related paper pairs stored
+}
+
+// RankedPaper is a paper with its PageRank.
+type RankedPaper struct {
+	ID     string
+	Title  string // Empty if the paper is not cached
+	Cached bool
+
+	// Rank is the paper's PageRank, scaled so that the average paper of
+	// its graph ranks 1
+	Rank float64
+}
+
+// RelatedPaper is a paper related to another by the citations they share.
+type RelatedPaper struct {
+	ID      string
+	Title   string // Empty if the paper is not cached
+	Authors string
+	Cached  bool
+
+	CoCitations int     // Papers citing both
+	SharedRefs  int     // References in common
+	Score       float64 // Co-citation plus coupling cosine similarity, 0 to 2
+}
+
+// ComputeGraphAnalytics computes PageRank, co-citation and bibliographic
+// coupling over the whole citation graph, and replaces the stored results
+// read by PageRank and Related in a single transaction.
+//
+// Each paper passes on its rank in proportion to the weight of its
+// citations. Per-category ranks only count citations between papers listed
+// in the category. Papers are related by the cosine similarity of the sets
+// of papers citing them plus that of their reference lists, leaving out
+// hubs as described for AnalyticsOptions.HubLimit.
+func (c *Cache) ComputeGraphAnalytics(ctx context.Context, opts *AnalyticsOptions) (*AnalyticsStats, error) {
+	if opts == nil {
+		opts = &AnalyticsOptions{}
+	}
+	damping := cmp.Or(opts.Damping, DefaultDamping)
+	related := cmp.Or(opts.Related, DefaultRelated)
+	hubLimit := cmp.Or(opts.HubLimit, DefaultHubLimit)
+	progress := func(stage string, done, total int) {
+		if opts.Progress != nil {
+			opts.Progress(stage, done, total)
+		}
+	}
+
+	g, err := c.loadCitationGraph(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats := &AnalyticsStats{Papers: len(g.ids), Citations: len(g.edges)}
+
+	byCategory := make(map[string][]int)
+	for i, cats := range g.categories {
+		for _, cat := range strings.Fields(cats) {
+			byCategory[cat] = append(byCategory[cat], i)
+		}
+	}
+	categories := slices.Sorted(maps.Keys(byCategory))
+
+	var ranks [][]any
+	total := 1 + len(categories)
+	for i, r := range pageRank(len(g.ids), g.edges, damping) {
+		ranks = append(ranks, []any{"", g.ids[i], r})
+	}
+	progress("pagerank", 1, total)
+	for n, cat := range categories {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		members := byCategory[cat]
+		local := make(map[int]int, len(members))
+		for i, m := range members {
+			local[m] = i
+		}
+		var edges []rankEdge
+		for _, m := range members {
+			for _, e := range g.out[m] {
+				if to, ok := local[e.to]; ok {
+					edges = append(edges, rankEdge{local[m], to, e.weight})
+				}
+			}
+		}
+		if len(edges) > 0 {
+			stats.Categories++
+			for i, r := range pageRank(len(members), edges, damping) {
+				ranks = append(ranks, []any{cat, g.ids[members[i]], r})
+			}
+		}
+		progress("pagerank", n+2, total)
+	}
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	for _, table := range []string{"paper_rank", "related_papers"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table); err != nil {
+			return nil, err
+		}
+	}
+	err = insertRows(ctx, tx, "INSERT INTO paper_rank (category, paper_id, rank)", ranks)
+	if err != nil {
+		return nil, err
+	}
+
+	// Related pairs are written as they are found, a batch at a time
+	const insertPairs = "INSERT INTO related_papers (paper_id, related_id, co_citations, shared_refs, score)"
+	var pairs [][]any
+	coCited := make([]int, len(g.ids))
+	coupled := make([]int, len(g.ids))
+	for a := range g.ids {
+		if a%1000 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		for _, r := range g.relatedTo(a, coCited, coupled, related, hubLimit) {
+			pairs = append(pairs, []any{g.ids[a], g.ids[r.b], r.coCited, r.coupled, r.score})
+		}
+		if len(pairs) >= insertBatch {
+			if err := insertRows(ctx, tx, insertPairs, pairs); err != nil {
+				return nil, err
+			}
+			stats.Related += len(pairs)
+			pairs = pairs[:0]
+		}
+		progress("related", a+1, len(g.ids))
+	}
+	if err := insertRows(ctx, tx, insertPairs, pairs); err != nil {
+		return nil, err
+	}
+	stats.Related += len(pairs)
+	_, err = tx.ExecContext(ctx, "INSERT OR REPLACE INTO sync_state (key, value) VALUES (?, ?)",
+		analyticsStateKey, time.Now().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	return stats, tx.Commit()
+}
+
+// GraphAnalyticsComputed returns when ComputeGraphAnalytics last ran, or
+// the zero time if it never has.
+func (c *Cache) GraphAnalyticsComputed(ctx context.Context) time.Time {
+	var v string
+	c.db.QueryRowContext(ctx, "SELECT value FROM sync_state WHERE key = ?", analyticsStateKey).Scan(&v)
+	t, _ := time.Parse(time.RFC3339, v)
+	return t
+}
+
+// PageRank returns the limit highest ranked papers, overall if category is
+// empty or else within the category, as of the last ComputeGraphAnalytics.
+func (c *Cache) PageRank(ctx context.Context, category string, limit int) ([]RankedPaper, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT r.paper_id, COALESCE(p.title, ''), p.id IS NOT NULL, r.rank
+		FROM paper_rank r LEFT JOIN papers p ON p.id = r.paper_id
+		WHERE r.category = ?
+		ORDER BY r.rank DESC, r.paper_id
+		LIMIT ?
+	`, category, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var papers []RankedPaper
+	for rows.Next() {
+		var p RankedPaper
+		if err := rows.Scan(&p.ID, &p.Title, &p.Cached, &p.Rank); err != nil {
+			return nil, err
+		}
+		papers = append(papers, p)
+	}
+	return papers, rows.Err()
+}
+
+// Related returns the papers most related to a paper by co-citation and
+// bibliographic coupling, most related first, as of the last
+// ComputeGraphAnalytics.
+func (c *Cache) Related(ctx context.Context, paperID string) ([]RelatedPaper, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT r.related_id, COALESCE(p.title, ''), COALESCE(p.authors, ''), p.id IS NOT NULL,
+		       r.co_citations, r.shared_refs, r.score
+		FROM related_papers r LEFT JOIN papers p ON p.id = r.related_id
+		WHERE r.paper_id = ?
+		ORDER BY r.score DESC, r.related_id
+	`, paperID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var papers []RelatedPaper
+	for rows.Next() {
+		var p RelatedPaper
+		if err := rows.Scan(&p.ID, &p.Title, &p.Authors, &p.Cached, &p.CoCitations, &p.SharedRefs, &p.Score); err != nil {
+			return nil, err
+		}
+		papers = append(papers, p)
+	}
+	return papers, rows.Err()
+}
+
+// rankEdge is a weighted citation between papers numbered from 0.
+type rankEdge struct {
+	from, to int
+	weight   float64
+}
+
+// citationGraph is the citations view in memory, with papers numbered from 0.
+type citationGraph struct {
+	ids        []string
+	categories []string // Space-separated, empty for uncached papers
+	edges      []rankEdge
+	out        [][]rankEdge // Citations from each paper
+	citedBy    [][]int      // Papers citing each paper
+}
+
+// loadCitationGraph reads the citations view and the categories of its
+// papers.
+func (c *Cache) loadCitationGraph(ctx context.Context) (*citationGraph, error) {
+	g := &citationGraph{}
+	index := make(map[string]int)
+	node := func(id string) int {
+		i, ok := index[id]
+		if !ok {
+			i = len(g.ids)
+			index[id] = i
+			g.ids = append(g.ids, id)
+		}
+		return i
+	}
+
+	rows, err := c.db.QueryContext(ctx, "SELECT from_id, to_id, weight FROM citations ORDER BY from_id, to_id")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var from, to string
+		var weight float64
+		if err := rows.Scan(&from, &to, &weight); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		g.edges = append(g.edges, rankEdge{node(from), node(to), weight})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	g.categories = make([]string, len(g.ids))
+	g.out = make([][]rankEdge, len(g.ids))
+	g.citedBy = make([][]int, len(g.ids))
+	for _, e := range g.edges {
+		g.out[e.from] = append(g.out[e.from], e)
+		g.citedBy[e.to] = append(g.citedBy[e.to], e.from)
+	}
+
+	rows, err = c.db.QueryContext(ctx, `
+		SELECT id, COALESCE(categories, '') FROM papers
+		WHERE id IN (SELECT paper_id FROM paper_references)
+		   OR id IN (SELECT arxiv_id FROM paper_references)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, cats string
+		if err := rows.Scan(&id, &cats); err != nil {
+			return nil, err
+		}
+		if i, ok := index[id]; ok {
+			g.categories[i] = cats
+		}
+	}
+	return g, rows.Err()
+}
+
+// relatedPair is a paper b related to another.
+type relatedPair struct {
+	b                int
+	coCited, coupled int
+	score            float64
+}
+
+// relatedTo returns the limit papers most related to paper a. coCited and
+// coupled are zeroed scratch counters, one per paper, and are left zeroed.
+// Citing papers with more than hubLimit references, and references with
+// more than hubLimit citing papers, are skipped, which bounds the work to
+// hubLimit times a's citations and references.
+func (g *citationGraph) relatedTo(a int, coCited, coupled []int, limit, hubLimit int) []relatedPair {
+	var candidates []int
+	count := func(counts []int, b int) {
+		if b == a {
+			return
+		}
+		if coCited[b] == 0 && coupled[b] == 0 {
+			candidates = append(candidates, b)
+		}
+		counts[b]++
+	}
+	for _, citing := range g.citedBy[a] {
+		if len(g.out[citing]) > hubLimit {
+			continue
+		}
+		for _, e := range g.out[citing] {
+			count(coCited, e.to)
+		}
+	}
+	for _, ref := range g.out[a] {
+		if len(g.citedBy[ref.to]) > hubLimit {
+			continue
+		}
+		for _, b := range g.citedBy[ref.to] {
+			count(coupled, b)
+		}
+	}
+
+	pairs := make([]relatedPair, len(candidates))
+	for i, b := range candidates {
+		p := relatedPair{b: b, coCited: coCited[b], coupled: coupled[b]}
+		if p.coCited > 0 {
+			p.score += float64(p.coCited) / math.Sqrt(float64(len(g.citedBy[a])*len(g.citedBy[b])))
+		}
+		if p.coupled > 0 {
+			p.score += float64(p.coupled) / math.Sqrt(float64(len(g.out[a])*len(g.out[b])))
+		}
+		pairs[i] = p
+		coCited[b], coupled[b] = 0, 0
+	}
+	slices.SortFunc(pairs, func(x, y relatedPair) int {
+		return cmp.Or(cmp.Compare(y.score, x.score), cmp.Compare(g.ids[x.b], g.ids[y.b]))
+	})
+	return pairs[:min(len(pairs), limit)]
+}
+
+// pageRank returns the PageRank of n papers linked by edges, scaled to
+// average 1. Each paper passes on its rank in proportion to the weights
+// of its citations; the rank of papers citing nothing is spread evenly.
+func pageRank(n int, edges []rankEdge, damping float64) []float64 {
+	out := make([]float64, n)
+	for _, e := range edges {
+		out[e.from] += e.weight
+	}
+	rank := make([]float64, n)
+	next := make([]float64, n)
+	for i := range rank {
+		rank[i] = 1 / float64(n)
+	}
+	for range pageRankIterations {
+		dangling := 0.0
+		for i, r := range rank {
+			if out[i] == 0 {
+				dangling += r
+			}
+		}
+		base := (1 - damping + damping*dangling) / float64(n)
+		for i := range next {
+			next[i] = base
+		}
+		for _, e := range edges {
+			next[e.to] += damping * rank[e.from] * e.weight / out[e.from]
+		}
+		delta := 0.0
+		for i := range rank {
+			delta += math.Abs(next[i] - rank[i])
+		}
+		rank, next = next, rank
+		if delta < pageRankTolerance {
+			break
+		}
+	}
+	for i := range rank {
+		rank[i] *= float64(n)
+	}
+	return rank
+}
@@ -0,0 +1,136 @@
+package arxiv
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestPageRank(t *testing.T) {
+	tests := []struct {
+		name  string
+		n     int
+		edges []rankEdge
+	}{
+		{"cycle", 3, []rankEdge{{0, 1, 1}, {1, 2, 1}, {2, 0, 1}}},
+		{"star", 4, []rankEdge{{1, 0, 1}, {2, 0, 1}, {3, 0, 1}}},
+		{"weighted", 4, []rankEdge{{0, 1, 3}, {0, 2, 1}, {1, 2, 2}, {3, 1, 0.5}}},
+		{"isolated paper", 3, []rankEdge{{0, 1, 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranks := pageRank(tt.n, tt.edges, DefaultDamping)
+			sum := 0.0
+			for _, r := range ranks {
+				sum += r
+			}
+			if avg := sum / float64(tt.n); math.Abs(avg-1) > 1e-6 {
+				t.Errorf("average rank = %v, want 1 (ranks %v)", avg, ranks)
+			}
+		})
+	}
+
+	// Every paper of a cycle ranks the same; the center of a star above
+	// the papers citing it
+	ranks := pageRank(3, tests[0].edges, DefaultDamping)
+	for _, r := range ranks {
+		if math.Abs(r-1) > 1e-6 {
+			t.Errorf("cycle ranks = %v, want all 1", ranks)
+			break
+		}
+	}
+	ranks = pageRank(4, tests[1].edges, DefaultDamping)
+	if ranks[0] <= ranks[1] || ranks[1] != ranks[2] || ranks[2] != ranks[3] {
+		t.Errorf("star ranks = %v", ranks)
+	}
+}
+
+func TestRelated(t *testing.T) {
+	// p1, p2 and p3 all cite a and b; p1 also cites c
+	refs := map[string][]string{
+		"2301.00001": {"2201.0000a", "2201.0000b", "2201.0000c"},
+		"2301.00002": {"2201.0000a", "2201.0000b"},
+		"2301.00003": {"2201.0000a", "2201.0000b"},
+	}
+	ctx := context.Background()
+	c, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for from, to := range refs {
+		for i, id := range to {
+			_, err := c.db.Exec("INSERT INTO paper_references (paper_id, position, arxiv_id) VALUES (?, ?, ?)", from, i, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	type pair struct {
+		id               string
+		coCited, coupled int
+		score            float64
+	}
+	tests := []struct {
+		name     string
+		hubLimit int
+		want     map[string][]pair
+	}{
+		{
+			name: "all",
+			want: map[string][]pair{
+				// Co-cited: by all three papers, or by p1 alone
+				"2201.0000a": {{"2201.0000b", 3, 0, 1}, {"2201.0000c", 1, 0, 1 / math.Sqrt(3)}},
+				"2201.0000c": {{"2201.0000a", 1, 0, 1 / math.Sqrt(3)}, {"2201.0000b", 1, 0, 1 / math.Sqrt(3)}},
+				// Coupled: two shared references out of two or three
+				"2301.00001": {{"2301.00002", 0, 2, 2 / math.Sqrt(6)}, {"2301.00003", 0, 2, 2 / math.Sqrt(6)}},
+				"2301.00002": {{"2301.00003", 0, 2, 1}, {"2301.00001", 0, 2, 2 / math.Sqrt(6)}},
+			},
+		},
+		{
+			// a and b are hubs, and so is p1 as a citing paper
+			name:     "hubs",
+			hubLimit: 2,
+			want: map[string][]pair{
+				"2201.0000a": {{"2201.0000b", 2, 0, 2.0 / 3}},
+				"2201.0000c": nil,
+				// Only p1 cites c, so no references are left to share
+				"2301.00001": nil,
+				"2301.00002": nil,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := c.ComputeGraphAnalytics(ctx, &AnalyticsOptions{HubLimit: tt.hubLimit})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Papers != 6 || stats.Citations != 7 {
+				t.Errorf("stats = %+v, want 6 papers and 7 citations", stats)
+			}
+			for id, want := range tt.want {
+				related, err := c.Related(ctx, id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got []pair
+				for _, r := range related {
+					got = append(got, pair{r.ID, r.CoCitations, r.SharedRefs, r.Score})
+				}
+				if len(got) != len(want) {
+					t.Errorf("Related(%s) = %v, want %v", id, got, want)
+					continue
+				}
+				for i := range got {
+					if got[i].id != want[i].id || got[i].coCited != want[i].coCited ||
+						got[i].coupled != want[i].coupled || math.Abs(got[i].score-want[i].score) > 1e-9 {
+						t.Errorf("Related(%s) = %v, want %v", id, got, want)
+						break
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package arxiv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// apiBatchSize is the maximum number of IDs requested per API call.
+const apiBatchSize = 100
+
+// How long an ID that arXiv did not return a paper for is skipped before
+// it is requested again. Withdrawn papers are rarely reinstated, so they
+// are checked less often. Malformed IDs are never requested.
+const (
+	negativeCacheTTL  = 7 * 24 * time.Hour
+	withdrawnCacheTTL = 30 * 24 * time.Hour
+)
+
+// MissingReason explains why a requested paper was not found.
+type MissingReason string
+
+const (
+	ReasonNotFound  MissingReason = "not found"
+	ReasonWithdrawn MissingReason = "withdrawn"
+	ReasonMalformed MissingReason = "malformed id"
+)
+
+// ttl returns how long IDs missing for reason r are negatively cached.
+func (r MissingReason) ttl() time.Duration {
+	if r == ReasonWithdrawn {
+		return withdrawnCacheTTL
+	}
+	return negativeCacheTTL
+}
+
+// MissingID is a requested ID that arXiv did not return a paper for.
+type MissingID struct {
+	ID     string
+	Reason MissingReason
+}
+
+// BatchError is a requested ID that could not be fetched or stored.
+type BatchError struct {
+	ID  string
+	Err error
+}
+
+func (e BatchError) Error() string {
+	return fmt.Sprintf("%s: %v", e.ID, e.Err)
+}
+
+func (e BatchError) Unwrap() error {
+	return e.Err
+}
+
+// BatchResult reports the outcome of a FetchBatch or Refresh for each
+// requested ID.
+type BatchResult struct {
+	// Found are the papers that are now in the cache, including stale
+	// copies of cached papers whose request failed
+	Found []*Paper
+
+	// Missing are IDs that do not name a paper on arXiv, including cached
+	// papers that arXiv now reports withdrawn or unknown; an ID is never
+	// in both Found and Missing
+	Missing []MissingID
+
+	// Errors are IDs whose request or insert failed; retrying may help
+	Errors []BatchError
+}
+
+// Err returns the per-ID errors joined into one, or nil if there were none.
+func (r *BatchResult) Err() error {
+	var errs []error
+	for _, e := range r.Errors {
+		errs = append(errs, e)
+	}
+	return errors.Join(errs...)
+}
+
+var (
+	newStyleID = regexp.MustCompile(`^\d{4}\.\d{4,5}(v\d+)?$`)
+	oldStyleID = regexp.MustCompile(`^[a-z]+(-[a-z]+)?(\.[A-Z]{2})?/\d{7}(v\d+)?$`)
+)
+
+// validID reports whether id is a well-formed arXiv identifier, in either
+// the current (2301.00001) or pre-2007 (hep-th/9901001) scheme.
+func validID(id string) bool {
+	return newStyleID.MatchString(id) || oldStyleID.MatchString(id)
+}
+
+// isWithdrawn reports whether the paper's latest version is a withdrawal
+// notice rather than a paper.
+func isWithdrawn(p *Paper) bool {
+	for _, s := range []string{p.Comments, p.Abstract} {
+		s = strings.ToLower(s)
+		if strings.Contains(s, "paper has been withdrawn") || strings.HasPrefix(s, "withdrawn") {
+			return true
+		}
+	}
+	return false
+}
+
+// knownMissing returns the negatively cached reason for id, if any.
+func (c *Cache) knownMissing(ctx context.Context, id string) (MissingReason, bool) {
+	var reason, lastAttempt string
+	err := c.db.QueryRowContext(ctx,
+		"SELECT reason, last_attempt FROM fetch_failures WHERE paper_id = ?", id).Scan(&reason, &lastAttempt)
+	if err != nil {
+		return "", false
+	}
+	t, err := time.Parse(time.RFC3339, lastAttempt)
+	if err != nil || time.Since(t) > MissingReason(reason).ttl() {
+		return "", false
+	}
+	return MissingReason(reason), true
+}
+
+// markMissing records that arXiv has no paper for id.
+func (c *Cache) markMissing(ctx context.Context, id string, reason MissingReason) {
+	c.db.ExecContext(ctx, `
+		INSERT INTO fetch_failures (paper_id, reason, last_attempt, attempts) VALUES (?, ?, ?, 1)
+		ON CONFLICT(paper_id) DO UPDATE SET
+			reason = excluded.reason,
+			last_attempt = excluded.last_attempt,
+			attempts = attempts + 1
+	`, id, string(reason), time.Now().Format(time.RFC3339))
+}
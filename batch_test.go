@@ -0,0 +1,276 @@
+package arxiv
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAPI serves the arXiv API from entries, keyed by ID. It records the
+// IDs requested by id_list queries, and the pages (start+max_results) of
+// search queries, which match every entry in ID order.
+type fakeAPI struct {
+	mu        sync.Mutex
+	entries   map[string]atomEntry
+	requested []string
+	pages     []string
+}
+
+// newFakeAPI returns a cache whose API requests go to a fakeAPI.
+func newFakeAPI(t *testing.T) (*fakeAPI, *Cache) {
+	t.Helper()
+	api := &fakeAPI{entries: make(map[string]atomEntry)}
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	c, err := OpenWithOptions(t.TempDir(), &Options{APIURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return api, c
+}
+
+// add serves a paper, replacing any earlier version.
+func (api *fakeAPI) add(id string, version int, title, comment string) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.entries[id] = atomEntry{
+		ID:         "http://arxiv.org/abs/" + id + "v" + strconv.Itoa(version),
+		Title:      title,
+		Summary:    "Abstract of " + title,
+		Authors:    []atomAuthor{{Name: "Ada Lovelace"}},
+		Categories: []atomCategory{{Term: "cs.LG"}},
+		Published:  "2023-01-02T00:00:00Z",
+		Updated:    "2023-01-02T00:00:00Z",
+		Comment:    comment,
+	}
+}
+
+// takeRequested returns the IDs requested since the last call.
+func (api *fakeAPI) takeRequested() []string {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	ids := api.requested
+	api.requested = nil
+	slices.Sort(ids)
+	return ids
+}
+
+func (api *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	var feed atomFeed
+	if q := r.URL.Query(); q.Get("search_query") != "" {
+		start, _ := strconv.Atoi(q.Get("start"))
+		n, _ := strconv.Atoi(q.Get("max_results"))
+		api.pages = append(api.pages, q.Get("start")+"+"+q.Get("max_results"))
+		ids := slices.Sorted(maps.Keys(api.entries))
+		for _, id := range ids[min(start, len(ids)):min(start+n, len(ids))] {
+			feed.Entries = append(feed.Entries, api.entries[id])
+		}
+		feed.TotalResults = len(ids)
+		xml.NewEncoder(w).Encode(feed)
+		return
+	}
+	for id := range strings.SplitSeq(r.URL.Query().Get("id_list"), ",") {
+		api.requested = append(api.requested, id)
+		if e, ok := api.entries[bareID(id)]; ok {
+			feed.Entries = append(feed.Entries, e)
+		}
+	}
+	feed.TotalResults = len(feed.Entries)
+	xml.NewEncoder(w).Encode(feed)
+}
+
+func TestFetchBatch(t *testing.T) {
+	ctx := context.Background()
+	api, c := newFakeAPI(t)
+	api.add("2301.00001", 1, "Found", "")
+	api.add("2301.00002", 1, "Withdrawn", "This paper has been withdrawn by the author")
+
+	type outcome struct {
+		found     []string
+		missing   map[string]MissingReason
+		requested []string
+	}
+	tests := []struct {
+		name  string
+		setup func(t *testing.T)
+		ids   []string
+		want  outcome
+	}{
+		{
+			name: "first fetch",
+			ids:  []string{"2301.00001", "2301.00002", "2301.00003", "not-an-id"},
+			want: outcome{
+				found: []string{"2301.00001"},
+				missing: map[string]MissingReason{
+					"2301.00002": ReasonWithdrawn,
+					"2301.00003": ReasonNotFound,
+					"not-an-id":  ReasonMalformed,
+				},
+				requested: []string{"2301.00001", "2301.00002", "2301.00003"},
+			},
+		},
+		{
+			name: "negatively cached",
+			ids:  []string{"2301.00001", "2301.00002", "2301.00003v2"},
+			want: outcome{
+				found: []string{"2301.00001"},
+				missing: map[string]MissingReason{
+					"2301.00002":   ReasonWithdrawn,
+					"2301.00003v2": ReasonNotFound,
+				},
+			},
+		},
+		{
+			name: "not found expired",
+			setup: func(t *testing.T) {
+				backdateFailures(t, c, negativeCacheTTL+time.Hour)
+			},
+			ids: []string{"2301.00002", "2301.00003"},
+			want: outcome{
+				missing: map[string]MissingReason{
+					"2301.00002": ReasonWithdrawn,
+					"2301.00003": ReasonNotFound,
+				},
+				requested: []string{"2301.00003"},
+			},
+		},
+		{
+			name: "withdrawn expired",
+			setup: func(t *testing.T) {
+				backdateFailures(t, c, withdrawnCacheTTL+time.Hour)
+			},
+			ids: []string{"2301.00002"},
+			want: outcome{
+				missing:   map[string]MissingReason{"2301.00002": ReasonWithdrawn},
+				requested: []string{"2301.00002"},
+			},
+		},
+		{
+			name: "published after a miss",
+			setup: func(t *testing.T) {
+				api.add("2301.00003", 1, "Late", "")
+				backdateFailures(t, c, negativeCacheTTL+time.Hour)
+			},
+			ids: []string{"2301.00003"},
+			want: outcome{
+				found:     []string{"2301.00003"},
+				requested: []string{"2301.00003"},
+			},
+		},
+		{
+			name: "reinstated after withdrawal",
+			setup: func(t *testing.T) {
+				api.add("2301.00002", 3, "Reinstated", "")
+				backdateFailures(t, c, withdrawnCacheTTL+time.Hour)
+			},
+			ids: []string{"2301.00002", "2301.00003"},
+			want: outcome{
+				found:     []string{"2301.00002", "2301.00003"},
+				requested: []string{"2301.00002"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.setup != nil {
+				tt.setup(t)
+			}
+			res, err := c.FetchBatch(ctx, tt.ids)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := res.Err(); err != nil {
+				t.Errorf("Err() = %v", err)
+			}
+
+			var found []string
+			for _, p := range res.Found {
+				found = append(found, p.ID)
+			}
+			slices.Sort(found)
+			if !slices.Equal(found, tt.want.found) {
+				t.Errorf("found %v, want %v", found, tt.want.found)
+			}
+			missing := make(map[string]MissingReason)
+			for _, m := range res.Missing {
+				missing[m.ID] = m.Reason
+			}
+			if len(missing) != len(tt.want.missing) {
+				t.Errorf("missing %v, want %v", missing, tt.want.missing)
+			}
+			for id, reason := range tt.want.missing {
+				if missing[id] != reason {
+					t.Errorf("missing %v, want %v", missing, tt.want.missing)
+					break
+				}
+			}
+			if got := api.takeRequested(); !slices.Equal(got, tt.want.requested) {
+				t.Errorf("requested %v, want %v", got, tt.want.requested)
+			}
+
+			// Papers that are found are no longer negatively cached
+			for _, id := range found {
+				if reason, ok := c.knownMissing(ctx, id); ok {
+					t.Errorf("%s is still cached as %s", id, reason)
+				}
+			}
+		})
+	}
+}
+
+// backdateFailures moves the last attempt of every negatively cached ID
+// back by d.
+func backdateFailures(t *testing.T, c *Cache, d time.Duration) {
+	t.Helper()
+	_, err := c.db.Exec("UPDATE fetch_failures SET last_attempt = ?", time.Now().Add(-d).Format(time.RFC3339))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBatchResultErr(t *testing.T) {
+	errTimeout := errors.New("timeout")
+	tests := []struct {
+		name   string
+		errors []BatchError
+		want   string
+	}{
+		{"none", nil, ""},
+		{"one", []BatchError{{ID: "2301.00001", Err: errTimeout}}, "2301.00001: timeout"},
+		{
+			name:   "two",
+			errors: []BatchError{{ID: "2301.00001", Err: errTimeout}, {ID: "2301.00002", Err: errTimeout}},
+			want:   "2301.00001: timeout\n2301.00002: timeout",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &BatchResult{Errors: tt.errors}
+			err := res.Err()
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Err() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Err() = %v, want %q", err, tt.want)
+			}
+			if !errors.Is(err, errTimeout) {
+				t.Errorf("Err() = %v, does not wrap %v", err, errTimeout)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package arxiv
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BibEntry is one entry of an imported .bib or .bbl file.
+type BibEntry struct {
+	// Key is the citation key
+	Key string
+
+	// File is the file the entry was read from
+	File string
+
+	Title string
+	DOI   string
+
+	// ArxivID is the arXiv paper the entry cites, or "" if unresolved
+	ArxivID string
+
+	// Via is how ArxivID was found: "arxiv" for an arXiv ID or arXiv DOI in
+	// the entry, "doi" for a DOI found in the cache
+	Via string
+}
+
+// ImportOptions configures ImportBib.
+type ImportOptions struct {
+	// Enqueue queues downloads for the cited papers: QueueSource, QueuePDF
+	// or QueueAll (default: metadata only)
+	Enqueue string
+}
+
+// ImportResult reports the outcome of ImportBib.
+type ImportResult struct {
+	// Resolved are entries that cite an arXiv paper
+	Resolved []BibEntry
+
+	// Unresolved are entries for which no arXiv paper was found
+	Unresolved []BibEntry
+
+	// Fetch reports which cited papers' metadata could be fetched
+	Fetch *BatchResult
+}
+
+// ImportBib reads the bibliography entries of a .bib or .bbl file, or of
+// every such file under a directory, and caches the arXiv papers they
+// cite. Entries are resolved by the arXiv IDs they contain (eprint fields,
+// arXiv URLs, arXiv DOIs) and otherwise by looking their DOI up among the
+// cached papers. Metadata is fetched with FetchBatch.
+func (c *Cache) ImportBib(ctx context.Context, path string, opts *ImportOptions) (*ImportResult, error) {
+	if opts == nil {
+		opts = &ImportOptions{}
+	}
+
+	entries, err := readBibEntries(path)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ImportResult{}
+	var ids []string
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		if e.ArxivID == "" && e.DOI != "" {
+			var id string
+			err := c.db.QueryRowContext(ctx,
+				"SELECT id FROM papers WHERE doi = ? COLLATE NOCASE LIMIT 1", e.DOI).Scan(&id)
+			if err != nil && err != sql.ErrNoRows {
+				return nil, err
+			}
+			if id != "" {
+				e.ArxivID, e.Via = id, "doi"
+			}
+		}
+		if e.ArxivID == "" {
+			res.Unresolved = append(res.Unresolved, e)
+			continue
+		}
+		res.Resolved = append(res.Resolved, e)
+		if !seen[e.ArxivID] {
+			seen[e.ArxivID] = true
+			ids = append(ids, e.ArxivID)
+		}
+	}
+
+	res.Fetch, err = c.FetchBatch(ctx, ids)
+	if err != nil {
+		return res, err
+	}
+
+	if opts.Enqueue != "" {
+		for _, p := range res.Fetch.Found {
+			if err := c.Enqueue(ctx, p.ID, opts.Enqueue); err != nil {
+				return res, err
+			}
+		}
+	}
+	return res, nil
+}
+
+// readBibEntries parses path, or every .bib and .bbl file under it.
+func readBibEntries(path string) ([]BibEntry, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return parseBibEntries(path, string(data)), nil
+	}
+
+	var entries []BibEntry
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(p)) {
+		case ".bib", ".bbl":
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, parseBibEntries(p, string(data))...)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// parseBibEntries parses the entries of a .bib or .bbl file for ImportBib.
+func parseBibEntries(file, text string) []BibEntry {
+	refs := parseBibliography(file, text)
+	entries := make([]BibEntry, len(refs))
+	for i, r := range refs {
+		entries[i] = BibEntry{Key: r.Key, File: file, Title: r.Title, DOI: r.DOI, ArxivID: r.ArxivID}
+		if r.ArxivID != "" {
+			entries[i].Via = "arxiv"
+		}
+	}
+	return entries
+}
@@ -0,0 +1,112 @@
+package arxiv
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestImportBib(t *testing.T) {
+	ctx := context.Background()
+	api, c := newFakeAPI(t)
+	api.add("2301.00001", 1, "Eprint", "")
+	api.add("2301.00002", 1, "Arxiv DOI", "")
+	api.add("2301.00003", 1, "Arxiv URL", "")
+
+	// A cached paper with a journal DOI
+	err := c.insertPapers(ctx, []Paper{{ID: "1512.03385", Title: "Deep Residual Learning", DOI: "10.1109/CVPR.2016.90"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"refs.bib": `@article{eprint, title = {Eprint}, eprint = {2301.00001}, archivePrefix = {arXiv}}
+@article{arxivdoi, title = {Arxiv DOI}, doi = {10.48550/arXiv.2301.00002}}
+@inproceedings{journaldoi, title = {Deep Residual Learning}, doi = {10.1109/cvpr.2016.90}}
+@article{uncached, title = {Elsewhere}, doi = {10.1000/xyz}}
+@book{plain, title = {A Book}, year = 1999}`,
+		"sub/paper.bbl": `\bibitem{url} A. Author. \newblock Arxiv URL. \newblock https://arxiv.org/abs/2301.00003, 2023.
+\bibitem{again} Eprint again. \newblock arXiv:2301.00001.`,
+		"notes.txt": `@article{ignored, eprint = {2301.09999}}`,
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := c.ImportBib(ctx, dir, &ImportOptions{Enqueue: QueuePDF})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key, arxivID, via string
+	}{
+		{"eprint", "2301.00001", "arxiv"},
+		{"arxivdoi", "2301.00002", "arxiv"},
+		{"journaldoi", "1512.03385", "doi"},
+		{"uncached", "", ""},
+		{"plain", "", ""},
+		{"url", "2301.00003", "arxiv"},
+		{"again", "2301.00001", "arxiv"},
+	}
+	entries := make(map[string]BibEntry)
+	for _, e := range append(res.Resolved, res.Unresolved...) {
+		entries[e.Key] = e
+	}
+	if len(entries) != len(tests) {
+		t.Errorf("got %d entries, want %d: %+v", len(entries), len(tests), entries)
+	}
+	for _, tt := range tests {
+		e, ok := entries[tt.key]
+		if !ok {
+			t.Errorf("%s: no entry", tt.key)
+			continue
+		}
+		if e.ArxivID != tt.arxivID || e.Via != tt.via {
+			t.Errorf("%s: resolved to %q via %q, want %q via %q", tt.key, e.ArxivID, e.Via, tt.arxivID, tt.via)
+		}
+		if resolved := slices.ContainsFunc(res.Resolved, func(r BibEntry) bool { return r.Key == tt.key }); resolved != (tt.arxivID != "") {
+			t.Errorf("%s: in Resolved = %v", tt.key, resolved)
+		}
+	}
+
+	// Each cited paper is fetched once, unless already cached, and queued
+	if got, want := api.takeRequested(), []string{"2301.00001", "2301.00002", "2301.00003"}; !slices.Equal(got, want) {
+		t.Errorf("requested %v, want %v", got, want)
+	}
+	if len(res.Fetch.Found) != 4 || len(res.Fetch.Missing) != 0 {
+		t.Errorf("Fetch = %+v", res.Fetch)
+	}
+	queued, err := c.queryIDs(ctx, "SELECT paper_id FROM download_queue WHERE type = ? ORDER BY paper_id", QueuePDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"1512.03385", "2301.00001", "2301.00002", "2301.00003"}; !slices.Equal(queued, want) {
+		t.Errorf("queued %v, want %v", queued, want)
+	}
+
+	// A single file
+	res, err = c.ImportBib(ctx, filepath.Join(dir, "sub", "paper.bbl"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Resolved) != 2 || len(res.Unresolved) != 0 {
+		t.Errorf("single file: %+v", res)
+	}
+	if got := api.takeRequested(); len(got) != 0 {
+		t.Errorf("requested cached papers %v", got)
+	}
+
+	if _, err := c.ImportBib(ctx, filepath.Join(dir, "missing.bib"), nil); err == nil {
+		t.Error("importing a missing file succeeded")
+	}
+}
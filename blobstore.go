@@ -0,0 +1,295 @@
+package arxiv
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BlobStore stores downloaded artifacts (PDFs and source files).
+//
+// Keys are slash-separated paths such as "pdf/2301/2301.00001.pdf" or
+// "src/2301/2301.00001/main.tex". Methods report missing keys with an error
+// satisfying errors.Is(err, fs.ErrNotExist).
+type BlobStore interface {
+	// Put stores the contents of r under key, replacing any existing blob.
+	Put(ctx context.Context, key string, r io.Reader) error
+
+	// Get opens the blob stored under key for streaming reads.
+	Get(ctx context.Context, key string) (io.ReadSeekCloser, error)
+
+	// Stat returns information about the blob stored under key.
+	Stat(ctx context.Context, key string) (*BlobInfo, error)
+
+	// List returns all blobs whose keys start with prefix, sorted by key.
+	List(ctx context.Context, prefix string) ([]BlobInfo, error)
+
+	// Delete removes the blob stored under key.
+	Delete(ctx context.Context, key string) error
+}
+
+// BlobInfo describes a stored blob.
+type BlobInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// FileStore is a BlobStore backed by a directory on the local filesystem.
+type FileStore struct {
+	root string
+}
+
+// NewFileStore returns a FileStore rooted at dir.
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{root: dir}
+}
+
+// Path returns the filesystem path for key.
+func (s *FileStore) Path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
+
+// Put implements BlobStore. The blob is written to a temporary file and
+// renamed into place, so readers never observe a partial write.
+func (s *FileStore) Put(ctx context.Context, key string, r io.Reader) error {
+	if !fs.ValidPath(key) {
+		return fmt.Errorf("invalid key %q", key)
+	}
+	p := s.Path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(p), ".put-*")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+// Get implements BlobStore.
+func (s *FileStore) Get(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	if !fs.ValidPath(key) {
+		return nil, &fs.PathError{Op: "get", Path: key, Err: fs.ErrInvalid}
+	}
+	f, err := os.Open(s.Path(key))
+	if err != nil {
+		return nil, err
+	}
+	if info, err := f.Stat(); err != nil || info.IsDir() {
+		f.Close()
+		return nil, &fs.PathError{Op: "get", Path: key, Err: fs.ErrNotExist}
+	}
+	return f, nil
+}
+
+// Stat implements BlobStore.
+func (s *FileStore) Stat(ctx context.Context, key string) (*BlobInfo, error) {
+	if !fs.ValidPath(key) {
+		return nil, &fs.PathError{Op: "stat", Path: key, Err: fs.ErrInvalid}
+	}
+	info, err := os.Stat(s.Path(key))
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, &fs.PathError{Op: "stat", Path: key, Err: fs.ErrNotExist}
+	}
+	return &BlobInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// List implements BlobStore.
+func (s *FileStore) List(ctx context.Context, prefix string) ([]BlobInfo, error) {
+	// Walk the deepest directory that contains every matching key.
+	dir := prefix
+	if !strings.HasSuffix(dir, "/") {
+		dir = path.Dir(dir)
+	}
+	dir = strings.TrimSuffix(dir, "/")
+	if dir == "" {
+		dir = "."
+	}
+
+	var blobs []BlobInfo
+	err := filepath.WalkDir(s.Path(dir), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".put-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		blobs = append(blobs, BlobInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	return blobs, err
+}
+
+// Delete implements BlobStore.
+func (s *FileStore) Delete(ctx context.Context, key string) error {
+	if !fs.ValidPath(key) {
+		return &fs.PathError{Op: "delete", Path: key, Err: fs.ErrInvalid}
+	}
+	return os.Remove(s.Path(key))
+}
+
+// blobFS presents the blobs under a key prefix as a read-only fs.FS, so
+// code written against a directory tree can read from any BlobStore.
+type blobFS struct {
+	ctx   context.Context
+	store BlobStore
+	files map[string]BlobInfo
+	dirs  map[string][]fs.DirEntry
+}
+
+func newBlobFS(ctx context.Context, store BlobStore, prefix string) (*blobFS, error) {
+	blobs, err := store.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	fsys := &blobFS{
+		ctx:   ctx,
+		store: store,
+		files: make(map[string]BlobInfo),
+		dirs:  map[string][]fs.DirEntry{".": nil},
+	}
+	for _, b := range blobs {
+		name := strings.TrimPrefix(b.Key, prefix)
+		if !fs.ValidPath(name) || name == "." {
+			continue
+		}
+		fsys.files[name] = b
+		fsys.addEntry(name, blobFileInfo{name: path.Base(name), size: b.Size, modTime: b.ModTime})
+	}
+	for _, entries := range fsys.dirs {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	}
+	return fsys, nil
+}
+
+// addEntry records name in its parent directory, creating parents as needed.
+func (f *blobFS) addEntry(name string, info blobFileInfo) {
+	dir := path.Dir(name)
+	if _, ok := f.dirs[dir]; !ok {
+		f.dirs[dir] = nil
+		f.addEntry(dir, blobFileInfo{name: path.Base(dir), dir: true})
+	}
+	f.dirs[dir] = append(f.dirs[dir], fs.FileInfoToDirEntry(info))
+}
+
+func (f *blobFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if entries, ok := f.dirs[name]; ok {
+		return &blobDir{info: blobFileInfo{name: path.Base(name), dir: true}, entries: entries}, nil
+	}
+	b, ok := f.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	rc, err := f.store.Get(f.ctx, b.Key)
+	if err != nil {
+		return nil, err
+	}
+	return &blobFile{ReadSeekCloser: rc, info: blobFileInfo{name: path.Base(name), size: b.Size, modTime: b.ModTime}}, nil
+}
+
+func (f *blobFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, ok := f.dirs[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]fs.DirEntry(nil), entries...), nil
+}
+
+type blobFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (i blobFileInfo) Name() string       { return i.name }
+func (i blobFileInfo) Size() int64        { return i.size }
+func (i blobFileInfo) ModTime() time.Time { return i.modTime }
+func (i blobFileInfo) IsDir() bool        { return i.dir }
+func (i blobFileInfo) Sys() any           { return nil }
+
+func (i blobFileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+type blobFile struct {
+	io.ReadSeekCloser
+	info blobFileInfo
+}
+
+func (f *blobFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+type blobDir struct {
+	info    blobFileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *blobDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *blobDir) Close() error               { return nil }
+
+func (d *blobDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+func (d *blobDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.offset += n
+	return rest[:n], nil
+}
@@ -1,23 +1,81 @@
 package arxiv
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
 
 // Cache manages a local offline cache of arXiv papers.
 type Cache struct {
-	root string
-	db   *sql.DB
+	root    string
+	db      *sql.DB
+	blobs   BlobStore
+	limiter *limiter
+	maxAge  time.Duration
+
+	// policies are download policies given in Options, applied along with
+	// those stored in the cache
+	policies []DownloadPolicy
+
+	// textExtractor reads PDFs for references
+	textExtractor TextExtractor
+
+	apiURL      string
+	oaiURL      string
+	downloadURL string
+}
+
+// Options configures a Cache.
+type Options struct {
+	// Blobs stores downloaded PDFs and sources (default: a FileStore
+	// rooted at the cache directory). The index always stays local.
+	Blobs BlobStore
+
+	// RateLimits overrides DefaultRateLimits for the given hosts.
+	// Limits are shared by every process using the same cache directory.
+	RateLimits map[string]RateLimit
+
+	// MaxAge is how old cached metadata may get before Fetch and FetchBatch
+	// re-fetch it from arXiv (default 0: never)
+	MaxAge time.Duration
+
+	// APIURL, OAIURL and DownloadURL override the arXiv endpoints, e.g. to
+	// use a mirror. DownloadURL is the base of the /pdf/ and /e-print/
+	// paths (default https://arxiv.org). Mirror hosts are not rate limited
+	// unless listed in RateLimits.
+	APIURL      string
+	OAIURL      string
+	DownloadURL string
+
+	// Policies are download policies applied in addition to those stored
+	// with AddPolicy, e.g. from a configuration file.
+	Policies []DownloadPolicy
+
+	// TextExtractor reads the text of PDFs, to find the references of
+	// papers without TeX source (default: PDFTextExtractor)
+	TextExtractor TextExtractor
 }
 
 // Open opens or creates an arXiv cache at the given root directory.
 func Open(root string) (*Cache, error) {
+	return OpenWithOptions(root, nil)
+}
+
+// OpenWithOptions opens or creates an arXiv cache at the given root directory
+// using the given options.
+func OpenWithOptions(root string, opts *Options) (*Cache, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
 	if err := os.MkdirAll(root, 0755); err != nil {
 		return nil, fmt.Errorf("create cache dir: %w", err)
 	}
@@ -29,13 +87,38 @@ func Open(root string) (*Cache, error) {
 		}
 	}
 
+	// Several processes may share a cache (e.g., sync and serve), so wait
+	// for locks rather than failing immediately.
 	dbPath := filepath.Join(root, "index.db")
-	db, err := sql.Open("sqlite", dbPath)
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=busy_timeout(10000)")
 	if err != nil {
 		return nil, fmt.Errorf("open database: %w", err)
 	}
 
-	c := &Cache{root: root, db: db}
+	c := &Cache{
+		root:        root,
+		db:          db,
+		blobs:       opts.Blobs,
+		maxAge:      opts.MaxAge,
+		policies:    opts.Policies,
+		apiURL:      cmp.Or(opts.APIURL, apiBaseURL),
+		oaiURL:      cmp.Or(opts.OAIURL, oaiBaseURL),
+		downloadURL: strings.TrimSuffix(cmp.Or(opts.DownloadURL, downloadBaseURL), "/"),
+	}
+	if c.blobs == nil {
+		c.blobs = NewFileStore(root)
+	}
+	c.textExtractor = opts.TextExtractor
+	if c.textExtractor == nil {
+		c.textExtractor = PDFTextExtractor{}
+	}
+	for i := range c.policies {
+		if err := c.policies[i].validate(); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	c.limiter = newLimiter(c, opts.RateLimits)
 	if err := c.initSchema(); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("init schema: %w", err)
@@ -53,6 +136,11 @@ func (c *Cache) Root() string {
 	return c.root
 }
 
+// Blobs returns the store holding downloaded PDFs and sources.
+func (c *Cache) Blobs() BlobStore {
+	return c.blobs
+}
+
 func (c *Cache) initSchema() error {
 	schema := `
 	CREATE TABLE IF NOT EXISTS papers (
@@ -77,6 +165,8 @@ func (c *Cache) initSchema() error {
 	CREATE INDEX IF NOT EXISTS idx_papers_created ON papers(created);
 	CREATE INDEX IF NOT EXISTS idx_papers_updated ON papers(updated);
 	CREATE INDEX IF NOT EXISTS idx_papers_categories ON papers(categories);
+	CREATE INDEX IF NOT EXISTS idx_papers_doi ON papers(doi COLLATE NOCASE);
+	CREATE INDEX IF NOT EXISTS idx_papers_license ON papers(license);
 
 	CREATE TABLE IF NOT EXISTS sync_state (
 		key TEXT PRIMARY KEY,
@@ -116,16 +206,216 @@ func (c *Cache) initSchema() error {
 		VALUES (NEW.rowid, NEW.title, NEW.abstract);
 	END;
 
-	CREATE TABLE IF NOT EXISTS citations (
-		from_id TEXT NOT NULL,
-		to_id TEXT NOT NULL,
-		PRIMARY KEY (from_id, to_id)
+	CREATE TABLE IF NOT EXISTS paper_references (
+		paper_id TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		cite_key TEXT NOT NULL DEFAULT '',
+		authors TEXT NOT NULL DEFAULT '',
+		title TEXT NOT NULL DEFAULT '',
+		venue TEXT NOT NULL DEFAULT '',
+		year INTEGER NOT NULL DEFAULT 0,
+		doi TEXT NOT NULL DEFAULT '',
+		arxiv_id TEXT NOT NULL DEFAULT '',
+		raw TEXT NOT NULL DEFAULT '',
+		confidence REAL NOT NULL DEFAULT 1,
+		resolved_by TEXT NOT NULL DEFAULT '',
+		mentions INTEGER NOT NULL DEFAULT 0,
+		weight REAL NOT NULL DEFAULT 1,
+		PRIMARY KEY (paper_id, position)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_paper_references_arxiv_id ON paper_references(arxiv_id);
+
+	CREATE TABLE IF NOT EXISTS citation_contexts (
+		paper_id TEXT NOT NULL,
+		seq INTEGER NOT NULL,
+		cite_key TEXT NOT NULL,
+		section TEXT NOT NULL DEFAULT '',
+		sentence TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (paper_id, seq, cite_key)
+	);
+
+	CREATE TABLE IF NOT EXISTS paper_index (
+		paper_id TEXT PRIMARY KEY,
+		source_hash TEXT NOT NULL,
+		extractor INTEGER NOT NULL,
+		indexed TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS paper_rank (
+		category TEXT NOT NULL,
+		paper_id TEXT NOT NULL,
+		rank REAL NOT NULL,
+		PRIMARY KEY (category, paper_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_paper_rank_rank ON paper_rank(category, rank);
+
+	CREATE TABLE IF NOT EXISTS related_papers (
+		paper_id TEXT NOT NULL,
+		related_id TEXT NOT NULL,
+		co_citations INTEGER NOT NULL DEFAULT 0,
+		shared_refs INTEGER NOT NULL DEFAULT 0,
+		score REAL NOT NULL,
+		PRIMARY KEY (paper_id, related_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS objects (
+		hash TEXT PRIMARY KEY,
+		size INTEGER
+	);
+
+	CREATE TABLE IF NOT EXISTS source_files (
+		paper_id TEXT NOT NULL,
+		path TEXT NOT NULL,
+		hash TEXT NOT NULL,
+		PRIMARY KEY (paper_id, path)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_source_files_hash ON source_files(hash);
+
+	CREATE TABLE IF NOT EXISTS pack_entries (
+		paper_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		pack TEXT NOT NULL,
+		offset INTEGER,
+		size INTEGER,
+		csize INTEGER,
+		method INTEGER,
+		modified TEXT,
+		file TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (paper_id, name)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_pack_entries_pack ON pack_entries(pack);
+
+	CREATE TABLE IF NOT EXISTS rate_limits (
+		host TEXT PRIMARY KEY,
+		tat INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS paper_authors (
+		paper_id TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		name TEXT,
+		affiliation TEXT,
+		PRIMARY KEY (paper_id, position)
 	);
 
-	CREATE INDEX IF NOT EXISTS idx_citations_to_id ON citations(to_id);
+	CREATE TABLE IF NOT EXISTS metadata_history (
+		paper_id TEXT NOT NULL,
+		field TEXT NOT NULL,
+		old_value TEXT,
+		new_value TEXT,
+		changed TEXT NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_metadata_history_paper_id ON metadata_history(paper_id);
+
+	CREATE TABLE IF NOT EXISTS announcements (
+		paper_id TEXT NOT NULL,
+		category TEXT NOT NULL,
+		day TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		PRIMARY KEY (category, day, paper_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS watches (
+		id INTEGER PRIMARY KEY,
+		query TEXT NOT NULL,
+		categories TEXT,
+		authors TEXT,
+		enqueue INTEGER DEFAULT 0,
+		created TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS alerts (
+		watch_id INTEGER NOT NULL,
+		paper_id TEXT NOT NULL,
+		created TEXT,
+		read INTEGER DEFAULT 0,
+		PRIMARY KEY (watch_id, paper_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS download_policies (
+		id INTEGER PRIMARY KEY,
+		name TEXT,
+		categories TEXT,
+		authors TEXT,
+		licenses TEXT,
+		max_age INTEGER DEFAULT 0,
+		max_size INTEGER DEFAULT 0,
+		kind TEXT NOT NULL,
+		created TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS fetch_failures (
+		paper_id TEXT PRIMARY KEY,
+		reason TEXT NOT NULL,
+		last_attempt TEXT,
+		attempts INTEGER DEFAULT 0
+	);
 	`
-	_, err := c.db.Exec(schema)
-	return err
+	if _, err := c.db.Exec(schema); err != nil {
+		return err
+	}
+	return c.migrate()
+}
+
+// columnMigrations lists columns added to existing tables after their
+// CREATE TABLE statements were first released.
+var columnMigrations = []struct {
+	table, column, decl string
+}{
+	{"papers", "primary_category", "TEXT"},
+	{"papers", "version", "INTEGER DEFAULT 0"},
+	{"download_queue", "max_size", "INTEGER DEFAULT 0"},
+	{"paper_references", "confidence", "REAL NOT NULL DEFAULT 1"},
+	{"paper_references", "resolved_by", "TEXT NOT NULL DEFAULT ''"},
+	{"paper_references", "mentions", "INTEGER NOT NULL DEFAULT 0"},
+	{"paper_references", "weight", "REAL NOT NULL DEFAULT 1"},
+	{"pack_entries", "file", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// migrate adds any missing columns and converts the citations table. SQLite
+// has no ADD COLUMN IF NOT EXISTS, so each column is checked first; a
+// concurrent process may still win the race, in which case the ALTER fails
+// but the column exists.
+func (c *Cache) migrate() error {
+	for _, m := range columnMigrations {
+		ok, err := c.hasColumn(m.table, m.column)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+		_, err = c.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", m.table, m.column, m.decl))
+		if err != nil {
+			if ok, _ := c.hasColumn(m.table, m.column); !ok {
+				return fmt.Errorf("add %s.%s: %w", m.table, m.column, err)
+			}
+		}
+	}
+	return c.migrateCitations()
+}
+
+func (c *Cache) hasColumn(table, column string) (bool, error) {
+	rows, err := c.db.Query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
 }
 
 // Stats returns cache statistics.
@@ -152,6 +442,12 @@ func (c *Cache) Stats(ctx context.Context) (*CacheStats, error) {
 		return nil, err
 	}
 
+	dedup, err := c.DedupStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats.Dedup = *dedup
+
 	return stats, nil
 }
 
@@ -161,4 +457,5 @@ type CacheStats struct {
 	PDFsDownloaded    int64
 	SourcesDownloaded int64
 	QueuedDownloads   int64
+	Dedup             DedupStats
 }
@@ -2,41 +2,92 @@ package arxiv
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
 	"time"
 )
 
-// UpdateCitations extracts references from a paper's source and stores citation edges.
-// This should be called after downloading source files.
+// UpdateCitations extracts the bibliography and citation contexts of a
+// paper's source and stores its references, and with them its citation
+// edges. This should be called after downloading source files.
 func (c *Cache) UpdateCitations(ctx context.Context, paperID, srcPath string) error {
 	if srcPath == "" {
 		return nil
 	}
+	fsys := os.DirFS(srcPath)
+	return c.storeReferences(ctx, paperID,
+		extractBibliography(ctx, fsys, c.textExtractor), extractCitationContexts(fsys))
+}
 
-	refs := ExtractReferences(srcPath)
-	if len(refs) == 0 {
-		return nil
+// indexPaper extracts the bibliography and citation contexts of a paper
+// and stores its references, recording what they were extracted from so
+// that RebuildCitations can skip the paper until it changes. It returns an
+// error satisfying errors.Is(err, fs.ErrNotExist) if neither the paper's
+// source nor its PDF has been downloaded.
+func (c *Cache) indexPaper(ctx context.Context, paperID string) error {
+	hash, err := c.sourceHash(ctx, c.db, paperID)
+	if err != nil {
+		return err
 	}
-
-	// Delete existing citations from this paper (in case of re-index)
-	_, err := c.db.ExecContext(ctx, "DELETE FROM citations WHERE from_id = ?", paperID)
+	refs, contexts, err := c.extractPaper(ctx, c.db, paperID)
 	if err != nil {
 		return err
 	}
+	if err := c.storeReferences(ctx, paperID, refs, contexts); err != nil {
+		return err
+	}
+	return recordIndex(ctx, c.db, paperID, hash)
+}
 
-	// Insert new citations
-	for _, refID := range refs {
-		if refID == paperID {
-			continue // Skip self-citations
+// extractPaper extracts the bibliography and citation contexts of a
+// paper's source in the blob store. A paper without source, or whose
+// source gives no references, has its downloaded PDF scanned for arXiv
+// IDs instead.
+func (c *Cache) extractPaper(ctx context.Context, db dbtx, paperID string) ([]BibReference, []CitationContext, error) {
+	var refs []BibReference
+	var contexts []CitationContext
+	fsys, err := c.sourceFS(ctx, db, paperID)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, err
+	}
+	hasSource := err == nil
+	if hasSource {
+		refs = extractBibliography(ctx, fsys, c.textExtractor)
+		contexts = extractCitationContexts(fsys)
+	}
+
+	if len(refs) == 0 {
+		ids, err := c.pdfReferences(ctx, paperID)
+		if errors.Is(err, fs.ErrNotExist) && !hasSource {
+			return nil, nil, err
 		}
-		_, err := c.db.ExecContext(ctx,
-			"INSERT OR IGNORE INTO citations (from_id, to_id) VALUES (?, ?)",
-			paperID, refID)
-		if err != nil {
-			return err
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, err
+		}
+		for _, id := range ids {
+			refs = append(refs, BibReference{ArxivID: id})
 		}
 	}
+	return refs, contexts, nil
+}
 
-	return nil
+// pdfReferences returns the arXiv IDs cited in a paper's downloaded PDF.
+func (c *Cache) pdfReferences(ctx context.Context, paperID string) ([]string, error) {
+	f, _, err := c.OpenPDF(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	text, err := c.textExtractor.PDFText(ctx, f)
+	if err != nil {
+		return nil, fmt.Errorf("pdf text: %w", err)
+	}
+	seen := map[string]bool{normalizeArxivID(paperID): true}
+	return arxivIDsInText(text, seen), nil
 }
 
 // CitedByCount returns the number of cached papers that cite this paper.
@@ -47,10 +98,43 @@ func (c *Cache) CitedByCount(ctx context.Context, paperID string) (int, error) {
 	return count, err
 }
 
-// CitedBy returns papers that cite this paper (only cached papers with metadata).
+// CitedByCounts returns CitedByCount for each of the given papers in one
+// query. Papers nobody cites are left out of the map.
+func (c *Cache) CitedByCounts(ctx context.Context, ids []string) (map[string]int, error) {
+	counts := make(map[string]int)
+	if len(ids) == 0 {
+		return counts, nil
+	}
+	// Counting paper_references directly is several times faster than
+	// grouping the citations view first; the count is the same.
+	idList, _ := json.Marshal(ids)
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT arxiv_id, COUNT(DISTINCT paper_id) FROM paper_references
+		WHERE arxiv_id IN (SELECT value FROM json_each(?)) AND arxiv_id != paper_id
+		GROUP BY arxiv_id
+	`, string(idList))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, err
+		}
+		counts[id] = count
+	}
+	return counts, rows.Err()
+}
+
+// CitedBy returns papers that cite this paper (only cached papers with metadata),
+// those relying on it most first.
 type CitingPaper struct {
-	ID    string
-	Title string
+	ID          string
+	Title       string
+	Weight      float64 // How much the citing paper relies on this one
+	Influential bool
 }
 
 func (c *Cache) CitedBy(ctx context.Context, paperID string, limit int) ([]CitingPaper, error) {
@@ -59,11 +143,11 @@ func (c *Cache) CitedBy(ctx context.Context, paperID string, limit int) ([]Citin
 	}
 
 	rows, err := c.db.QueryContext(ctx, `
-		SELECT p.id, p.title
+		SELECT p.id, p.title, c.weight, c.influential
 		FROM citations c
 		JOIN papers p ON c.from_id = p.id
 		WHERE c.to_id = ?
-		ORDER BY p.created DESC
+		ORDER BY c.weight DESC, p.created DESC
 		LIMIT ?
 	`, paperID, limit)
 	if err != nil {
@@ -74,7 +158,7 @@ func (c *Cache) CitedBy(ctx context.Context, paperID string, limit int) ([]Citin
 	var papers []CitingPaper
 	for rows.Next() {
 		var p CitingPaper
-		if err := rows.Scan(&p.ID, &p.Title); err != nil {
+		if err := rows.Scan(&p.ID, &p.Title, &p.Weight, &p.Influential); err != nil {
 			return nil, err
 		}
 		papers = append(papers, p)
@@ -85,21 +169,28 @@ func (c *Cache) CitedBy(ctx context.Context, paperID string, limit int) ([]Citin
 
 // Reference represents a paper that is cited.
 type Reference struct {
-	ID        string
-	Title     string
-	HasTitle  bool // True if we have metadata (title available)
-	HasSource bool // True if we have source downloaded
+	ID         string
+	Title      string
+	HasTitle   bool    // True if we have metadata (title available)
+	HasSource  bool    // True if we have source downloaded
+	Confidence float64 // 1 unless the reference was matched by DOI or title
+
+	// Weight is how much the paper relies on the reference: its mentions
+	// in the text, scaled by section (see InfluentialWeight)
+	Weight      float64
+	Influential bool
 }
 
 func (c *Cache) References(ctx context.Context, paperID string) ([]Reference, error) {
 	rows, err := c.db.QueryContext(ctx, `
 		SELECT c.to_id, COALESCE(p.title, ''),
 		       CASE WHEN p.id IS NOT NULL AND p.title != '' THEN 1 ELSE 0 END,
-		       CASE WHEN p.src_downloaded = 1 THEN 1 ELSE 0 END
+		       CASE WHEN p.src_downloaded = 1 THEN 1 ELSE 0 END,
+		       c.confidence, c.weight, c.influential
 		FROM citations c
 		LEFT JOIN papers p ON c.to_id = p.id
 		WHERE c.from_id = ?
-		ORDER BY c.to_id DESC
+		ORDER BY c.weight DESC, c.to_id DESC
 	`, paperID)
 	if err != nil {
 		return nil, err
@@ -110,7 +201,7 @@ func (c *Cache) References(ctx context.Context, paperID string) ([]Reference, er
 	for rows.Next() {
 		var r Reference
 		var hasTitle, hasSource int
-		if err := rows.Scan(&r.ID, &r.Title, &hasTitle, &hasSource); err != nil {
+		if err := rows.Scan(&r.ID, &r.Title, &hasTitle, &hasSource, &r.Confidence, &r.Weight, &r.Influential); err != nil {
 			return nil, err
 		}
 		r.HasTitle = hasTitle == 1
@@ -121,60 +212,19 @@ func (c *Cache) References(ctx context.Context, paperID string) ([]Reference, er
 	return refs, rows.Err()
 }
 
-// UncachedReferenceCount returns the number of references without metadata.
+// UncachedReferenceCount returns the number of references without metadata,
+// not counting IDs that arXiv is known not to have.
 func (c *Cache) UncachedReferenceCount(ctx context.Context, paperID string) (int, error) {
 	var count int
 	err := c.db.QueryRowContext(ctx, `
 		SELECT COUNT(*) FROM citations c
 		LEFT JOIN papers p ON c.to_id = p.id
 		WHERE c.from_id = ? AND (p.id IS NULL OR p.title = '')
+		  AND c.to_id NOT IN (SELECT paper_id FROM fetch_failures)
 	`, paperID).Scan(&count)
 	return count, err
 }
 
-// RebuildAllCitations rebuilds the citations table by re-extracting references from all papers.
-func (c *Cache) RebuildAllCitations(ctx context.Context) error {
-	// Clear existing citations
-	_, err := c.db.ExecContext(ctx, "DELETE FROM citations")
-	if err != nil {
-		return err
-	}
-
-	// Get all papers with source downloaded
-	rows, err := c.db.QueryContext(ctx, `
-		SELECT id, src_path FROM papers WHERE src_downloaded = 1 AND src_path IS NOT NULL
-	`)
-	if err != nil {
-		return err
-	}
-	defer rows.Close()
-
-	type paper struct {
-		id      string
-		srcPath string
-	}
-	var papers []paper
-	for rows.Next() {
-		var p paper
-		if err := rows.Scan(&p.id, &p.srcPath); err != nil {
-			return err
-		}
-		papers = append(papers, p)
-	}
-	if err := rows.Err(); err != nil {
-		return err
-	}
-
-	// Extract and store citations for each paper
-	for _, p := range papers {
-		if err := c.UpdateCitations(ctx, p.id, p.srcPath); err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
 // GetPaperWithCitations returns a paper along with its citation count.
 func (c *Cache) GetPaperWithCitations(ctx context.Context, id string) (*Paper, int, error) {
 	paper, err := c.GetPaper(ctx, id)
@@ -190,19 +240,22 @@ func (c *Cache) GetPaperWithCitations(ctx context.Context, id string) (*Paper, i
 	return paper, count, nil
 }
 
-// scanPaper is a helper to scan paper rows (used internally).
+// scanPaperRow scans a paper from a row selecting, after the columns
+// scanned into extra, id, created, updated, title, abstract, authors,
+// categories, comments, journal_ref, doi, license, primary_category (with
+// NULL as the empty string), pdf_downloaded and src_downloaded.
 func scanPaperRow(row interface {
 	Scan(dest ...any) error
-}) (*Paper, error) {
+}, extra ...any) (*Paper, error) {
 	var p Paper
 	var created, updated string
 	var pdfDl, srcDl int
 
-	err := row.Scan(
+	err := row.Scan(append(extra,
 		&p.ID, &created, &updated, &p.Title, &p.Abstract, &p.Authors,
 		&p.Categories, &p.Comments, &p.JournalRef, &p.DOI, &p.License,
-		&pdfDl, &srcDl,
-	)
+		&p.Primary, &pdfDl, &srcDl,
+	)...)
 	if err != nil {
 		return nil, err
 	}
@@ -223,12 +276,17 @@ type GraphNode struct {
 	Year      int    `json:"year"`
 	Citations int    `json:"citations"` // How many papers cite this one
 	Cached    bool   `json:"cached"`
+
+	Categories string `json:"categories,omitempty"` // Space-separated, if cached
+	Depth      int    `json:"depth"`                // Citation hops from the center
 }
 
 // GraphEdge represents an edge in the citation graph.
 type GraphEdge struct {
-	Source string `json:"source"`
-	Target string `json:"target"`
+	Source      string  `json:"source"`
+	Target      string  `json:"target"`
+	Weight      float64 `json:"weight"`
+	Influential bool    `json:"influential"`
 }
 
 // CitationGraph represents a citation graph for visualization.
@@ -247,219 +305,148 @@ type PaperListItem struct {
 	Cached    bool   `json:"cached"`
 	IsRef     bool   `json:"isRef"`    // True if this paper is a reference
 	IsCiting  bool   `json:"isCiting"` // True if this paper cites the main paper
+
+	// Weight and Influential describe the citation edge to or from the
+	// main paper; items are sorted by weight
+	Weight      float64 `json:"weight"`
+	Influential bool    `json:"influential"`
 }
 
-// GetCitationGraph returns a citation graph centered on the given paper.
-// Includes: the paper itself, its references, papers that cite it,
-// and edges between references if they cite each other.
+// GetCitationGraph returns a citation graph centered on the given paper:
+// the paper itself, its references, papers that cite it, and the edges
+// between them. See GetCitationGraphWithOptions to explore further.
 func (c *Cache) GetCitationGraph(ctx context.Context, paperID string) (*CitationGraph, error) {
-	graph := &CitationGraph{
-		Nodes: []GraphNode{},
-		Edges: []GraphEdge{},
-	}
-
-	nodeSet := make(map[string]GraphNode)
-	edgeSet := make(map[string]bool)
+	return c.GetCitationGraphWithOptions(ctx, paperID, nil)
+}
 
-	addNode := func(id string, node GraphNode) {
-		if _, exists := nodeSet[id]; !exists {
-			nodeSet[id] = node
-		}
+// GetPaperList returns a combined list of references and citing papers for the sidebar.
+func (c *Cache) GetPaperList(ctx context.Context, paperID string) ([]PaperListItem, error) {
+	refs, err := c.References(ctx, paperID)
+	if err != nil {
+		return nil, err
 	}
-
-	addEdge := func(from, to string) {
-		key := from + "->" + to
-		if !edgeSet[key] {
-			edgeSet[key] = true
-			graph.Edges = append(graph.Edges, GraphEdge{Source: from, Target: to})
-		}
+	citedBy, err := c.CitedBy(ctx, paperID, 100)
+	if err != nil {
+		return nil, err
 	}
-
-	// Helper to extract year from arXiv ID (YYMM.NNNNN -> 20YY)
-	yearFromID := func(id string) int {
-		if len(id) >= 2 {
-			yy := id[:2]
-			if yy >= "00" && yy <= "99" {
-				year := 2000 + int(yy[0]-'0')*10 + int(yy[1]-'0')
-				if year > 2090 {
-					year -= 100 // 91-99 -> 1991-1999
-				}
-				return year
-			}
-		}
-		return 0
+	if len(refs) == 0 && len(citedBy) == 0 {
+		return nil, nil
 	}
 
-	// Get the central paper
-	paper, err := c.GetPaper(ctx, paperID)
-	if err != nil {
-		return nil, err
+	var ids []string
+	for _, ref := range refs {
+		ids = append(ids, ref.ID)
 	}
-	citedByCount, _ := c.CitedByCount(ctx, paperID)
-	addNode(paper.ID, GraphNode{
-		ID:        paper.ID,
-		Title:     paper.Title,
-		Authors:   paper.Authors,
-		Year:      paper.Created.Year(),
-		Citations: citedByCount,
-		Cached:    true,
-	})
-
-	// Get references (papers this one cites)
-	refs, err := c.References(ctx, paperID)
+	for _, citing := range citedBy {
+		ids = append(ids, citing.ID)
+	}
+	nodes, err := c.graphNodes(ctx, ids)
 	if err != nil {
 		return nil, err
 	}
-	refIDs := make(map[string]bool)
+
+	items := make([]PaperListItem, 0, len(ids))
 	for _, ref := range refs {
-		title := ref.Title
-		if title == "" {
-			title = ref.ID
-		}
-		year := yearFromID(ref.ID)
-		citations, _ := c.CitedByCount(ctx, ref.ID)
-		// Get authors if we have metadata
-		var authors string
-		if ref.HasTitle {
-			if p, err := c.GetPaper(ctx, ref.ID); err == nil {
-				authors = p.Authors
-				year = p.Created.Year()
-			}
-		}
-		addNode(ref.ID, GraphNode{
+		n := nodes[ref.ID]
+		items = append(items, PaperListItem{
 			ID:        ref.ID,
-			Title:     title,
-			Authors:   authors,
-			Year:      year,
-			Citations: citations,
-			Cached:    ref.HasTitle,
-		})
-		addEdge(paperID, ref.ID)
-		refIDs[ref.ID] = true
-	}
+			Title:     n.Title,
+			Authors:   n.Authors,
+			Year:      n.Year,
+			Citations: n.Citations,
+			Cached:    n.Cached,
+			IsRef:     true,
 
-	// Get citing papers (papers that cite this one)
-	citedBy, err := c.CitedBy(ctx, paperID, 100)
-	if err != nil {
-		return nil, err
+			Weight:      ref.Weight,
+			Influential: ref.Influential,
+		})
 	}
 	for _, citing := range citedBy {
-		citations, _ := c.CitedByCount(ctx, citing.ID)
-		var authors string
-		var year int
-		if p, err := c.GetPaper(ctx, citing.ID); err == nil {
-			authors = p.Authors
-			year = p.Created.Year()
-		}
-		addNode(citing.ID, GraphNode{
+		n := nodes[citing.ID]
+		items = append(items, PaperListItem{
 			ID:        citing.ID,
-			Title:     citing.Title,
-			Authors:   authors,
-			Year:      year,
-			Citations: citations,
-			Cached:    true,
-		})
-		addEdge(citing.ID, paperID)
-	}
-
-	// Find edges between references (if they cite each other)
-	if len(refIDs) > 0 {
-		rows, err := c.db.QueryContext(ctx, `
-			SELECT from_id, to_id FROM citations
-			WHERE from_id IN (SELECT to_id FROM citations WHERE from_id = ?)
-			  AND to_id IN (SELECT to_id FROM citations WHERE from_id = ?)
-		`, paperID, paperID)
-		if err == nil {
-			defer rows.Close()
-			for rows.Next() {
-				var from, to string
-				if err := rows.Scan(&from, &to); err == nil {
-					addEdge(from, to)
-				}
-			}
-		}
-	}
+			Title:     n.Title,
+			Authors:   n.Authors,
+			Year:      n.Year,
+			Citations: n.Citations,
+			Cached:    n.Cached,
+			IsCiting:  true,
 
-	// Convert node map to slice
-	for _, node := range nodeSet {
-		graph.Nodes = append(graph.Nodes, node)
+			Weight:      citing.Weight,
+			Influential: citing.Influential,
+		})
 	}
 
-	return graph, nil
+	return items, nil
 }
 
-// GetPaperList returns a combined list of references and citing papers for the sidebar.
-func (c *Cache) GetPaperList(ctx context.Context, paperID string) ([]PaperListItem, error) {
-	var items []PaperListItem
-
-	// Helper to extract year from arXiv ID
-	yearFromID := func(id string) int {
-		if len(id) >= 2 {
-			yy := id[:2]
-			if yy >= "00" && yy <= "99" {
-				year := 2000 + int(yy[0]-'0')*10 + int(yy[1]-'0')
-				if year > 2090 {
-					year -= 100
-				}
-				return year
-			}
-		}
-		return 0
+// graphNodes returns the graph node of each of the given papers, reading
+// their metadata and citation counts with one query each. Papers without
+// metadata are titled by their ID and dated by its year.
+func (c *Cache) graphNodes(ctx context.Context, ids []string) (map[string]GraphNode, error) {
+	nodes := make(map[string]GraphNode, len(ids))
+	for _, id := range ids {
+		nodes[id] = GraphNode{ID: id, Title: id, Year: yearFromID(id)}
 	}
+	idList, _ := json.Marshal(ids)
 
-	// Get references
-	refs, err := c.References(ctx, paperID)
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT id, title, COALESCE(authors, ''), COALESCE(categories, ''), COALESCE(created, '')
+		FROM papers WHERE id IN (SELECT value FROM json_each(?)) AND title != ''
+	`, string(idList))
 	if err != nil {
 		return nil, err
 	}
-	for _, ref := range refs {
-		title := ref.Title
-		if title == "" {
-			title = ref.ID
+	defer rows.Close()
+	for rows.Next() {
+		var id, title, authors, categories, created string
+		if err := rows.Scan(&id, &title, &authors, &categories, &created); err != nil {
+			return nil, err
 		}
-		year := yearFromID(ref.ID)
-		citations, _ := c.CitedByCount(ctx, ref.ID)
-		var authors string
-		if ref.HasTitle {
-			if p, err := c.GetPaper(ctx, ref.ID); err == nil {
-				authors = p.Authors
-				year = p.Created.Year()
-			}
+		n := nodes[id]
+		n.Title, n.Authors, n.Categories, n.Cached = title, authors, categories, true
+		if year := atoiPrefix(created); year > 0 {
+			n.Year = year
 		}
-		items = append(items, PaperListItem{
-			ID:        ref.ID,
-			Title:     title,
-			Authors:   authors,
-			Year:      year,
-			Citations: citations,
-			Cached:    ref.HasTitle,
-			IsRef:     true,
-		})
+		nodes[id] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	// Get citing papers
-	citedBy, err := c.CitedBy(ctx, paperID, 100)
+	counts, err := c.CitedByCounts(ctx, ids)
 	if err != nil {
 		return nil, err
 	}
-	for _, citing := range citedBy {
-		citations, _ := c.CitedByCount(ctx, citing.ID)
-		var authors string
-		var year int
-		if p, err := c.GetPaper(ctx, citing.ID); err == nil {
-			authors = p.Authors
-			year = p.Created.Year()
-		}
-		items = append(items, PaperListItem{
-			ID:        citing.ID,
-			Title:     citing.Title,
-			Authors:   authors,
-			Year:      year,
-			Citations: citations,
-			Cached:    true,
-			IsCiting:  true,
-		})
+	for id, count := range counts {
+		n := nodes[id]
+		n.Citations = count
+		nodes[id] = n
 	}
+	return nodes, nil
+}
 
-	return items, nil
+// inList returns the placeholders of an IN clause over the non-empty
+// list ids, and its arguments. Unlike json_each, a list of values lets
+// SQLite filter paper_references before grouping it into the citations
+// view.
+func inList(ids []string) (string, []any) {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return "?" + strings.Repeat(", ?", len(ids)-1), args
+}
+
+// yearFromID returns the year of an arXiv ID (YYMM.NNNNN -> 20YY), or 0
+// for old-style IDs such as hep-th/9901001.
+func yearFromID(id string) int {
+	if len(id) >= 2 && id[0] >= '0' && id[0] <= '9' && id[1] >= '0' && id[1] <= '9' {
+		year := 2000 + int(id[0]-'0')*10 + int(id[1]-'0')
+		if year > 2090 {
+			year -= 100 // 91-99 -> 1991-1999
+		}
+		return year
+	}
+	return 0
 }
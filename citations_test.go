@@ -0,0 +1,86 @@
+package arxiv
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// benchGraph returns a cache holding a synthetic citation graph of 10,000
+// papers with 100,000 edges, and the ID of a paper with 150 references and
+// about 100 citing papers.
+func benchGraph(b *testing.B) (*Cache, string) {
+	b.Helper()
+	ctx := context.Background()
+	c, err := Open(b.TempDir())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { c.Close() })
+
+	const papers = 10000
+	id := func(i int) string { return fmt.Sprintf("2301.%05d", i) }
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer tx.Rollback()
+	created := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	for i := range papers {
+		p := &Paper{
+			ID:      id(i),
+			Title:   "Paper " + id(i),
+			Authors: "A. Author, B. Author",
+			Created: created,
+			Updated: created,
+		}
+		if _, err := tx.ExecContext(ctx, upsertPaperSQL, paperArgs(p, "")...); err != nil {
+			b.Fatal(err)
+		}
+	}
+	// Each paper cites 10 papers spread over the ID range, paper 0 cites
+	// 150, and the first reference of papers 1 to 100 is paper 0
+	var rows [][]any
+	for i := range papers {
+		n := 10
+		if i == 0 {
+			n = 150
+		}
+		for j := 1; j <= n; j++ {
+			to := (i + j*7) % papers
+			if j == 1 && i > 0 && i <= 100 {
+				to = 0
+			}
+			rows = append(rows, []any{id(i), j, id(to), float64(j%4 + 1)})
+		}
+	}
+	err = insertRows(ctx, tx, "INSERT OR IGNORE INTO paper_references (paper_id, position, arxiv_id, weight)", rows)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		b.Fatal(err)
+	}
+	return c, id(0)
+}
+
+func BenchmarkGetCitationGraph(b *testing.B) {
+	c, center := benchGraph(b)
+	ctx := context.Background()
+	for b.Loop() {
+		if _, err := c.GetCitationGraph(ctx, center); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetPaperList(b *testing.B) {
+	c, center := benchGraph(b)
+	ctx := context.Background()
+	for b.Loop() {
+		if _, err := c.GetPaperList(ctx, center); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
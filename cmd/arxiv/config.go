@@ -0,0 +1,340 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tmc/arxiv"
+)
+
+// Profile holds settings that can be given in the config file. Empty fields
+// fall back to the top-level settings of the file and then to the built-in
+// defaults.
+type Profile struct {
+	Cache      string               `json:"cache,omitempty"`
+	Port       int                  `json:"port,omitempty"`
+	Set        string               `json:"set,omitempty"`
+	Categories []string             `json:"categories,omitempty"`
+	Download   string               `json:"download,omitempty"` // source, pdf or all
+	MaxAge     string               `json:"max_age,omitempty"`  // e.g., 30d
+	RateLimits map[string]rateLimit `json:"rate_limits,omitempty"`
+
+	// RemoteLicense lists the licenses whose PDFs and sources serve
+	// shares with remote clients, or "all"
+	RemoteLicense string `json:"remote_license,omitempty"`
+
+	// PDFText selects how PDFs are read for references: go (built in)
+	// or pdftotext (faster, needs poppler-utils installed)
+	PDFText string `json:"pdf_text,omitempty"`
+
+	APIURL      string `json:"api_url,omitempty"`
+	OAIURL      string `json:"oai_url,omitempty"`
+	DownloadURL string `json:"download_url,omitempty"`
+
+	S3 *s3Config `json:"s3,omitempty"`
+
+	// Policies are download policies applied along with those added by
+	// arxiv policy add
+	Policies []policyConfig `json:"policies,omitempty"`
+}
+
+type rateLimit struct {
+	Interval string `json:"interval"`
+	Burst    int    `json:"burst,omitempty"`
+}
+
+type policyConfig struct {
+	Name       string   `json:"name,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+	Authors    []string `json:"authors,omitempty"`
+	Licenses   []string `json:"licenses,omitempty"` // e.g., permissive, cc-by
+	MaxAge     string   `json:"max_age,omitempty"`  // e.g., 7d
+	MaxSize    string   `json:"max_size,omitempty"` // e.g., 50MB
+	Kind       string   `json:"kind,omitempty"`     // source, pdf or all
+}
+
+// newPolicy converts a configured policy to a DownloadPolicy.
+func newPolicy(pc policyConfig) (*arxiv.DownloadPolicy, error) {
+	p := &arxiv.DownloadPolicy{
+		Name:       pc.Name,
+		Categories: pc.Categories,
+		Authors:    pc.Authors,
+		Kind:       pc.Kind,
+	}
+	var err error
+	if p.Licenses, err = arxiv.ParseLicenseFilter(strings.Join(pc.Licenses, ",")); err != nil {
+		return nil, fmt.Errorf("policy %s: %v", pc.Name, err)
+	}
+	if pc.MaxAge != "" {
+		if p.MaxAge, err = parseAge(pc.MaxAge); err != nil {
+			return nil, fmt.Errorf("policy %s: %v", pc.Name, err)
+		}
+	}
+	if pc.MaxSize != "" {
+		if p.MaxSize, err = parseSize(pc.MaxSize); err != nil {
+			return nil, fmt.Errorf("policy %s: %v", pc.Name, err)
+		}
+	}
+	return p, nil
+}
+
+type s3Config struct {
+	Bucket   string `json:"bucket,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Prefix   string `json:"prefix,omitempty"`
+	Region   string `json:"region,omitempty"`
+}
+
+// configFile is the layout of config.json: top-level settings shared by all
+// profiles, plus named profiles that override them.
+type configFile struct {
+	Profile
+	DefaultProfile string             `json:"default_profile,omitempty"`
+	Profiles       map[string]Profile `json:"profiles,omitempty"`
+}
+
+// settings are the effective settings for this invocation. Command flags
+// use them as their defaults, so flags override everything else.
+var settings = defaultSettings()
+
+// settingsSource describes where the settings came from, for config show.
+var settingsSource struct {
+	Path    string
+	Profile string
+}
+
+func defaultSettings() Profile {
+	home, _ := os.UserHomeDir()
+	return Profile{
+		Cache:    filepath.Join(home, ".cache", "arxiv"),
+		Port:     8080,
+		Download: "source",
+
+		RemoteLicense: "permissive",
+	}
+}
+
+// configPath returns the config file location: ARXIV_CONFIG, or
+// arxiv/config.json in the user config directory (~/.config on Linux).
+func configPath() string {
+	if path := os.Getenv("ARXIV_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "arxiv", "config.json")
+}
+
+// loadSettings computes the effective settings: built-in defaults,
+// overridden by the config file (top level, then the selected profile),
+// overridden by the environment. A missing config file is not an error
+// unless a profile was asked for.
+func loadSettings(profile string) error {
+	path := configPath()
+	settingsSource.Path = path
+	if profile == "" {
+		profile = os.Getenv("ARXIV_PROFILE")
+	}
+
+	var file configFile
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist) || path == "":
+		if profile != "" {
+			return fmt.Errorf("profile %q: no config file at %s", profile, path)
+		}
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(data, &file); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	settings.merge(file.Profile)
+	if profile == "" {
+		profile = file.DefaultProfile
+	}
+	if profile != "" {
+		p, ok := file.Profiles[profile]
+		if !ok {
+			return fmt.Errorf("%s: no profile %q", path, profile)
+		}
+		settings.merge(p)
+	}
+	settingsSource.Profile = profile
+
+	settings.merge(Profile{Cache: os.Getenv("ARXIV_CACHE")})
+	if bucket := os.Getenv("ARXIV_S3_BUCKET"); bucket != "" {
+		settings.S3 = &s3Config{
+			Bucket:   bucket,
+			Endpoint: os.Getenv("ARXIV_S3_ENDPOINT"),
+			Prefix:   os.Getenv("ARXIV_S3_PREFIX"),
+		}
+	}
+	if settings.S3 != nil && os.Getenv("AWS_REGION") != "" {
+		settings.S3.Region = os.Getenv("AWS_REGION")
+	}
+
+	if rest, ok := strings.CutPrefix(settings.Cache, "~/"); ok {
+		home, _ := os.UserHomeDir()
+		settings.Cache = filepath.Join(home, rest)
+	}
+	return settings.validate()
+}
+
+// merge overrides p's fields with the non-empty fields of o.
+func (p *Profile) merge(o Profile) {
+	if o.Cache != "" {
+		p.Cache = o.Cache
+	}
+	if o.Port != 0 {
+		p.Port = o.Port
+	}
+	if o.Set != "" {
+		p.Set = o.Set
+	}
+	if o.Categories != nil {
+		p.Categories = o.Categories
+	}
+	if o.Download != "" {
+		p.Download = o.Download
+	}
+	if o.MaxAge != "" {
+		p.MaxAge = o.MaxAge
+	}
+	if o.RemoteLicense != "" {
+		p.RemoteLicense = o.RemoteLicense
+	}
+	if o.PDFText != "" {
+		p.PDFText = o.PDFText
+	}
+	for host, rl := range o.RateLimits {
+		if p.RateLimits == nil {
+			p.RateLimits = make(map[string]rateLimit)
+		}
+		p.RateLimits[host] = rl
+	}
+	if o.APIURL != "" {
+		p.APIURL = o.APIURL
+	}
+	if o.OAIURL != "" {
+		p.OAIURL = o.OAIURL
+	}
+	if o.DownloadURL != "" {
+		p.DownloadURL = o.DownloadURL
+	}
+	if o.S3 != nil {
+		p.S3 = o.S3
+	}
+	if o.Policies != nil {
+		p.Policies = o.Policies
+	}
+}
+
+func (p *Profile) validate() error {
+	switch p.Download {
+	case "source", "pdf", "all":
+	default:
+		return fmt.Errorf("invalid download %q (want source, pdf or all)", p.Download)
+	}
+	switch p.PDFText {
+	case "", "go", "pdftotext":
+	default:
+		return fmt.Errorf("invalid pdf_text %q (want go or pdftotext)", p.PDFText)
+	}
+	if _, err := p.maxAge(); err != nil {
+		return fmt.Errorf("invalid max_age: %v", err)
+	}
+	if _, err := p.rateLimits(); err != nil {
+		return err
+	}
+	if _, err := parseRemoteLicense(p.RemoteLicense); err != nil {
+		return fmt.Errorf("invalid remote_license: %v", err)
+	}
+	_, err := p.policies()
+	return err
+}
+
+func (p *Profile) policies() ([]arxiv.DownloadPolicy, error) {
+	var policies []arxiv.DownloadPolicy
+	for _, pc := range p.Policies {
+		dp, err := newPolicy(pc)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, *dp)
+	}
+	return policies, nil
+}
+
+func (p *Profile) maxAge() (time.Duration, error) {
+	if p.MaxAge == "" {
+		return 0, nil
+	}
+	return parseAge(p.MaxAge)
+}
+
+func (p *Profile) rateLimits() (map[string]arxiv.RateLimit, error) {
+	if len(p.RateLimits) == 0 {
+		return nil, nil
+	}
+	limits := make(map[string]arxiv.RateLimit, len(p.RateLimits))
+	for host, rl := range p.RateLimits {
+		interval, err := time.ParseDuration(rl.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit for %s: %v", host, err)
+		}
+		limits[host] = arxiv.RateLimit{Interval: interval, Burst: rl.Burst}
+	}
+	return limits, nil
+}
+
+// options returns the library options for the settings. The S3 store is
+// configured by openCache.
+func (p *Profile) options() *arxiv.Options {
+	// validate has already checked these
+	maxAge, _ := p.maxAge()
+	limits, _ := p.rateLimits()
+	policies, _ := p.policies()
+	var extractor arxiv.TextExtractor
+	if p.PDFText == "pdftotext" {
+		extractor = arxiv.Pdftotext{}
+	}
+	return &arxiv.Options{
+		TextExtractor: extractor,
+		Policies:      policies,
+		MaxAge:        maxAge,
+		RateLimits:    limits,
+		APIURL:        p.APIURL,
+		OAIURL:        p.OAIURL,
+		DownloadURL:   p.DownloadURL,
+	}
+}
+
+func cmdConfig(args []string) {
+	if len(args) == 0 || args[0] != "show" {
+		log.Fatal("usage: arxiv config show")
+	}
+	fs := flag.NewFlagSet("config show", flag.ExitOnError)
+	fs.Parse(args[1:])
+
+	out, err := json.MarshalIndent(struct {
+		Config   string  `json:"config"`
+		Profile  string  `json:"profile,omitempty"`
+		Settings Profile `json:"settings"`
+	}{settingsSource.Path, settingsSource.Profile, settings}, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(out))
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testConfig = `{
+	"cache": "/data/arxiv",
+	"download": "pdf",
+	"max_age": "30d",
+	"rate_limits": {"arxiv.org": {"interval": "5s"}, "export.arxiv.org": {"interval": "3s"}},
+	"default_profile": "laptop",
+	"profiles": {
+		"laptop": {"cache": "~/arxiv", "port": 9090},
+		"mirror": {
+			"download": "all",
+			"categories": ["cs.LG", "stat.ML"],
+			"rate_limits": {"arxiv.org": {"interval": "1s", "burst": 4}},
+			"remote_license": "all"
+		},
+		"broken": {"download": "everything"}
+	}
+}`
+
+func TestLoadSettings(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(testConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		config  string // ARXIV_CONFIG (default: the test config)
+		profile string // -profile flag
+		env     map[string]string
+		want    func(p *Profile) // Changes from the defaults
+		wantErr string
+	}{
+		{
+			name:   "no config file",
+			config: filepath.Join(dir, "missing.json"),
+			want:   func(p *Profile) {},
+		},
+		{
+			name: "default profile over top level",
+			want: func(p *Profile) {
+				p.Cache = filepath.Join(home, "arxiv")
+				p.Port = 9090
+				p.Download = "pdf"
+				p.MaxAge = "30d"
+				p.RateLimits = map[string]rateLimit{"arxiv.org": {Interval: "5s"}, "export.arxiv.org": {Interval: "3s"}}
+			},
+		},
+		{
+			name:    "selected profile",
+			profile: "mirror",
+			want: func(p *Profile) {
+				p.Cache = "/data/arxiv"
+				p.Download = "all"
+				p.MaxAge = "30d"
+				p.Categories = []string{"cs.LG", "stat.ML"}
+				p.RemoteLicense = "all"
+				// Hosts merge one by one
+				p.RateLimits = map[string]rateLimit{"arxiv.org": {Interval: "1s", Burst: 4}, "export.arxiv.org": {Interval: "3s"}}
+			},
+		},
+		{
+			name: "profile from the environment",
+			env:  map[string]string{"ARXIV_PROFILE": "mirror"},
+			want: func(p *Profile) {
+				p.Cache = "/data/arxiv"
+				p.Download = "all"
+				p.MaxAge = "30d"
+				p.Categories = []string{"cs.LG", "stat.ML"}
+				p.RemoteLicense = "all"
+				p.RateLimits = map[string]rateLimit{"arxiv.org": {Interval: "1s", Burst: 4}, "export.arxiv.org": {Interval: "3s"}}
+			},
+		},
+		{
+			name: "environment over profile",
+			env:  map[string]string{"ARXIV_CACHE": "/tmp/cache", "ARXIV_S3_BUCKET": "papers", "AWS_REGION": "eu-west-1"},
+			want: func(p *Profile) {
+				p.Cache = "/tmp/cache"
+				p.Port = 9090
+				p.Download = "pdf"
+				p.MaxAge = "30d"
+				p.RateLimits = map[string]rateLimit{"arxiv.org": {Interval: "5s"}, "export.arxiv.org": {Interval: "3s"}}
+				p.S3 = &s3Config{Bucket: "papers", Region: "eu-west-1"}
+			},
+		},
+		{
+			name:    "unknown profile",
+			profile: "desktop",
+			wantErr: `no profile "desktop"`,
+		},
+		{
+			name:    "profile without a config file",
+			config:  filepath.Join(dir, "missing.json"),
+			profile: "laptop",
+			wantErr: "no config file",
+		},
+		{
+			name:    "invalid profile",
+			profile: "broken",
+			wantErr: `invalid download "everything"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"ARXIV_PROFILE", "ARXIV_CACHE", "ARXIV_S3_BUCKET", "ARXIV_S3_ENDPOINT", "ARXIV_S3_PREFIX", "AWS_REGION"} {
+				t.Setenv(key, tt.env[key])
+			}
+			t.Setenv("ARXIV_CONFIG", path)
+			if tt.config != "" {
+				t.Setenv("ARXIV_CONFIG", tt.config)
+			}
+			settings = defaultSettings()
+			defer func() { settings = defaultSettings() }()
+
+			err := loadSettings(tt.profile)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadSettings error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := defaultSettings()
+			tt.want(&want)
+			if !reflect.DeepEqual(settings, want) {
+				t.Errorf("settings =\n%+v\nwant\n%+v", settings, want)
+			}
+		})
+	}
+}
+
+func TestProfileMerge(t *testing.T) {
+	base := Profile{
+		Cache:      "/cache",
+		Port:       8080,
+		Categories: []string{"cs.LG"},
+		RateLimits: map[string]rateLimit{"arxiv.org": {Interval: "3s"}},
+		Policies:   []policyConfig{{Name: "base"}},
+	}
+	tests := []struct {
+		name string
+		over Profile
+		want func(p *Profile)
+	}{
+		{
+			name: "empty",
+			want: func(p *Profile) {},
+		},
+		{
+			name: "scalars",
+			over: Profile{Port: 9090, Set: "cs"},
+			want: func(p *Profile) { p.Port, p.Set = 9090, "cs" },
+		},
+		{
+			name: "lists are replaced",
+			over: Profile{Categories: []string{"math"}, Policies: []policyConfig{{Name: "over"}}},
+			want: func(p *Profile) {
+				p.Categories = []string{"math"}
+				p.Policies = []policyConfig{{Name: "over"}}
+			},
+		},
+		{
+			name: "an empty list clears",
+			over: Profile{Categories: []string{}},
+			want: func(p *Profile) { p.Categories = []string{} },
+		},
+		{
+			name: "rate limits are merged",
+			over: Profile{RateLimits: map[string]rateLimit{"export.arxiv.org": {Interval: "1s"}}},
+			want: func(p *Profile) {
+				p.RateLimits = map[string]rateLimit{"arxiv.org": {Interval: "3s"}, "export.arxiv.org": {Interval: "1s"}}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := base
+			got.RateLimits = map[string]rateLimit{"arxiv.org": {Interval: "3s"}}
+			got.merge(tt.over)
+			want := base
+			want.RateLimits = map[string]rateLimit{"arxiv.org": {Interval: "3s"}}
+			tt.want(&want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("merge =\n%+v\nwant\n%+v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tmc/arxiv"
+)
+
+func cmdDaemon(ctx context.Context, cacheDir string, args []string) {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	syncEvery := fs.Duration("sync-every", 6*time.Hour, "Incremental metadata sync interval (0 = off)")
+	set := fs.String("set", settings.Set, "arXiv set to sync (e.g., cs, physics)")
+	queueEvery := fs.Duration("queue-every", 5*time.Minute, "Download queue interval (0 = off)")
+	queueLimit := fs.Int("queue-limit", 100, "Max queued downloads per run (0 = all)")
+	gcEvery := fs.Duration("gc-every", 24*time.Hour, "Garbage collection interval (0 = off)")
+	httpAddr := fs.String("http", "", "Also serve the web interface and /status on this address (e.g., :8080)")
+	logFormat := fs.String("log-format", "text", "Log format: text or json")
+	remoteLicense := fs.String("remote-license", settings.RemoteLicense,
+		"Licenses whose PDFs and sources -http serves to remote clients (comma-separated, or all)")
+	fs.Parse(args)
+
+	remote, err := parseRemoteLicense(*remoteLicense)
+	if err != nil {
+		log.Fatalf("invalid -remote-license: %v", err)
+	}
+
+	var handler slog.Handler
+	switch *logFormat {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, nil)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, nil)
+	default:
+		log.Fatalf("invalid -log-format: %s", *logFormat)
+	}
+	logger := slog.New(handler)
+	// Route the library's log.Printf output through the same handler
+	slog.SetDefault(logger)
+
+	cache, err := openCache(cacheDir)
+	if err != nil {
+		log.Fatalf("open cache: %v", err)
+	}
+	defer cache.Close()
+
+	d := &daemon{cache: cache, log: logger, started: time.Now()}
+	d.add("sync", *syncEvery, func(ctx context.Context) ([]any, error) {
+		// Saved searches are evaluated as papers are inserted
+		before := unreadAlerts(ctx, cache)
+		if err := cache.SyncMetadata(ctx, &arxiv.SyncOptions{Set: *set}); err != nil {
+			return nil, err
+		}
+		return []any{"new_alerts", unreadAlerts(ctx, cache) - before}, nil
+	})
+	d.add("queue", *queueEvery, func(ctx context.Context) ([]any, error) {
+		stats, err := cache.ProcessDownloadQueue(ctx, &arxiv.QueueOptions{
+			Limit: *queueLimit,
+			Progress: func(id string, err error) {
+				if err != nil {
+					logger.Warn("download failed", "paper", id, "err", err)
+				} else {
+					logger.Info("downloaded", "paper", id)
+				}
+			},
+		})
+		if stats == nil {
+			return nil, err
+		}
+		return []any{"downloaded", stats.Downloaded, "failed", stats.Failed, "skipped", stats.Skipped}, err
+	})
+	d.add("gc", *gcEvery, func(ctx context.Context) ([]any, error) {
+		stats, err := cache.GC(ctx)
+		if stats == nil {
+			return nil, err
+		}
+		return []any{
+			"objects", stats.Objects, "bytes", stats.ObjectBytes,
+			"fetch_failures", stats.FetchFailures, "queue_entries", stats.QueueEntries,
+		}, err
+	})
+	if len(d.jobs) == 0 && *httpAddr == "" {
+		log.Fatal("daemon: nothing to do (all jobs disabled and no -http)")
+	}
+
+	var httpServer *http.Server
+	if *httpAddr != "" {
+		srv := &server{cache: cache, cacheDir: cacheDir, remoteLicenses: remote}
+		mux := srv.routes()
+		mux.HandleFunc("/status", d.handleStatus)
+		httpServer = &http.Server{Addr: *httpAddr, Handler: mux}
+		go func() {
+			logger.Info("serving", "addr", *httpAddr)
+			if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+				logger.Error("server error", "err", err)
+			}
+		}()
+	}
+
+	logger.Info("daemon started", "cache", cacheDir, "jobs", len(d.jobs))
+	d.run(ctx)
+
+	if httpServer != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		httpServer.Shutdown(shutdownCtx)
+		cancel()
+	}
+	logger.Info("daemon stopped")
+}
+
+// unreadAlerts returns the number of unread alerts across all watches.
+func unreadAlerts(ctx context.Context, cache *arxiv.Cache) int {
+	watches, _ := cache.Watches(ctx)
+	n := 0
+	for _, w := range watches {
+		n += w.Unread
+	}
+	return n
+}
+
+// daemon runs periodic jobs against a cache, one at a time.
+type daemon struct {
+	cache   *arxiv.Cache
+	log     *slog.Logger
+	started time.Time
+
+	mu   sync.Mutex // guards job status
+	jobs []*job
+}
+
+// job is a periodic task; run returns slog key/value pairs describing its result.
+type job struct {
+	Name         string    `json:"name"`
+	Every        string    `json:"every"`
+	Running      bool      `json:"running"`
+	LastRun      time.Time `json:"last_run,omitzero"`
+	LastDuration string    `json:"last_duration,omitempty"`
+	LastError    string    `json:"last_error,omitempty"`
+	NextRun      time.Time `json:"next_run"`
+
+	every time.Duration
+	run   func(context.Context) ([]any, error)
+}
+
+func (d *daemon) add(name string, every time.Duration, run func(context.Context) ([]any, error)) {
+	if every <= 0 {
+		return
+	}
+	d.jobs = append(d.jobs, &job{
+		Name:    name,
+		Every:   every.String(),
+		NextRun: time.Now(),
+		every:   every,
+		run:     run,
+	})
+}
+
+// run executes due jobs until ctx is done.
+func (d *daemon) run(ctx context.Context) {
+	for {
+		d.mu.Lock()
+		var next *job
+		for _, j := range d.jobs {
+			if next == nil || j.NextRun.Before(next.NextRun) {
+				next = j
+			}
+		}
+		d.mu.Unlock()
+
+		if next == nil {
+			<-ctx.Done()
+			return
+		}
+
+		t := time.NewTimer(time.Until(next.NextRun))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+
+		d.runJob(ctx, next)
+	}
+}
+
+func (d *daemon) runJob(ctx context.Context, j *job) {
+	start := time.Now()
+	d.mu.Lock()
+	j.Running = true
+	d.mu.Unlock()
+	d.log.Info("job started", "job", j.Name)
+
+	attrs, err := j.run(ctx)
+
+	d.mu.Lock()
+	j.Running = false
+	j.LastRun = start
+	j.LastDuration = time.Since(start).Round(time.Millisecond).String()
+	j.LastError = ""
+	if err != nil {
+		j.LastError = err.Error()
+	}
+	j.NextRun = start.Add(j.every)
+	d.mu.Unlock()
+
+	attrs = append(attrs, "job", j.Name, "duration", j.LastDuration)
+	switch {
+	case ctx.Err() != nil:
+		d.log.Info("job interrupted", attrs...)
+	case err != nil:
+		d.log.Error("job failed", append(attrs, "err", err)...)
+	default:
+		d.log.Info("job finished", attrs...)
+	}
+}
+
+// handleStatus reports the daemon's jobs and cache statistics as JSON.
+func (d *daemon) handleStatus(w http.ResponseWriter, r *http.Request) {
+	stats, err := d.cache.Stats(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	d.mu.Lock()
+	jobs := make([]job, len(d.jobs))
+	for i, j := range d.jobs {
+		jobs[i] = *j
+	}
+	d.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"started": d.started,
+		"uptime":  fmt.Sprint(time.Since(d.started).Round(time.Second)),
+		"jobs":    jobs,
+		"cache":   stats,
+	})
+}
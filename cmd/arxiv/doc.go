@@ -6,22 +6,77 @@ including TeX source files, PDFs, and extracted citation graphs.
 
 # Usage
 
-	arxiv <command> [options]
+	arxiv [-profile name] <command> [options]
 
 # Commands
 
 	fetch      Fetch and download specific papers
 	sync       Sync paper metadata from arXiv OAI-PMH (bulk)
+	query      Import papers matching an arXiv API search query
+	import-bib Cache the arXiv papers cited by a .bib or .bbl file
+	refresh    Re-fetch stale paper metadata from arXiv
+	new        Show a category's daily new submissions
+	watch      Manage saved searches (add, list, rm)
+	alerts     Show papers matching saved searches
+	policy     Manage automatic download policies (add, list, rm)
 	stats      Show cache statistics
 	search     Search cached papers (full-text search)
 	get        Get a specific paper's info
 	ls         List cached papers (alias: list)
 	reindex    Rebuild search index and citations
+	graph      Rank papers and find related ones (compute, top, related)
+	dedup      Deduplicate cached source files
+	pack       Move cold source trees into per-month packs
+	unpack     Restore a paper's source tree from its pack
 	serve      Start web server to browse cached papers
+	daemon     Run sync, downloads and GC on a schedule
+	config     Show the effective configuration
 
 # Environment
 
-	ARXIV_CACHE    Cache directory (default: ~/.cache/arxiv)
+	ARXIV_CACHE        Cache directory (default: ~/.cache/arxiv)
+	ARXIV_S3_BUCKET    Store PDFs and sources in this S3-compatible bucket
+	ARXIV_S3_ENDPOINT  S3 endpoint URL (e.g., http://localhost:9000 for MinIO)
+	ARXIV_S3_PREFIX    Key prefix within the bucket
+	ARXIV_CONFIG       Config file (default: ~/.config/arxiv/config.json)
+	ARXIV_PROFILE      Config profile to use
+
+The S3 store reads credentials from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY,
+AWS_SESSION_TOKEN and AWS_REGION. The index always stays in ARXIV_CACHE.
+
+# Configuration
+
+Settings can also be kept in a JSON config file. Top-level settings apply to
+every profile; a named profile, selected with -profile before the command or
+with ARXIV_PROFILE, overrides them:
+
+	{
+	  "port": 9000,
+	  "default_profile": "laptop",
+	  "profiles": {
+	    "laptop": {"cache": "~/arxiv", "categories": ["cs.LG", "stat.ML"]},
+	    "work": {
+	      "cache": "/data/arxiv",
+	      "set": "cs",
+	      "download": "all",
+	      "max_age": "30d",
+	      "download_url": "https://arxiv-mirror.example.com",
+	      "rate_limits": {"arxiv-mirror.example.com": {"interval": "100ms", "burst": 10}},
+	      "s3": {"bucket": "papers", "endpoint": "http://localhost:9000"}
+	    }
+	  }
+	}
+
+Command-line flags override the environment, which overrides the config
+file, which overrides the built-in defaults. The port, set and download
+settings are the defaults for serve -port, sync and daemon -set, and fetch;
+categories are used by new when no category is given. api_url, oai_url and
+download_url point the cache at a mirror. pdf_text selects how PDFs are
+read for references: go, the built-in reader (the default), or pdftotext,
+which is faster but needs poppler-utils installed.
+
+	arxiv config show                # Print the effective settings
+	arxiv -profile work sync         # Use the work profile
 
 # Fetching Papers
 
@@ -32,8 +87,65 @@ Fetch downloads paper metadata, TeX source, and optionally PDF:
 	arxiv fetch -all 2301.00001         # Fetch paper + source + PDF
 	arxiv fetch 2301.00001 2302.12345   # Fetch multiple papers
 
-The fetch command also extracts citation references from TeX source files
-and stores them in the local database for graph visualization.
+The fetch command also parses the bibliography of the TeX source (.bbl
+files, or .bib files if there are none) and stores every reference with
+its authors, title, venue, year, DOI and arXiv ID. References to arXiv
+papers make up the citation graph. The sentence and section of each
+citation in the TeX text are recorded too, and shown under "Cited By" on
+the paper page. Each citation is weighted by its mentions, those in method
+and experiment sections counting double and those in related work half;
+citations weighing 3 or more are marked influential. The paper page sorts
+references and citing papers by weight and can show influential citations
+only.
+
+References that give no arXiv ID, such as those citing a journal version,
+are matched to cached papers by DOI, or else by title, first author and
+year; each match is stored with a confidence. Run 'arxiv reindex' after
+syncing new metadata to match references against the new papers.
+
+Papers without TeX source, or whose source has no bibliography, are
+indexed from their downloaded PDF instead: its text is scanned for arXiv
+IDs, which become references without contexts.
+
+'arxiv reindex' extracts references in parallel (-workers), skipping papers
+whose source and PDF are unchanged since they were last indexed (-force
+re-extracts them too), and writes the new graph in a single transaction:
+an interrupted reindex leaves the previous graph in place.
+
+# Citation Graph Analytics
+
+The graph command analyzes the whole citation graph. 'arxiv graph compute'
+ranks every paper with PageRank, overall and within each category, and
+finds each paper's related papers by co-citation (papers often cited
+together) and bibliographic coupling (papers sharing references):
+
+	arxiv graph compute                 # Recompute ranks and related papers
+	arxiv graph top -cat cs.LG -n 20    # Highest ranked cs.LG papers
+	arxiv graph related 2301.00001      # Papers related to one paper
+
+Ranks are scaled so that the average paper ranks 1, and citations count in
+proportion to their weight. References cited by more than 1000 papers, and
+papers with more than 1000 references, relate nearly everything and are
+passed over when relating papers (see -hub-limit). The results are stored in the cache and shown
+as "Related Papers" on the paper page; run 'arxiv graph compute' again
+after reindexing to bring them up to date.
+
+Requests to arXiv are paced to one every three seconds per host. The limit
+is shared by every command using the same cache, so a running sync, fetch
+and serve together stay within arXiv's usage policy.
+
+# Importing a Bibliography
+
+import-bib caches every arXiv paper cited by a .bib or .bbl file, or by all
+such files under a directory:
+
+	arxiv import-bib refs.bib
+	arxiv import-bib -queue source paper/      # Also queue the sources
+
+Entries are matched by the arXiv IDs they contain (eprint fields, arXiv
+URLs and arXiv DOIs), or else by a DOI that belongs to a cached paper.
+Metadata is fetched in batches, and a report lists the resolved and
+unresolved entries.
 
 # Listing Papers
 
@@ -44,6 +156,7 @@ List cached papers with various filters:
 	arxiv ls -src                       # Only papers with source downloaded
 	arxiv ls -n 50                      # Limit to 50 results
 	arxiv ls -a                         # Include metadata-only papers
+	arxiv ls -license permissive        # Only papers with permissive licenses
 
 # Searching
 
@@ -53,20 +166,100 @@ Full-text search across titles and abstracts:
 	arxiv search -category cs.CL "language model"
 	arxiv search -limit 50 "neural network"
 
+# Exporting
+
+get, ls and search print text by default. -format selects a structured
+output instead: json, jsonl, csv, bibtex (in the style of arXiv's own
+export, with eprint, archivePrefix and primaryClass), ris or csl-json:
+
+	arxiv get -format bibtex 1706.03762 >> refs.bib
+	arxiv ls -format jsonl cs.CL | jq -r .title
+	arxiv search -format csv -limit 100 "diffusion" > diffusion.csv
+	arxiv ls -license permissive -format csl-json > library.json
+
+# Licenses
+
+arXiv records each paper's license as a URL. It is normalized to one of
+CC-BY, CC-BY-SA, CC-BY-NC, CC-BY-NC-SA, CC-BY-ND, CC-BY-NC-ND, CC0, arXiv
+(the non-exclusive distribution license), other or none. CC-BY, CC-BY-SA
+and CC0 are permissive. Commands that take -license accept a
+comma-separated list of these names, and "permissive". Licenses come from
+OAI-PMH metadata (sync and new); the arXiv API used by fetch, query and
+refresh does not report them, so papers only fetched have none:
+
+	arxiv ls -license permissive        # Only redistributable papers
+	arxiv search -license cc-by,cc0 "diffusion"
+	arxiv fetch -license permissive 2301.00001
+	arxiv stats -by-license             # Paper counts per license
+
+The web server serves PDFs and sources to clients other than localhost only
+for papers with a license in -remote-license (default permissive; "all"
+disables the check), and answers 403 otherwise. The default can be set
+with remote_license in the config file. Behind a reverse proxy on the same
+host, all clients count as local.
+
+# Deduplicating Sources
+
+Many papers ship identical style files, bibliography styles and logos.
+The dedup command stores each distinct source file once under objects/
+and replaces the copies in each paper's source directory with hard links:
+
+	arxiv dedup                         # Convert the cache and enable dedup
+	arxiv dedup -off                    # Stop deduplicating new sources
+
+Once enabled, newly downloaded sources are deduplicated as they are
+extracted. The dedup ratio is reported by 'arxiv stats'.
+
+# Packing Sources
+
+Extracted source trees use many small files. The pack command moves the
+sources of older papers into one zip file per month under packs/, indexed
+so that single files can still be read, served and scanned for references
+without unpacking:
+
+	arxiv pack -older-than 180d         # Pack papers older than 180 days
+	arxiv unpack 2301.00001             # Restore one paper's source tree
+
 # Web Interface
 
 Start a local web server to browse papers with a citation graph visualization:
 
 	arxiv serve                         # Start on default port 8080
 	arxiv serve -port 3000              # Start on custom port
+	arxiv serve -remote-license all     # Serve all files to remote clients
 
 The web interface provides:
   - Full-text search with real-time results
-  - Paper detail pages with abstracts and metadata
+  - Paper detail pages with abstracts, metadata, bibliographies and related papers
   - Interactive D3.js citation graph visualization
   - Category and author browsing
+  - Daily new-submission listings (/new/{category})
+  - Saved-search alerts (/alerts)
   - Direct arXiv ID/URL input for fetching new papers
 
+The citation graph on a paper page can be expanded up to three hops from
+the paper, following references, citing papers or both, and capped at the
+most cited papers of each hop. Its JSON, at /paper/{id}/graph, takes the
+same settings as query parameters: depth, max (nodes, default 500),
+direction (references or citations), min_citations, categories
+(comma-separated) and from and to (years):
+
+	/paper/2301.00001/graph?depth=2&max=500&categories=cs.LG,stat.ML
+
+# Running as a Daemon
+
+The daemon command keeps the cache open and runs incremental metadata
+syncs (which also evaluate saved searches), queued downloads and garbage
+collection on a schedule, optionally serving the web interface too:
+
+	arxiv daemon -set cs                # Sync cs every 6h, downloads every 5m
+	arxiv daemon -sync-every 1h -http :8080 -log-format json
+
+Logs are structured (text or JSON) on standard error. With -http, GET
+/status reports each job's last run, duration, error and next run, plus
+cache statistics. Interrupting the daemon stops the running job and shuts
+the server down cleanly.
+
 # Syncing Metadata
 
 Bulk sync paper metadata from arXiv's OAI-PMH API:
@@ -78,6 +271,76 @@ Bulk sync paper metadata from arXiv's OAI-PMH API:
 Note: This downloads metadata only, not source files or PDFs.
 Use 'arxiv fetch' to download individual papers with full content.
 
+# Querying arXiv
+
+Import the metadata of papers matching an arXiv API search query, to seed
+a cache around a topic without a full OAI-PMH harvest:
+
+	arxiv query 'cat:cs.LG AND ti:diffusion'
+	arxiv query -max 500 -sort submittedDate 'au:hinton'
+
+Queries use the arXiv API syntax (ti:, au:, abs:, cat:, AND, OR, ANDNOT)
+and are fetched page by page.
+
+# Daily Listings
+
+The new command shows a day's announcements in a category, like arXiv's
+"new" listing, split into new submissions, cross-lists and replacements:
+
+	arxiv new cs.LG                     # Today's announcements
+	arxiv new -date 2024-03-01 hep-th   # A past day
+
+The first request for a day pulls that day's records over OAI-PMH and
+stores their metadata. A day that was still in progress when it was
+pulled is pulled again once the stored copy is an hour old. The web
+interface serves the same listing at /new/{category}.
+
+# Saved Searches
+
+Watches are saved searches that are checked whenever 'arxiv sync' or
+'arxiv new' adds papers to the cache. Each new match is recorded once as
+an unread alert:
+
+	arxiv watch add "mixture of experts" -cat cs.LG
+	arxiv watch add -author Hinton -enqueue ""
+	arxiv watch list                    # Watches and unread counts
+	arxiv watch rm 2
+	arxiv alerts                        # Show unread alerts, mark them read
+
+With -enqueue, matching papers' sources are added to the download queue.
+The web interface lists alerts at /alerts.
+
+# Download Policies
+
+Download policies queue downloads for papers as sync or new adds them. A
+paper matches a policy if it meets all of the policy's predicates; the
+queue is worked through by 'arxiv daemon':
+
+	arxiv policy add -cat cs.CL                         # Source for every new cs.CL paper
+	arxiv policy add -author Hinton,LeCun -kind pdf     # PDFs by these authors
+	arxiv policy add -license permissive -max-size 50MB
+	arxiv policy add -cat math -max-age 7d -kind all    # Skip old papers during a backfill
+	arxiv policy list
+	arxiv policy rm 3
+
+-license takes the license names described under Licenses, -max-age
+matches the submission date, and -max-size the download's reported size when the queue is
+processed. Policies can also be listed under "policies" in the config file,
+with the same fields as the flags (max_age, max_size).
+
+# Refreshing Metadata
+
+Metadata is fetched once and kept; titles, abstracts and journal
+references can change in later versions. The refresh command re-fetches
+the metadata of papers last fetched before a given age:
+
+	arxiv refresh                       # Papers fetched over 30 days ago
+	arxiv refresh -older-than 7d -cat cs.CL
+	arxiv refresh 2301.00001            # Specific papers, regardless of age
+
+Changed titles, abstracts, journal references, DOIs and versions are
+recorded and shown by 'arxiv get'.
+
 # Cache Structure
 
 The cache is stored in ARXIV_CACHE (default ~/.cache/arxiv):
@@ -86,6 +349,8 @@ The cache is stored in ARXIV_CACHE (default ~/.cache/arxiv):
 	├── index.db          # SQLite database with metadata and FTS index
 	├── pdf/              # Downloaded PDF files
 	├── src/              # Extracted TeX source directories
+	├── objects/          # Deduplicated source files (see 'arxiv dedup')
+	├── packs/            # Packed sources of cold papers (see 'arxiv pack')
 	└── meta/             # Raw metadata files
 
 # Examples
@@ -110,27 +375,48 @@ package main
 
 const usageText = `arxiv - offline arXiv paper cache manager
 
-Usage: arxiv <command> [options]
+Usage: arxiv [-profile name] <command> [options]
 
 Commands:
   fetch      Fetch and download specific papers
   sync       Sync paper metadata from arXiv OAI-PMH
+  query      Import papers matching an arXiv API query
+  import-bib Cache arXiv papers cited by a .bib/.bbl
+  refresh    Re-fetch stale paper metadata
+  new        Show a category's daily new submissions
+  watch      Manage saved searches
+  alerts     Show papers matching saved searches
+  policy     Manage automatic download policies
   stats      Show cache statistics
   search     Search cached papers (full-text)
   get        Get a specific paper's info
   ls         List cached papers
   reindex    Rebuild search index and citations
+  graph      Rank papers and find related ones
+  dedup      Deduplicate cached source files
+  pack       Move cold source trees into packs
+  unpack     Restore a packed source tree
   serve      Start web server
+  daemon     Run sync, downloads and GC on a schedule
+  config     Show the effective configuration
+
+Global options:
+  -profile name    Config profile (default: $ARXIV_PROFILE)
 
 Environment:
-  ARXIV_CACHE  Cache directory (default: ~/.cache/arxiv)
+  ARXIV_CACHE      Cache directory (default: ~/.cache/arxiv)
+  ARXIV_S3_BUCKET  Store PDFs and sources in an S3-compatible bucket
+  ARXIV_CONFIG     Config file (default: ~/.config/arxiv/config.json)
+  ARXIV_PROFILE    Config profile to use
 
 Examples:
   arxiv fetch 2301.00001       Fetch paper + TeX source
   arxiv fetch -all 2301.00001  Fetch paper + source + PDF
   arxiv search "transformer"   Search cached papers
   arxiv ls cs.AI               List papers in category
+  arxiv new cs.LG              Today's new cs.LG submissions
   arxiv ls -src -n 50          List 50 papers with source
+  arxiv get -format bibtex ID  Print a BibTeX entry
   arxiv serve                  Start web UI on :8080
 
 Run 'go doc github.com/tmc/arxiv/cmd/arxiv' for full documentation.`
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"bufio"
+	"cmp"
 	"context"
 	"flag"
 	"fmt"
 	"log"
+	"maps"
 	"os"
 	"os/signal"
-	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/tmc/arxiv"
@@ -16,29 +21,46 @@ import (
 func main() {
 	log.SetFlags(0)
 
-	if len(os.Args) < 2 {
+	global := flag.NewFlagSet("arxiv", flag.ExitOnError)
+	global.Usage = usage
+	profile := global.String("profile", "", "Config profile (default $ARXIV_PROFILE)")
+	global.Parse(os.Args[1:])
+
+	if global.NArg() == 0 {
 		usage()
 		os.Exit(1)
 	}
 
-	// Default cache location
-	cacheDir := os.Getenv("ARXIV_CACHE")
-	if cacheDir == "" {
-		home, _ := os.UserHomeDir()
-		cacheDir = filepath.Join(home, ".cache", "arxiv")
+	if err := loadSettings(*profile); err != nil {
+		log.Fatalf("config: %v", err)
 	}
+	cacheDir := settings.Cache
 
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
 
-	cmd := os.Args[1]
-	args := os.Args[2:]
+	cmd := global.Arg(0)
+	args := global.Args()[1:]
 
 	switch cmd {
 	case "fetch":
 		cmdFetch(ctx, cacheDir, args)
 	case "sync":
 		cmdSync(ctx, cacheDir, args)
+	case "query":
+		cmdQuery(ctx, cacheDir, args)
+	case "refresh":
+		cmdRefresh(ctx, cacheDir, args)
+	case "new":
+		cmdNew(ctx, cacheDir, args)
+	case "watch":
+		cmdWatch(ctx, cacheDir, args)
+	case "alerts":
+		cmdAlerts(ctx, cacheDir, args)
+	case "policy":
+		cmdPolicy(ctx, cacheDir, args)
+	case "import-bib":
+		cmdImportBib(ctx, cacheDir, args)
 	case "stats":
 		cmdStats(ctx, cacheDir, args)
 	case "search":
@@ -49,8 +71,20 @@ func main() {
 		cmdList(ctx, cacheDir, args)
 	case "reindex":
 		cmdReindex(ctx, cacheDir, args)
+	case "graph":
+		cmdGraph(ctx, cacheDir, args)
+	case "dedup":
+		cmdDedup(ctx, cacheDir, args)
+	case "pack":
+		cmdPack(ctx, cacheDir, args)
+	case "unpack":
+		cmdUnpack(ctx, cacheDir, args)
 	case "serve":
 		cmdServe(ctx, cacheDir, args)
+	case "daemon":
+		cmdDaemon(ctx, cacheDir, args)
+	case "config":
+		cmdConfig(args)
 	case "help":
 		usage()
 	default:
@@ -64,24 +98,30 @@ func usage() {
 
 func cmdFetch(ctx context.Context, cacheDir string, args []string) {
 	fs := flag.NewFlagSet("fetch", flag.ExitOnError)
-	pdf := fs.Bool("pdf", false, "Download PDF")
-	source := fs.Bool("source", true, "Download TeX source (default)")
-	all := fs.Bool("all", false, "Download both PDF and source")
+	pdf := fs.Bool("pdf", settings.Download == "pdf", "Download PDF")
+	source := fs.Bool("source", settings.Download != "pdf", "Download TeX source (default)")
+	all := fs.Bool("all", settings.Download == "all", "Download both PDF and source")
+	license := fs.String("license", "", "Only download papers with these licenses (e.g., permissive, cc-by)")
 	fs.Parse(args)
 
 	if fs.NArg() == 0 {
 		log.Fatal("usage: arxiv fetch [options] <paper-id> [paper-id...]")
 	}
 
-	cache, err := arxiv.Open(cacheDir)
+	cache, err := openCache(cacheDir)
 	if err != nil {
 		log.Fatalf("open cache: %v", err)
 	}
 	defer cache.Close()
 
+	licenses, err := arxiv.ParseLicenseFilter(*license)
+	if err != nil {
+		log.Fatalf("invalid -license: %v", err)
+	}
 	opts := &arxiv.DownloadOptions{
 		DownloadPDF:    *pdf || *all,
 		DownloadSource: *source || *all,
+		LicenseFilter:  licenses,
 	}
 
 	for _, id := range fs.Args() {
@@ -102,21 +142,16 @@ func cmdFetch(ctx context.Context, cacheDir string, args []string) {
 			fmt.Printf("  PDF: %s\n", paper.PDFPath)
 		}
 		fmt.Println()
-
-		// Rate limit between papers
-		if len(fs.Args()) > 1 {
-			time.Sleep(3 * time.Second)
-		}
 	}
 }
 
 func cmdSync(ctx context.Context, cacheDir string, args []string) {
 	fs := flag.NewFlagSet("sync", flag.ExitOnError)
-	set := fs.String("set", "", "arXiv set to sync (e.g., cs, physics)")
+	set := fs.String("set", settings.Set, "arXiv set to sync (e.g., cs, physics)")
 	from := fs.String("from", "", "Start date (YYYY-MM-DD)")
 	fs.Parse(args)
 
-	cache, err := arxiv.Open(cacheDir)
+	cache, err := openCache(cacheDir)
 	if err != nil {
 		log.Fatalf("open cache: %v", err)
 	}
@@ -148,13 +183,488 @@ func cmdSync(ctx context.Context, cacheDir string, args []string) {
 	fmt.Println("\nSync complete!")
 }
 
+func cmdQuery(ctx context.Context, cacheDir string, args []string) {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	maxResults := fs.Int("max", 100, "Max papers to import")
+	sortBy := fs.String("sort", "", "Sort by: relevance, submittedDate, lastUpdatedDate")
+	order := fs.String("order", "", "Sort order: ascending, descending")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		log.Fatal("usage: arxiv query [options] <search-query>")
+	}
+
+	cache, err := openCache(cacheDir)
+	if err != nil {
+		log.Fatalf("open cache: %v", err)
+	}
+	defer cache.Close()
+
+	result, err := cache.Query(ctx, strings.Join(fs.Args(), " "), &arxiv.QueryOptions{
+		MaxResults: *maxResults,
+		SortBy:     *sortBy,
+		SortOrder:  *order,
+		Progress: func(fetched, total int) {
+			fmt.Fprintf(os.Stderr, "\rQuerying: %d / %d papers", fetched, total)
+		},
+	})
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		log.Fatalf("query: %v", err)
+	}
+
+	for _, p := range result.Papers {
+		fmt.Printf("%s\t%s\n", p.ID, p.Title)
+	}
+	fmt.Fprintf(os.Stderr, "\nImported %d of %d matching papers\n", len(result.Papers), result.TotalResults)
+}
+
+func cmdRefresh(ctx context.Context, cacheDir string, args []string) {
+	fs := flag.NewFlagSet("refresh", flag.ExitOnError)
+	olderThan := fs.String("older-than", "30d", "Refresh metadata fetched longer ago than this (e.g., 30d, 12h)")
+	category := fs.String("cat", "", "Only refresh papers in this category or archive (e.g., cs.LG or cs)")
+	limit := fs.Int("n", 0, "Max papers to refresh (0 = all)")
+	fs.Parse(args)
+
+	cache, err := openCache(cacheDir)
+	if err != nil {
+		log.Fatalf("open cache: %v", err)
+	}
+	defer cache.Close()
+
+	ids := fs.Args()
+	if len(ids) == 0 {
+		age, err := parseAge(*olderThan)
+		if err != nil {
+			log.Fatalf("invalid -older-than: %v", err)
+		}
+		ids, err = cache.StalePapers(ctx, age, *category, *limit)
+		if err != nil {
+			log.Fatalf("find stale papers: %v", err)
+		}
+	}
+	if len(ids) == 0 {
+		fmt.Println("No stale papers.")
+		return
+	}
+
+	fmt.Printf("Refreshing %d papers...\n", len(ids))
+	res, err := cache.Refresh(ctx, ids)
+	for _, m := range res.Missing {
+		fmt.Printf("  %s: %s\n", m.ID, m.Reason)
+	}
+	for _, e := range res.Errors {
+		fmt.Printf("  %v\n", e)
+	}
+	if err != nil {
+		log.Printf("refresh: %v", err)
+	}
+	fmt.Printf("Refreshed %d of %d papers\n", len(res.Found), len(ids))
+}
+
+func cmdNew(ctx context.Context, cacheDir string, args []string) {
+	fs := flag.NewFlagSet("new", flag.ExitOnError)
+	date := fs.String("date", "", "Announcement date (YYYY-MM-DD, default today)")
+	noReplacements := fs.Bool("no-replacements", false, "Omit replacements")
+	fs.Parse(args)
+
+	categories := fs.Args()
+	if len(categories) == 0 {
+		categories = settings.Categories
+	}
+	if len(categories) == 0 {
+		log.Fatal("usage: arxiv new [-date YYYY-MM-DD] <category>...")
+	}
+
+	day := time.Now().UTC()
+	if *date != "" {
+		var err error
+		day, err = time.Parse("2006-01-02", *date)
+		if err != nil {
+			log.Fatalf("invalid date: %v", err)
+		}
+	}
+
+	cache, err := openCache(cacheDir)
+	if err != nil {
+		log.Fatalf("open cache: %v", err)
+	}
+	defer cache.Close()
+
+	printDigest := func(heading string, papers []arxiv.Paper) {
+		if len(papers) == 0 {
+			return
+		}
+		fmt.Printf("\n%s\n", heading)
+		for _, p := range papers {
+			fmt.Printf("  %-12s %s\n", p.ID, p.Title)
+			fmt.Printf("  %-12s %s [%s]\n", "", truncate(p.Authors, 80), p.Categories)
+		}
+	}
+	for i, category := range categories {
+		listing, err := cache.NewSubmissions(ctx, category, day)
+		if err != nil {
+			log.Fatalf("new submissions: %v", err)
+		}
+
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s, %s: %d new, %d cross-lists, %d replacements\n",
+			listing.Category, listing.Day.Format("Mon 2 Jan 2006"),
+			len(listing.New), len(listing.CrossLists), len(listing.Replacements))
+		printDigest("New submissions", listing.New)
+		printDigest("Cross-lists", listing.CrossLists)
+		if !*noReplacements {
+			printDigest("Replacements", listing.Replacements)
+		}
+	}
+}
+
+func cmdWatch(ctx context.Context, cacheDir string, args []string) {
+	if len(args) == 0 {
+		log.Fatal("usage: arxiv watch add|list|rm [options]")
+	}
+
+	cache, err := openCache(cacheDir)
+	if err != nil {
+		log.Fatalf("open cache: %v", err)
+	}
+	defer cache.Close()
+
+	switch args[0] {
+	case "add":
+		fs := flag.NewFlagSet("watch add", flag.ExitOnError)
+		cats := fs.String("cat", "", "Only papers in these categories or archives (comma-separated)")
+		authors := fs.String("author", "", "Only papers by these authors (comma-separated)")
+		enqueue := fs.Bool("enqueue", false, "Queue matching papers' sources for download")
+		fs.Parse(args[1:])
+		// Allow flags after the query, e.g. watch add "mixture of experts" -cat cs.LG
+		query := fs.Arg(0)
+		if fs.NArg() > 1 {
+			fs.Parse(fs.Args()[1:])
+		}
+		if query == "" && *cats == "" && *authors == "" {
+			log.Fatal("usage: arxiv watch add [-cat cats] [-author names] [-enqueue] <query>")
+		}
+
+		w := &arxiv.Watch{Query: query, Enqueue: *enqueue}
+		if *cats != "" {
+			w.Categories = strings.Split(*cats, ",")
+		}
+		if *authors != "" {
+			w.Authors = strings.Split(*authors, ",")
+		}
+		id, err := cache.AddWatch(ctx, w)
+		if err != nil {
+			log.Fatalf("add watch: %v", err)
+		}
+		fmt.Printf("Added watch %d\n", id)
+
+	case "list", "ls":
+		watches, err := cache.Watches(ctx)
+		if err != nil {
+			log.Fatalf("list watches: %v", err)
+		}
+		if len(watches) == 0 {
+			fmt.Println("No watches.")
+			return
+		}
+		for _, w := range watches {
+			fmt.Printf("%3d  %-30q", w.ID, w.Query)
+			if len(w.Categories) > 0 {
+				fmt.Printf(" cat:%s", strings.Join(w.Categories, ","))
+			}
+			if len(w.Authors) > 0 {
+				fmt.Printf(" author:%s", strings.Join(w.Authors, ","))
+			}
+			if w.Enqueue {
+				fmt.Printf(" [enqueue]")
+			}
+			fmt.Printf("  (%d unread)\n", w.Unread)
+		}
+
+	case "rm", "remove":
+		if len(args) < 2 {
+			log.Fatal("usage: arxiv watch rm <id> [id...]")
+		}
+		for _, arg := range args[1:] {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				log.Fatalf("invalid watch id %q", arg)
+			}
+			if err := cache.RemoveWatch(ctx, id); err != nil {
+				log.Printf("remove watch %d: %v", id, err)
+				continue
+			}
+			fmt.Printf("Removed watch %d\n", id)
+		}
+
+	default:
+		log.Fatalf("unknown watch command: %s", args[0])
+	}
+}
+
+func cmdImportBib(ctx context.Context, cacheDir string, args []string) {
+	fs := flag.NewFlagSet("import-bib", flag.ExitOnError)
+	queue := fs.String("queue", "", "Also queue downloads: source, pdf or all")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		log.Fatal("usage: arxiv import-bib [-queue source|pdf|all] <file|dir>...")
+	}
+	switch *queue {
+	case "", arxiv.QueueSource, arxiv.QueuePDF, arxiv.QueueAll:
+	default:
+		log.Fatalf("invalid -queue: %s", *queue)
+	}
+
+	cache, err := openCache(cacheDir)
+	if err != nil {
+		log.Fatalf("open cache: %v", err)
+	}
+	defer cache.Close()
+
+	for _, path := range fs.Args() {
+		res, err := cache.ImportBib(ctx, path, &arxiv.ImportOptions{Enqueue: *queue})
+		if res == nil {
+			log.Fatalf("import %s: %v", path, err)
+		}
+
+		total := len(res.Resolved) + len(res.Unresolved)
+		byDOI := 0
+		for _, e := range res.Resolved {
+			if e.Via == "doi" {
+				byDOI++
+			}
+		}
+		fmt.Printf("%s: %d of %d entries cite arXiv papers (%d by DOI)\n", path, len(res.Resolved), total, byDOI)
+		for _, e := range res.Resolved {
+			fmt.Printf("  %-24s %-16s %s\n", e.Key, e.ArxivID, truncate(e.Title, 60))
+		}
+		if len(res.Unresolved) > 0 {
+			fmt.Printf("\nUnresolved:\n")
+			for _, e := range res.Unresolved {
+				detail := e.Title
+				if e.DOI != "" {
+					detail = "doi:" + e.DOI + " " + detail
+				}
+				fmt.Printf("  %-24s %s\n", e.Key, truncate(detail, 80))
+			}
+		}
+		if f := res.Fetch; f != nil {
+			fmt.Printf("\nCached %d papers", len(f.Found))
+			if *queue != "" {
+				fmt.Printf(", queued for download (%s)", *queue)
+			}
+			fmt.Println()
+			for _, m := range f.Missing {
+				fmt.Printf("  %s: %s\n", m.ID, m.Reason)
+			}
+			for _, e := range f.Errors {
+				fmt.Printf("  %s: %v\n", e.ID, e.Err)
+			}
+		}
+		if err != nil {
+			log.Fatalf("import %s: %v", path, err)
+		}
+	}
+}
+
+func cmdPolicy(ctx context.Context, cacheDir string, args []string) {
+	if len(args) == 0 {
+		log.Fatal("usage: arxiv policy add|list|rm [options]")
+	}
+
+	cache, err := openCache(cacheDir)
+	if err != nil {
+		log.Fatalf("open cache: %v", err)
+	}
+	defer cache.Close()
+
+	switch args[0] {
+	case "add":
+		fs := flag.NewFlagSet("policy add", flag.ExitOnError)
+		name := fs.String("name", "", "Policy name")
+		cats := fs.String("cat", "", "Only papers in these categories (comma-separated)")
+		authors := fs.String("author", "", "Only papers by these authors (comma-separated)")
+		licenses := fs.String("license", "", "Only papers with these licenses (e.g., permissive, cc-by)")
+		maxAge := fs.String("max-age", "", "Only papers submitted within this long (e.g., 7d)")
+		maxSize := fs.String("max-size", "", "Skip downloads larger than this (e.g., 50MB)")
+		kind := fs.String("kind", "source", "What to download: source, pdf or all")
+		fs.Parse(args[1:])
+
+		p, err := newPolicy(policyConfig{
+			Name:       *name,
+			Categories: splitList(*cats),
+			Authors:    splitList(*authors),
+			Licenses:   splitList(*licenses),
+			MaxAge:     *maxAge,
+			MaxSize:    *maxSize,
+			Kind:       *kind,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		id, err := cache.AddPolicy(ctx, p)
+		if err != nil {
+			log.Fatalf("add policy: %v", err)
+		}
+		fmt.Printf("Added policy %d\n", id)
+
+	case "list", "ls":
+		policies, err := cache.Policies(ctx)
+		if err != nil {
+			log.Fatalf("list policies: %v", err)
+		}
+		for _, p := range policies {
+			fmt.Printf("%3d  %s\n", p.ID, describePolicy(&p))
+		}
+		for _, p := range settings.Policies {
+			dp, _ := newPolicy(p) // validated when the config was loaded
+			fmt.Printf("%3s  %s\n", "cfg", describePolicy(dp))
+		}
+		if len(policies) == 0 && len(settings.Policies) == 0 {
+			fmt.Println("No download policies.")
+		}
+
+	case "rm", "remove":
+		if len(args) < 2 {
+			log.Fatal("usage: arxiv policy rm <id> [id...]")
+		}
+		for _, arg := range args[1:] {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				log.Fatalf("invalid policy id %q", arg)
+			}
+			if err := cache.RemovePolicy(ctx, id); err != nil {
+				log.Printf("remove policy %d: %v", id, err)
+				continue
+			}
+			fmt.Printf("Removed policy %d\n", id)
+		}
+
+	default:
+		log.Fatalf("unknown policy command: %s", args[0])
+	}
+}
+
+// describePolicy formats a download policy for policy list.
+func describePolicy(p *arxiv.DownloadPolicy) string {
+	parts := []string{p.Kind}
+	if p.Kind == "" {
+		parts[0] = arxiv.QueueSource
+	}
+	if p.Name != "" {
+		parts = append(parts, fmt.Sprintf("%q", p.Name))
+	}
+	if len(p.Categories) > 0 {
+		parts = append(parts, "cat:"+strings.Join(p.Categories, ","))
+	}
+	if len(p.Authors) > 0 {
+		parts = append(parts, "author:"+strings.Join(p.Authors, ","))
+	}
+	if len(p.Licenses) > 0 {
+		parts = append(parts, "license:"+p.Licenses.String())
+	}
+	if p.MaxAge > 0 {
+		parts = append(parts, fmt.Sprintf("max-age:%dd", p.MaxAge/(24*time.Hour)))
+	}
+	if p.MaxSize > 0 {
+		parts = append(parts, "max-size:"+formatBytes(p.MaxSize))
+	}
+	return strings.Join(parts, " ")
+}
+
+// checkFormat exits if format is neither empty (text) nor an export format.
+func checkFormat(format string) {
+	if format != "" && !slices.Contains(arxiv.ExportFormats, format) {
+		log.Fatalf("invalid -format %q (want %s)", format, strings.Join(arxiv.ExportFormats, ", "))
+	}
+}
+
+// encodePapers writes papers to stdout in an export format.
+func encodePapers(format string, papers []arxiv.Paper) {
+	w := bufio.NewWriter(os.Stdout)
+	if err := arxiv.EncodePapers(w, format, papers); err != nil {
+		log.Fatalf("encode: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatalf("encode: %v", err)
+	}
+}
+
+// splitList splits a comma-separated flag value, returning nil for "".
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func cmdAlerts(ctx context.Context, cacheDir string, args []string) {
+	fs := flag.NewFlagSet("alerts", flag.ExitOnError)
+	all := fs.Bool("a", false, "Include alerts already read")
+	keep := fs.Bool("keep", false, "Leave alerts unread")
+	fs.Parse(args)
+
+	cache, err := openCache(cacheDir)
+	if err != nil {
+		log.Fatalf("open cache: %v", err)
+	}
+	defer cache.Close()
+
+	alerts, err := cache.Alerts(ctx, !*all)
+	if err != nil {
+		log.Fatalf("alerts: %v", err)
+	}
+	if len(alerts) == 0 {
+		fmt.Println("No new alerts.")
+		return
+	}
+
+	for _, a := range alerts {
+		fmt.Printf("[%s] %s\n", a.Paper.ID, a.Paper.Title)
+		fmt.Printf("  %s\n", truncate(a.Paper.Authors, 100))
+		fmt.Printf("  watch %d: %q  %s\n\n", a.WatchID, a.Query, a.Paper.Categories)
+	}
+
+	if !*keep {
+		if err := cache.MarkAlertsRead(ctx); err != nil {
+			log.Fatalf("mark alerts read: %v", err)
+		}
+	}
+}
+
 func cmdStats(ctx context.Context, cacheDir string, args []string) {
-	cache, err := arxiv.Open(cacheDir)
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	byLicense := fs.Bool("by-license", false, "Count papers by license")
+	fs.Parse(args)
+
+	cache, err := openCache(cacheDir)
 	if err != nil {
 		log.Fatalf("open cache: %v", err)
 	}
 	defer cache.Close()
 
+	if *byLicense {
+		counts, err := cache.LicenseCounts(ctx)
+		if err != nil {
+			log.Fatalf("stats: %v", err)
+		}
+		licenses := slices.SortedFunc(maps.Keys(counts), func(a, b arxiv.License) int {
+			return cmp.Compare(counts[b], counts[a])
+		})
+		for _, l := range licenses {
+			permissive := ""
+			if l.Permissive() {
+				permissive = " (permissive)"
+			}
+			fmt.Printf("%-12s %8d%s\n", l, counts[l], permissive)
+		}
+		return
+	}
+
 	stats, err := cache.Stats(ctx)
 	if err != nil {
 		log.Fatalf("stats: %v", err)
@@ -165,30 +675,51 @@ func cmdStats(ctx context.Context, cacheDir string, args []string) {
 	fmt.Printf("PDFs downloaded:    %d\n", stats.PDFsDownloaded)
 	fmt.Printf("Sources downloaded: %d\n", stats.SourcesDownloaded)
 	fmt.Printf("Queued downloads:   %d\n", stats.QueuedDownloads)
+	if stats.Dedup.Files > 0 {
+		fmt.Printf("Dedup objects:      %d (%d files)\n", stats.Dedup.Objects, stats.Dedup.Files)
+		fmt.Printf("Dedup ratio:        %.2fx (%d MB stored, %d MB logical)\n",
+			stats.Dedup.Ratio(), stats.Dedup.StoredBytes>>20, stats.Dedup.LogicalBytes>>20)
+	}
 }
 
 func cmdSearch(ctx context.Context, cacheDir string, args []string) {
 	fs := flag.NewFlagSet("search", flag.ExitOnError)
 	category := fs.String("category", "", "Filter by category")
 	limit := fs.Int("limit", 20, "Max results")
+	license := fs.String("license", "", "Only papers with these licenses (e.g., permissive, cc-by)")
+	format := fs.String("format", "", "Output format: "+strings.Join(arxiv.ExportFormats, ", ")+" (default text)")
 	fs.Parse(args)
 
 	if fs.NArg() == 0 {
 		log.Fatal("usage: arxiv search <query>")
 	}
+	checkFormat(*format)
+	licenses, err := arxiv.ParseLicenseFilter(*license)
+	if err != nil {
+		log.Fatalf("invalid -license: %v", err)
+	}
 
-	cache, err := arxiv.Open(cacheDir)
+	cache, err := openCache(cacheDir)
 	if err != nil {
 		log.Fatalf("open cache: %v", err)
 	}
 	defer cache.Close()
 
 	query := fs.Arg(0)
-	results, err := cache.Search(ctx, query, *category, *limit)
+	results, err := cache.SearchWithOptions(ctx, query, &arxiv.SearchOptions{
+		Category: *category,
+		Licenses: licenses,
+		Limit:    *limit,
+	})
 	if err != nil {
 		log.Fatalf("search: %v", err)
 	}
 
+	if *format != "" {
+		encodePapers(*format, results)
+		return
+	}
+
 	if len(results) == 0 {
 		fmt.Println("No results found.")
 		return
@@ -204,20 +735,22 @@ func cmdSearch(ctx context.Context, cacheDir string, args []string) {
 		if p.PDFDownloaded {
 			fmt.Printf(" [pdf cached]")
 		}
-		fmt.Println("\n")
+		fmt.Print("\n\n")
 	}
 }
 
 func cmdGet(ctx context.Context, cacheDir string, args []string) {
 	fs := flag.NewFlagSet("get", flag.ExitOnError)
 	fetch := fs.Bool("fetch", false, "Fetch from arXiv if not cached")
+	format := fs.String("format", "", "Output format: "+strings.Join(arxiv.ExportFormats, ", ")+" (default text)")
 	fs.Parse(args)
 
 	if fs.NArg() == 0 {
-		log.Fatal("usage: arxiv get [-fetch] <paper-id>")
+		log.Fatal("usage: arxiv get [-fetch] [-format fmt] <paper-id>")
 	}
+	checkFormat(*format)
 
-	cache, err := arxiv.Open(cacheDir)
+	cache, err := openCache(cacheDir)
 	if err != nil {
 		log.Fatalf("open cache: %v", err)
 	}
@@ -233,10 +766,26 @@ func cmdGet(ctx context.Context, cacheDir string, args []string) {
 		log.Fatalf("get paper: %v", err)
 	}
 
+	if *format != "" {
+		encodePapers(*format, []arxiv.Paper{*paper})
+		return
+	}
+
 	fmt.Printf("ID:         %s\n", paper.ID)
 	fmt.Printf("Title:      %s\n", paper.Title)
 	fmt.Printf("Authors:    %s\n", paper.Authors)
-	fmt.Printf("Categories: %s\n", paper.Categories)
+	for _, a := range paper.AuthorList {
+		if len(a.Affiliations) > 0 {
+			fmt.Printf("            %s (%s)\n", a.Name, strings.Join(a.Affiliations, "; "))
+		}
+	}
+	fmt.Printf("Categories: %s (primary %s)\n", paper.Categories, paper.PrimaryCategory())
+	if paper.Version > 0 {
+		fmt.Printf("Version:    v%d\n", paper.Version)
+	}
+	if paper.License != "" {
+		fmt.Printf("License:    %s (%s)\n", paper.LicenseType(), paper.License)
+	}
 	fmt.Printf("Created:    %s\n", paper.Created.Format("2006-01-02"))
 	fmt.Printf("Updated:    %s\n", paper.Updated.Format("2006-01-02"))
 	fmt.Printf("PDF:        %v\n", paper.PDFDownloaded)
@@ -248,6 +797,13 @@ func cmdGet(ctx context.Context, cacheDir string, args []string) {
 		fmt.Printf("Source Path: %s\n", paper.SourcePath)
 	}
 	fmt.Printf("\nAbstract:\n%s\n", paper.Abstract)
+
+	if history, err := cache.MetadataHistory(ctx, paper.ID); err == nil && len(history) > 0 {
+		fmt.Printf("\nHistory:\n")
+		for _, ch := range history {
+			fmt.Printf("  %s %s: %q -> %q\n", ch.Changed.Format("2006-01-02"), ch.Field, ch.OldValue, ch.NewValue)
+		}
+	}
 }
 
 func cmdList(ctx context.Context, cacheDir string, args []string) {
@@ -256,9 +812,17 @@ func cmdList(ctx context.Context, cacheDir string, args []string) {
 	limit := fs.Int("n", 0, "Max results (0 = all)")
 	srcOnly := fs.Bool("src", false, "Only papers with source downloaded")
 	all := fs.Bool("a", false, "Show all (including metadata-only)")
+	license := fs.String("license", "", "Only papers with these licenses (e.g., permissive, cc-by)")
+	format := fs.String("format", "", "Output format: "+strings.Join(arxiv.ExportFormats, ", ")+" (default text)")
 	fs.Parse(args)
 
-	cache, err := arxiv.Open(cacheDir)
+	checkFormat(*format)
+	licenses, err := arxiv.ParseLicenseFilter(*license)
+	if err != nil {
+		log.Fatalf("invalid -license: %v", err)
+	}
+
+	cache, err := openCache(cacheDir)
 	if err != nil {
 		log.Fatalf("open cache: %v", err)
 	}
@@ -269,11 +833,22 @@ func cmdList(ctx context.Context, cacheDir string, args []string) {
 		*category = fs.Arg(0)
 	}
 
-	papers, err := cache.ListPapersFiltered(ctx, *category, *srcOnly, *all, *limit)
+	papers, err := cache.ListPapersWithOptions(ctx, &arxiv.ListOptions{
+		Category:   *category,
+		Licenses:   licenses,
+		SourceOnly: *srcOnly,
+		All:        *all,
+		Limit:      *limit,
+	})
 	if err != nil {
 		log.Fatalf("list: %v", err)
 	}
 
+	if *format != "" {
+		encodePapers(*format, papers)
+		return
+	}
+
 	if len(papers) == 0 {
 		fmt.Println("No papers cached.")
 		return
@@ -293,7 +868,12 @@ func cmdList(ctx context.Context, cacheDir string, args []string) {
 }
 
 func cmdReindex(ctx context.Context, cacheDir string, args []string) {
-	cache, err := arxiv.Open(cacheDir)
+	fs := flag.NewFlagSet("reindex", flag.ExitOnError)
+	force := fs.Bool("force", false, "Re-extract the references of unchanged papers too")
+	workers := fs.Int("workers", 0, "Papers to extract in parallel (default: number of CPUs)")
+	fs.Parse(args)
+
+	cache, err := openCache(cacheDir)
 	if err != nil {
 		log.Fatalf("open cache: %v", err)
 	}
@@ -304,9 +884,261 @@ func cmdReindex(ctx context.Context, cacheDir string, args []string) {
 		log.Fatalf("reindex fts: %v", err)
 	}
 
-	fmt.Println("Rebuilding citations...")
-	if err := cache.RebuildAllCitations(ctx); err != nil {
-		log.Fatalf("reindex citations: %v", err)
+	stats, err := cache.RebuildCitations(ctx, &arxiv.RebuildOptions{
+		Workers: *workers,
+		Force:   *force,
+		Progress: func(paperID string, done, total int) {
+			fmt.Printf("\rRebuilding citations: %d / %d papers", done, total)
+		},
+	})
+	if err != nil {
+		log.Fatalf("\nreindex citations: %v", err)
+	}
+	fmt.Printf("\nIndexed %d papers, %d unchanged", stats.Indexed, stats.Unchanged)
+	if stats.Missing > 0 {
+		fmt.Printf(", %d missing their source and PDF", stats.Missing)
+	}
+	fmt.Println()
+}
+
+func cmdGraph(ctx context.Context, cacheDir string, args []string) {
+	if len(args) == 0 {
+		log.Fatal("usage: arxiv graph compute|top|related [options]")
+	}
+
+	cache, err := openCache(cacheDir)
+	if err != nil {
+		log.Fatalf("open cache: %v", err)
+	}
+	defer cache.Close()
+
+	if args[0] != "compute" && cache.GraphAnalyticsComputed(ctx).IsZero() {
+		log.Fatal("graph analytics not computed; run 'arxiv graph compute' first")
+	}
+
+	switch args[0] {
+	case "compute":
+		fs := flag.NewFlagSet("graph compute", flag.ExitOnError)
+		damping := fs.Float64("damping", arxiv.DefaultDamping, "PageRank damping factor")
+		related := fs.Int("related", arxiv.DefaultRelated, "Related papers to keep per paper")
+		hubLimit := fs.Int("hub-limit", arxiv.DefaultHubLimit, "When relating papers, skip references with more citing papers, and papers with more references, than this")
+		fs.Parse(args[1:])
+
+		stats, err := cache.ComputeGraphAnalytics(ctx, &arxiv.AnalyticsOptions{
+			Damping:  *damping,
+			Related:  *related,
+			HubLimit: *hubLimit,
+			Progress: func(stage string, done, total int) {
+				if done == total || done%1000 == 0 {
+					fmt.Printf("\rComputing %s: %d / %d", stage, done, total)
+				}
+				if done == total {
+					fmt.Println()
+				}
+			},
+		})
+		if err != nil {
+			log.Fatalf("\ngraph compute: %v", err)
+		}
+		fmt.Printf("Ranked %d papers (%d citations) overall and in %d categories, %d related pairs\n",
+			stats.Papers, stats.Citations, stats.Categories, stats.Related)
+
+	case "top":
+		fs := flag.NewFlagSet("graph top", flag.ExitOnError)
+		category := fs.String("cat", "", "Rank within this category (e.g., cs.LG)")
+		limit := fs.Int("n", 20, "Number of papers")
+		fs.Parse(args[1:])
+
+		papers, err := cache.PageRank(ctx, *category, *limit)
+		if err != nil {
+			log.Fatalf("graph top: %v", err)
+		}
+		if len(papers) == 0 {
+			fmt.Println("No ranked papers.")
+		}
+		for _, p := range papers {
+			title := p.Title
+			if !p.Cached {
+				title = "(not cached)"
+			}
+			fmt.Printf("%8.2f  %-16s %s\n", p.Rank, p.ID, truncate(title, 60))
+		}
+
+	case "related":
+		if len(args) < 2 {
+			log.Fatal("usage: arxiv graph related <paper-id>")
+		}
+		papers, err := cache.Related(ctx, args[1])
+		if err != nil {
+			log.Fatalf("graph related: %v", err)
+		}
+		if len(papers) == 0 {
+			fmt.Println("No related papers.")
+		}
+		for _, p := range papers {
+			title := p.Title
+			if !p.Cached {
+				title = "(not cached)"
+			}
+			fmt.Printf("%5.2f  %-16s %3d co-cited %3d shared  %s\n",
+				p.Score, p.ID, p.CoCitations, p.SharedRefs, truncate(title, 50))
+		}
+
+	default:
+		log.Fatalf("unknown graph command: %s", args[0])
+	}
+}
+
+func cmdDedup(ctx context.Context, cacheDir string, args []string) {
+	fs := flag.NewFlagSet("dedup", flag.ExitOnError)
+	off := fs.Bool("off", false, "Disable dedup for newly extracted sources")
+	fs.Parse(args)
+
+	cache, err := openCache(cacheDir)
+	if err != nil {
+		log.Fatalf("open cache: %v", err)
+	}
+	defer cache.Close()
+
+	if *off {
+		if err := cache.SetDedup(ctx, false); err != nil {
+			log.Fatalf("dedup: %v", err)
+		}
+		fmt.Println("Dedup disabled for new sources.")
+		return
+	}
+
+	err = cache.Dedup(ctx, func(paperID string, done, total int) {
+		fmt.Printf("\rDeduplicating: %d / %d papers", done, total)
+	})
+	if err != nil {
+		log.Fatalf("\ndedup: %v", err)
+	}
+
+	stats, err := cache.DedupStats(ctx)
+	if err != nil {
+		log.Fatalf("stats: %v", err)
+	}
+	fmt.Printf("\n%d files stored as %d objects (%.2fx)\n", stats.Files, stats.Objects, stats.Ratio())
+}
+
+func cmdPack(ctx context.Context, cacheDir string, args []string) {
+	fs := flag.NewFlagSet("pack", flag.ExitOnError)
+	olderThan := fs.String("older-than", "180d", "Pack papers submitted before this age (e.g., 180d, 720h)")
+	fs.Parse(args)
+
+	age, err := parseAge(*olderThan)
+	if err != nil {
+		log.Fatalf("invalid -older-than: %v", err)
+	}
+
+	cache, err := openCache(cacheDir)
+	if err != nil {
+		log.Fatalf("open cache: %v", err)
+	}
+	defer cache.Close()
+
+	stats, err := cache.Pack(ctx, &arxiv.PackOptions{
+		OlderThan: age,
+		Progress: func(pack string, papers, done, total int) {
+			fmt.Printf("\rPacking: %d / %d packs (%s: %d papers)", done, total, pack, papers)
+		},
+	})
+	if err != nil {
+		log.Fatalf("\npack: %v", err)
+	}
+	fmt.Printf("\nPacked %d files from %d papers into %d packs\n", stats.Files, stats.Papers, stats.Packs)
+}
+
+func cmdUnpack(ctx context.Context, cacheDir string, args []string) {
+	if len(args) == 0 {
+		log.Fatal("usage: arxiv unpack <paper-id> [paper-id...]")
+	}
+
+	cache, err := openCache(cacheDir)
+	if err != nil {
+		log.Fatalf("open cache: %v", err)
+	}
+	defer cache.Close()
+
+	for _, id := range args {
+		if err := cache.Unpack(ctx, id); err != nil {
+			log.Printf("%s: %v", id, err)
+			continue
+		}
+		fmt.Printf("Unpacked %s\n", id)
+	}
+}
+
+// parseAge parses a duration, additionally accepting a "d" suffix for days.
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// parseSize parses a byte count with an optional KB, MB or GB suffix.
+func parseSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		n      int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+	upper := strings.ToUpper(strings.TrimSpace(s))
+	for _, u := range units {
+		if num, ok := strings.CutSuffix(upper, u.suffix); ok {
+			n, err := strconv.ParseInt(strings.TrimSpace(num), 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid size %q", s)
+			}
+			return n * u.n, nil
+		}
+	}
+	n, err := strconv.ParseInt(upper, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n, nil
+}
+
+// formatBytes formats a byte count in the largest whole unit.
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30 && n%(1<<30) == 0:
+		return fmt.Sprintf("%dGB", n>>30)
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%dMB", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%dKB", n>>10)
+	}
+	return fmt.Sprintf("%dB", n)
+}
+
+// openCache opens the cache at cacheDir with the effective settings. If an
+// S3 bucket is configured, PDFs and sources are stored in that
+// S3-compatible bucket instead of under cacheDir; the index always stays
+// local.
+func openCache(cacheDir string) (*arxiv.Cache, error) {
+	opts := settings.options()
+	if s3 := settings.S3; s3 != nil && s3.Bucket != "" {
+		store, err := arxiv.NewS3Store(arxiv.S3Config{
+			Endpoint:     s3.Endpoint,
+			Region:       s3.Region,
+			Bucket:       s3.Bucket,
+			Prefix:       s3.Prefix,
+			AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+			PathStyle:    s3.Endpoint != "",
+		})
+		if err != nil {
+			return nil, err
+		}
+		opts.Blobs = store
 	}
-	fmt.Println("Done.")
+	return arxiv.OpenWithOptions(cacheDir, opts)
 }
@@ -2,17 +2,20 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"embed"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"html/template"
-	"io/fs"
 	"log"
+	"net"
 	"net/http"
-	"os"
-	"path/filepath"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/tmc/arxiv"
 )
@@ -21,37 +24,39 @@ import (
 var templateFS embed.FS
 
 var templates = template.Must(template.New("").Funcs(template.FuncMap{
-	"truncate": func(s string, n int) string {
-		if len(s) <= n {
-			return s
-		}
-		return s[:n] + "..."
-	},
+	"truncate":        truncate,
 	"parseAuthors":    parseAuthors,
 	"parseCategories": parseCategories,
 	"arxivIDToDate":   arxivIDToDate,
 }).ParseFS(templateFS, "templates/*.html"))
 
+// truncate shortens s to at most n bytes, adding an ellipsis if cut.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
 func cmdServe(ctx context.Context, cacheDir string, args []string) {
 	fs := flag.NewFlagSet("serve", flag.ExitOnError)
-	port := fs.Int("port", 8080, "Port to listen on")
+	port := fs.Int("port", settings.Port, "Port to listen on")
+	remoteLicense := fs.String("remote-license", settings.RemoteLicense,
+		"Licenses whose PDFs and sources may be served to remote clients (comma-separated, or all)")
 	fs.Parse(args)
 
-	cache, err := arxiv.Open(cacheDir)
+	remote, err := parseRemoteLicense(*remoteLicense)
+	if err != nil {
+		log.Fatalf("invalid -remote-license: %v", err)
+	}
+
+	cache, err := openCache(cacheDir)
 	if err != nil {
 		log.Fatalf("open cache: %v", err)
 	}
 
-	srv := &server{cache: cache, cacheDir: cacheDir}
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", srv.handleIndex)
-	mux.HandleFunc("/search", srv.handleSearch)
-	mux.HandleFunc("/paper/", srv.handlePaper)
-	mux.HandleFunc("/author/", srv.handleAuthor)
-	mux.HandleFunc("/category/", srv.handleCategory)
-	mux.HandleFunc("/categories", srv.handleCategories)
-	mux.HandleFunc("/src/", srv.handleSource)
-	mux.HandleFunc("/pdf/", srv.handlePDF)
+	srv := &server{cache: cache, cacheDir: cacheDir, remoteLicenses: remote}
+	mux := srv.routes()
 
 	addr := fmt.Sprintf(":%d", *port)
 	log.Printf("Starting server at http://localhost%s", addr)
@@ -70,6 +75,55 @@ func cmdServe(ctx context.Context, cacheDir string, args []string) {
 type server struct {
 	cache    *arxiv.Cache
 	cacheDir string
+
+	// remoteLicenses are the licenses whose PDFs and sources may be served
+	// to non-loopback clients
+	remoteLicenses arxiv.LicenseFilter
+}
+
+// parseRemoteLicense parses a -remote-license value; "all" allows any
+// license.
+func parseRemoteLicense(s string) (arxiv.LicenseFilter, error) {
+	if s == "all" {
+		return nil, nil
+	}
+	f, err := arxiv.ParseLicenseFilter(s)
+	if err == nil && len(f) == 0 {
+		err = fmt.Errorf("no licenses given (use all to allow any)")
+	}
+	return f, err
+}
+
+// allowFiles reports whether the paper's PDF and source may be served to
+// the client, writing a 403 response if not. Loopback clients are always
+// allowed; others only if the license is in s.remoteLicenses. Behind a
+// reverse proxy on the same host every client looks local.
+func (s *server) allowFiles(w http.ResponseWriter, r *http.Request, paper *arxiv.Paper) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if ip := net.ParseIP(host); err == nil && ip != nil && ip.IsLoopback() {
+		return true
+	}
+	if l := paper.LicenseType(); !s.remoteLicenses.Allows(l) {
+		http.Error(w, fmt.Sprintf("%s is not served remotely under license %s", paper.ID, l), http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// routes returns a mux serving the web interface.
+func (s *server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleIndex)
+	mux.HandleFunc("/search", s.handleSearch)
+	mux.HandleFunc("/paper/", s.handlePaper)
+	mux.HandleFunc("/author/", s.handleAuthor)
+	mux.HandleFunc("/category/", s.handleCategory)
+	mux.HandleFunc("/categories", s.handleCategories)
+	mux.HandleFunc("/new/", s.handleNew)
+	mux.HandleFunc("/alerts", s.handleAlerts)
+	mux.HandleFunc("/src/", s.handleSource)
+	mux.HandleFunc("/pdf/", s.handlePDF)
+	return mux
 }
 
 func (s *server) handleIndex(w http.ResponseWriter, r *http.Request) {
@@ -191,11 +245,28 @@ func (s *server) handlePaper(w http.ResponseWriter, r *http.Request) {
 	// Handle /paper/:id/graph - return citation graph JSON
 	if strings.HasSuffix(path, "/graph") {
 		paperID := strings.TrimSuffix(path, "/graph")
-		graph, err := s.cache.GetCitationGraph(ctx, paperID)
+		opts, err := graphOptions(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		graph, err := s.cache.GetCitationGraphWithOptions(ctx, paperID, opts)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "paper not cached", http.StatusNotFound)
+			return
+		}
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if v := r.URL.Query().Get("min_weight"); v != "" {
+			minWeight, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				http.Error(w, "invalid min_weight", http.StatusBadRequest)
+				return
+			}
+			filterGraph(graph, paperID, minWeight)
+		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(graph)
 		return
@@ -274,7 +345,17 @@ func (s *server) handlePaper(w http.ResponseWriter, r *http.Request) {
 			HasTitle  bool   `json:"hasTitle"`
 			HasSource bool   `json:"hasSource"`
 			CitedBy   int    `json:"citedBy"`
+
+			Weight      float64 `json:"weight"`
+			Influential bool    `json:"influential"`
 		}
+		var cached []string
+		for _, r := range dbRefs {
+			if r.HasTitle {
+				cached = append(cached, r.ID)
+			}
+		}
+		counts, _ := s.cache.CitedByCounts(ctx, cached)
 		refs := make([]refJSON, len(dbRefs))
 		for i, r := range dbRefs {
 			refs[i] = refJSON{
@@ -282,9 +363,10 @@ func (s *server) handlePaper(w http.ResponseWriter, r *http.Request) {
 				Title:     r.Title,
 				HasTitle:  r.HasTitle,
 				HasSource: r.HasSource,
-			}
-			if r.HasTitle {
-				refs[i].CitedBy, _ = s.cache.CitedByCount(ctx, r.ID)
+				CitedBy:   counts[r.ID],
+
+				Weight:      r.Weight,
+				Influential: r.Influential,
 			}
 		}
 		w.Header().Set("Content-Type", "application/json")
@@ -314,20 +396,22 @@ func (s *server) handlePaper(w http.ResponseWriter, r *http.Request) {
 	citedByCount, _ := s.cache.CitedByCount(ctx, id)
 
 	var files []string
-	if paper.SourceDownloaded && paper.SourcePath != "" {
-		filepath.WalkDir(paper.SourcePath, func(path string, d fs.DirEntry, err error) error {
-			if err != nil || d.IsDir() {
-				return nil
-			}
-			rel, _ := filepath.Rel(paper.SourcePath, path)
-			files = append(files, rel)
-			return nil
-		})
+	if paper.SourceDownloaded {
+		files, _ = s.cache.SourceFiles(ctx, id)
 	}
 
 	// Get paper list for sidebar
 	paperList, _ := s.cache.GetPaperList(ctx, id)
 
+	// Every reference, including those outside arXiv
+	bibliography, _ := s.cache.Bibliography(ctx, id)
+
+	// How each citing paper cites this one
+	contexts, _ := s.cache.CitationContextsTo(ctx, id)
+
+	// Papers co-cited with or sharing references with this one
+	related, _ := s.cache.Related(ctx, id)
+
 	// Count uncached references
 	uncachedCount := 0
 	for _, p := range paperList {
@@ -349,17 +433,89 @@ func (s *server) handlePaper(w http.ResponseWriter, r *http.Request) {
 	// Note: Client handles prefetch via /prefetch-refs endpoint
 
 	data := map[string]any{
-		"Title":          paper.Title,
-		"Paper":          paper,
-		"Files":          files,
-		"PaperList":      paperList,
-		"UncachedCount":  uncachedCount,
-		"CitedByCount":   citedByCount,
-		"FetchingSource": fetchingSource,
+		"Title":             paper.Title,
+		"Paper":             paper,
+		"Files":             files,
+		"PaperList":         paperList,
+		"Bibliography":      bibliography,
+		"Contexts":          contexts,
+		"Related":           related,
+		"InfluentialWeight": arxiv.InfluentialWeight,
+		"UncachedCount":     uncachedCount,
+		"CitedByCount":      citedByCount,
+		"FetchingSource":    fetchingSource,
 	}
 	templates.ExecuteTemplate(w, "paper", data)
 }
 
+// Limits on the graphs served, which grow quickly with depth.
+const (
+	maxGraphDepth = 3
+	maxGraphNodes = 2000
+)
+
+// graphOptions parses the parameters of /paper/{id}/graph: depth, max
+// (nodes), direction (references or citations), min_citations, categories
+// (comma-separated) and from and to (years).
+func graphOptions(q url.Values) (*arxiv.GraphOptions, error) {
+	opts := &arxiv.GraphOptions{
+		Direction: arxiv.GraphDirection(q.Get("direction")),
+	}
+	switch opts.Direction {
+	case arxiv.GraphBoth, arxiv.GraphReferences, arxiv.GraphCitations:
+	case "both":
+		opts.Direction = arxiv.GraphBoth
+	default:
+		return nil, fmt.Errorf("invalid direction %q", opts.Direction)
+	}
+	for _, p := range []struct {
+		name string
+		dst  *int
+		max  int
+	}{
+		{"depth", &opts.Depth, maxGraphDepth},
+		{"max", &opts.MaxNodes, maxGraphNodes},
+		{"min_citations", &opts.MinCitations, 0},
+		{"from", &opts.YearRange[0], 0},
+		{"to", &opts.YearRange[1], 0},
+	} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || p.max > 0 && n > p.max {
+			return nil, fmt.Errorf("invalid %s %q", p.name, v)
+		}
+		*p.dst = n
+	}
+	if v := q.Get("categories"); v != "" {
+		opts.Categories = strings.Split(v, ",")
+	}
+	return opts, nil
+}
+
+// filterGraph drops the edges of g lighter than minWeight, and the nodes
+// other than center that are left without edges.
+func filterGraph(g *arxiv.CitationGraph, center string, minWeight float64) {
+	linked := map[string]bool{center: true}
+	edges := g.Edges[:0]
+	for _, e := range g.Edges {
+		if e.Weight >= minWeight {
+			edges = append(edges, e)
+			linked[e.Source], linked[e.Target] = true, true
+		}
+	}
+	g.Edges = edges
+	nodes := g.Nodes[:0]
+	for _, n := range g.Nodes {
+		if linked[n.ID] {
+			nodes = append(nodes, n)
+		}
+	}
+	g.Nodes = nodes
+}
+
 func (s *server) handleAuthor(w http.ResponseWriter, r *http.Request) {
 	author := strings.TrimPrefix(r.URL.Path, "/author/")
 	if author == "" {
@@ -433,20 +589,24 @@ func (s *server) handlePDF(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !paper.PDFDownloaded || paper.PDFPath == "" {
+	if !paper.PDFDownloaded {
 		http.Error(w, "PDF not cached", http.StatusNotFound)
 		return
 	}
+	if !s.allowFiles(w, r, paper) {
+		return
+	}
 
-	// Verify file exists
-	if _, err := os.Stat(paper.PDFPath); err != nil {
+	pdf, info, err := s.cache.OpenPDF(ctx, paperID)
+	if err != nil {
 		http.Error(w, "PDF file not found", http.StatusNotFound)
 		return
 	}
+	defer pdf.Close()
 
 	w.Header().Set("Content-Type", "application/pdf")
 	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", paperID+".pdf"))
-	http.ServeFile(w, r, paper.PDFPath)
+	http.ServeContent(w, r, paperID+".pdf", info.ModTime, pdf)
 }
 
 func (s *server) handleSource(w http.ResponseWriter, r *http.Request) {
@@ -463,27 +623,23 @@ func (s *server) handleSource(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 	paper, err := s.cache.GetPaper(ctx, paperID)
-	if err != nil || !paper.SourceDownloaded || paper.SourcePath == "" {
+	if err != nil || !paper.SourceDownloaded {
 		http.NotFound(w, r)
 		return
 	}
-
-	// Security: ensure the requested path is within the source directory
-	fullPath := filepath.Join(paper.SourcePath, filePath)
-	fullPath = filepath.Clean(fullPath)
-	if !strings.HasPrefix(fullPath, paper.SourcePath) {
-		http.NotFound(w, r)
+	if !s.allowFiles(w, r, paper) {
 		return
 	}
 
-	// Check file exists
-	info, err := os.Stat(fullPath)
-	if err != nil || info.IsDir() {
+	// OpenSource rejects paths that escape the source directory
+	f, info, err := s.cache.OpenSource(ctx, paperID, filePath)
+	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
+	defer f.Close()
 
-	http.ServeFile(w, r, fullPath)
+	http.ServeContent(w, r, filePath, info.ModTime, f)
 }
 
 // parseAuthors splits an author string into individual author names.
@@ -670,6 +826,72 @@ func (s *server) handleCategory(w http.ResponseWriter, r *http.Request) {
 	templates.ExecuteTemplate(w, "category", data)
 }
 
+func (s *server) handleNew(w http.ResponseWriter, r *http.Request) {
+	category := strings.TrimPrefix(r.URL.Path, "/new/")
+	if category == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	day := time.Now().UTC()
+	if date := r.URL.Query().Get("date"); date != "" {
+		var err error
+		day, err = time.Parse("2006-01-02", date)
+		if err != nil {
+			http.Error(w, "invalid date", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx := r.Context()
+	listing, err := s.cache.NewSubmissions(ctx, category, day)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"Title":    "New: " + category,
+		"Category": category,
+		"Listing":  listing,
+		"Prev":     listing.Day.AddDate(0, 0, -1).Format("2006-01-02"),
+		"Next":     listing.Day.AddDate(0, 0, 1).Format("2006-01-02"),
+	}
+	templates.ExecuteTemplate(w, "new", data)
+}
+
+func (s *server) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if r.Method == http.MethodPost {
+		if err := s.cache.MarkAlertsRead(ctx); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/alerts", http.StatusSeeOther)
+		return
+	}
+
+	all := r.URL.Query().Get("all") != ""
+	alerts, err := s.cache.Alerts(ctx, !all)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	watches, err := s.cache.Watches(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"Title":   "Alerts",
+		"Alerts":  alerts,
+		"Watches": watches,
+		"All":     all,
+	}
+	templates.ExecuteTemplate(w, "alerts", data)
+}
+
 func (s *server) handleCategories(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	categories, err := s.cache.ListCategories(ctx)
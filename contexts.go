@@ -0,0 +1,190 @@
+package arxiv
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// CitationContext is one place where a paper cites a reference.
+type CitationContext struct {
+	// Key is the citation key used, as in \cite{key}
+	Key string
+
+	// Section is the heading of the top-level section the citation is in,
+	// or "" if it is not known
+	Section string
+
+	// Sentence is the sentence containing the citation, with citations
+	// shown as [key]
+	Sentence string
+}
+
+var (
+	// \cite, \citep, \citet, \citealp, \parencite, \textcite, \autocite, ...
+	texCite        = regexp.MustCompile(`\\([a-zA-Z]*cite[a-zA-Z]*)\*?((?:\s*\[[^\]]*\]){0,2})\s*\{([^}]*)\}`)
+	texSection     = regexp.MustCompile(`\\(?:chapter|section)\*?\s*(?:\[[^\]]*\])?\s*\{|\\begin\s*\{abstract\}`)
+	texSentenceEnd = regexp.MustCompile(`[.?!](?:\s+|$)|\n\s*\n|\\(?:begin|end)\s*\{[^}]*\}|\\(?:chapter|(?:sub)*section|paragraph)\*?\s*(?:\[[^\]]*\])?\s*\{(?:[^{}]|\{[^{}]*\})*\}`)
+)
+
+// CitationContexts returns the places where paper from cites paper to, in
+// the order they appear. The number of contexts is the number of mentions.
+func (c *Cache) CitationContexts(ctx context.Context, from, to string) ([]CitationContext, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT cite_key, section, sentence
+		FROM citation_contexts
+		WHERE paper_id = ? AND cite_key IN (
+			SELECT cite_key FROM paper_references
+			WHERE paper_id = ? AND arxiv_id = ? AND cite_key != ''
+		)
+		ORDER BY seq
+	`, from, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var contexts []CitationContext
+	for rows.Next() {
+		var cc CitationContext
+		if err := rows.Scan(&cc.Key, &cc.Section, &cc.Sentence); err != nil {
+			return nil, err
+		}
+		contexts = append(contexts, cc)
+	}
+	return contexts, rows.Err()
+}
+
+// CitationContextsTo returns the citation contexts of every paper citing
+// paper to, keyed by citing paper, in one query.
+func (c *Cache) CitationContextsTo(ctx context.Context, to string) (map[string][]CitationContext, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT cc.paper_id, cc.cite_key, cc.section, cc.sentence
+		FROM citation_contexts cc
+		JOIN (
+			SELECT DISTINCT paper_id, cite_key FROM paper_references
+			WHERE arxiv_id = ? AND paper_id != arxiv_id AND cite_key != ''
+		) r ON r.paper_id = cc.paper_id AND r.cite_key = cc.cite_key
+		ORDER BY cc.paper_id, cc.seq
+	`, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contexts := make(map[string][]CitationContext)
+	for rows.Next() {
+		var from string
+		var cc CitationContext
+		if err := rows.Scan(&from, &cc.Key, &cc.Section, &cc.Sentence); err != nil {
+			return nil, err
+		}
+		contexts[from] = append(contexts[from], cc)
+	}
+	return contexts, rows.Err()
+}
+
+// maxSentence bounds how far a sentence extends on either side of a
+// citation, for text that has no punctuation.
+const maxSentence = 400
+
+// extractCitationContexts finds the \cite commands in the .tex files of
+// fsys and returns one context per cited key, in order.
+func extractCitationContexts(fsys fs.FS) []CitationContext {
+	var contexts []CitationContext
+	fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".tex") {
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return nil
+		}
+		contexts = append(contexts, texCitationContexts(string(data))...)
+		return nil
+	})
+	return contexts
+}
+
+// texCitationContexts returns the citation contexts of a TeX document.
+func texCitationContexts(text string) []CitationContext {
+	text = texComment.ReplaceAllString(text, "$1")
+
+	type heading struct {
+		pos   int
+		title string
+	}
+	var headings []heading
+	for _, m := range texSection.FindAllStringIndex(text, -1) {
+		if strings.HasPrefix(text[m[0]:], `\begin`) {
+			headings = append(headings, heading{m[0], "Abstract"})
+			continue
+		}
+		end := matchBrace(text, m[1]-1)
+		headings = append(headings, heading{m[0], detex(text[m[1]:min(end, len(text))])})
+	}
+
+	var contexts []CitationContext
+	h := -1
+	for _, m := range texCite.FindAllStringSubmatchIndex(text, -1) {
+		if text[m[2]:m[3]] == "nocite" {
+			continue
+		}
+		for h+1 < len(headings) && headings[h+1].pos < m[0] {
+			h++
+		}
+		section := ""
+		if h >= 0 {
+			section = headings[h].title
+		}
+		sentence := citationSentence(text, m[0], m[1])
+		for key := range strings.SplitSeq(text[m[6]:m[7]], ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				contexts = append(contexts, CitationContext{Key: key, Section: section, Sentence: sentence})
+			}
+		}
+	}
+	return contexts
+}
+
+// citationSentence returns the sentence around text[start:end].
+func citationSentence(text string, start, end int) string {
+	from := max(0, start-maxSentence)
+	base := from
+	for _, m := range texSentenceEnd.FindAllStringIndex(text[base:start], -1) {
+		if !abbreviation(text[:base+m[0]+1]) {
+			from = base + m[1]
+		}
+	}
+	to := min(len(text), end+maxSentence)
+	for _, m := range texSentenceEnd.FindAllStringIndex(text[end:to], -1) {
+		if !abbreviation(text[:end+m[0]+1]) {
+			to = end + m[0] + 1
+			break
+		}
+	}
+
+	sentence := texCite.ReplaceAllStringFunc(text[from:to], func(cite string) string {
+		m := texCite.FindStringSubmatch(cite)
+		return "[" + strings.Join(strings.Fields(strings.ReplaceAll(m[3], ",", " ")), ", ") + "]"
+	})
+	return detex(sentence)
+}
+
+// abbreviation reports whether text ends with an abbreviation whose
+// period does not end a sentence, such as "et al." or "Fig.".
+func abbreviation(text string) bool {
+	if !strings.HasSuffix(text, ".") {
+		return false
+	}
+	i := strings.LastIndexAny(text[:len(text)-1], " \t\n~({")
+	word := text[i+1:]
+	switch strings.ToLower(word) {
+	case "al.", "e.g.", "i.e.", "cf.", "vs.", "fig.", "figs.", "eq.", "eqs.", "sec.", "tab.", "ref.", "refs.", "resp.", "approx.", "etc.":
+		return true
+	}
+	// Initials, as in "A. Vaswani"
+	return len(word) == 2 && word[0] >= 'A' && word[0] <= 'Z'
+}
@@ -0,0 +1,187 @@
+package arxiv
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestTexCitationContexts(t *testing.T) {
+	tests := []struct {
+		name, text string
+		want       []CitationContext
+	}{
+		{
+			name: "sections",
+			text: `\begin{abstract}
+We build on transformers~\cite{vaswani2017}.
+\end{abstract}
+\section{Introduction}
+Deep networks are hard to train. Residual connections help \citep{he2016}. They are common.
+\section[Method]{Our Method}
+We follow \citet[Sec.~3]{vaswani2017}.`,
+			want: []CitationContext{
+				{"vaswani2017", "Abstract", "We build on transformers [vaswani2017]."},
+				{"he2016", "Introduction", "Residual connections help [he2016]."},
+				{"vaswani2017", "Our Method", "We follow [vaswani2017]."},
+			},
+		},
+		{
+			name: "several keys",
+			text: `Attention is everywhere \cite{a, b,c}.`,
+			want: []CitationContext{
+				{"a", "", "Attention is everywhere [a, b, c]."},
+				{"b", "", "Attention is everywhere [a, b, c]."},
+				{"c", "", "Attention is everywhere [a, b, c]."},
+			},
+		},
+		{
+			name: "abbreviations",
+			text: `First sentence. As shown by Vaswani et al. \cite{v} and in Fig. 2, e.g. here, it works. Next.`,
+			want: []CitationContext{
+				{"v", "", "As shown by Vaswani et al. [v] and in Fig. 2, e.g. here, it works."},
+			},
+		},
+		{
+			name: "paragraph break",
+			text: "An unfinished thought\n\nTransformers \\cite{v} are used\n\nelsewhere",
+			want: []CitationContext{
+				{"v", "", "Transformers [v] are used"},
+			},
+		},
+		{
+			name: "subsection heading inside a section",
+			text: `\section{Related Work}
+\subsection{Attention}
+Transformers \cite{v} use attention.`,
+			want: []CitationContext{
+				{"v", "Related Work", "Transformers [v] use attention."},
+			},
+		},
+		{
+			name: "comments and nocite",
+			text: `% Old text \cite{old}.
+Kept \cite{k}. % and \cite{dropped}
+\nocite{*}`,
+			want: []CitationContext{
+				{"k", "", "Kept [k]."},
+			},
+		},
+		{
+			name: "no citations",
+			text: `\section{Introduction} Nothing cited here.`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := texCitationContexts(tt.text)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("texCitationContexts =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractCitationContexts(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.tex":           {Data: []byte("\\input{sections/intro}\n\nSee \\cite{a}.")},
+		"sections/intro.TEX": {Data: []byte(`Intro \cite{b}.`)},
+		"refs.bib":           {Data: []byte(`@article{a, note = {\cite{c}}}`)},
+	}
+	got := extractCitationContexts(fsys)
+	want := []CitationContext{
+		{Key: "a", Sentence: "See [a]."},
+		{Key: "b", Sentence: "Intro [b]."},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("extractCitationContexts = %q, want %q", got, want)
+	}
+}
+
+func TestCitationContexts(t *testing.T) {
+	ctx := context.Background()
+	c, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.insertPapers(ctx, []Paper{{ID: "1706.03762"}, {ID: "1512.03385"}}); err != nil {
+		t.Fatal(err)
+	}
+	store := func(id string, refs []BibReference, contexts []CitationContext) {
+		t.Helper()
+		if err := c.storeReferences(ctx, id, refs, contexts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store("2301.00001", []BibReference{
+		{Position: 1, Key: "v", ArxivID: "1706.03762"},
+		{Position: 2, Key: "h", ArxivID: "1512.03385"},
+		{Position: 3, Key: "x", Title: "Not on arXiv"},
+	}, []CitationContext{
+		{"v", "Introduction", "Transformers [v]."},
+		{"x", "Introduction", "Other work [x]."},
+		{"v", "Method", "We follow [v] and [h]."},
+		{"h", "Method", "We follow [v] and [h]."},
+	})
+	store("2301.00002", []BibReference{
+		{Position: 1, Key: "vaswani", ArxivID: "1706.03762"},
+		{Position: 2, Key: "", ArxivID: "1512.03385"},
+	}, []CitationContext{
+		{"vaswani", "", "Attention [vaswani]."},
+		{"", "", "Keyless."},
+	})
+
+	tests := []struct {
+		name, from, to string
+		want           []CitationContext
+	}{
+		{
+			name: "in order",
+			from: "2301.00001", to: "1706.03762",
+			want: []CitationContext{
+				{"v", "Introduction", "Transformers [v]."},
+				{"v", "Method", "We follow [v] and [h]."},
+			},
+		},
+		{
+			name: "shared sentence",
+			from: "2301.00001", to: "1512.03385",
+			want: []CitationContext{{"h", "Method", "We follow [v] and [h]."}},
+		},
+		{
+			name: "no key",
+			from: "2301.00002", to: "1512.03385",
+		},
+		{
+			name: "not cited",
+			from: "2301.00002", to: "2301.00001",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.CitationContexts(ctx, tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("CitationContexts(%s, %s) = %q, want %q", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+
+	got, err := c.CitationContextsTo(ctx, "1706.03762")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]CitationContext{
+		"2301.00001": {{"v", "Introduction", "Transformers [v]."}, {"v", "Method", "We follow [v] and [h]."}},
+		"2301.00002": {{"vaswani", "", "Attention [vaswani]."}},
+	}
+	if !maps.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("CitationContextsTo = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,226 @@
+package arxiv
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Content-addressed storage for extracted source files.
+//
+// Thousands of source tarballs ship identical copies of conference style
+// files, bibliography styles and logos. When dedup is enabled, every
+// extracted file is hashed and stored once under objects/ in the cache root,
+// and each paper's source tree holds a hard link to the shared object.
+// Source trees are treated as read-only; editing a linked file in place
+// changes it for every paper that shares it.
+
+const dedupStateKey = "dedup"
+
+// DedupEnabled reports whether newly extracted sources are deduplicated.
+func (c *Cache) DedupEnabled(ctx context.Context) bool {
+	var v string
+	err := c.db.QueryRowContext(ctx, "SELECT value FROM sync_state WHERE key = ?", dedupStateKey).Scan(&v)
+	return err == nil && v == "1"
+}
+
+// SetDedup enables or disables deduplication of newly extracted sources.
+// Existing source trees are left as they are; use Dedup to convert them.
+func (c *Cache) SetDedup(ctx context.Context, enabled bool) error {
+	v := "0"
+	if enabled {
+		v = "1"
+	}
+	_, err := c.db.ExecContext(ctx, "INSERT OR REPLACE INTO sync_state (key, value) VALUES (?, ?)", dedupStateKey, v)
+	return err
+}
+
+// Dedup enables deduplication and converts every downloaded source tree in
+// place, replacing duplicate files with hard links into the object store.
+func (c *Cache) Dedup(ctx context.Context, progress func(paperID string, done, total int)) error {
+	if _, ok := c.blobs.(*FileStore); !ok {
+		return errors.New("dedup requires a local file store")
+	}
+	if err := c.SetDedup(ctx, true); err != nil {
+		return err
+	}
+
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT id, src_path FROM papers
+		WHERE src_downloaded = 1 AND src_path IS NOT NULL
+		  AND id NOT IN (SELECT DISTINCT paper_id FROM pack_entries)
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type paper struct {
+		id      string
+		srcPath string
+	}
+	var papers []paper
+	for rows.Next() {
+		var p paper
+		if err := rows.Scan(&p.id, &p.srcPath); err != nil {
+			return err
+		}
+		papers = append(papers, p)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i, p := range papers {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		if progress != nil {
+			progress(p.id, i+1, len(papers))
+		}
+		if err := c.dedupTree(ctx, p.id, p.srcPath); err != nil {
+			return fmt.Errorf("dedup %s: %w", p.id, err)
+		}
+	}
+
+	return nil
+}
+
+// DedupStats describes the content-addressed object store.
+type DedupStats struct {
+	Files        int64 // Source files tracked in the store
+	Objects      int64 // Distinct objects stored
+	LogicalBytes int64 // Bytes the files would occupy without dedup
+	StoredBytes  int64 // Bytes actually stored
+}
+
+// Ratio returns the dedup ratio (logical bytes per stored byte).
+func (s DedupStats) Ratio() float64 {
+	if s.StoredBytes == 0 {
+		return 0
+	}
+	return float64(s.LogicalBytes) / float64(s.StoredBytes)
+}
+
+// DedupStats returns statistics about the object store.
+func (c *Cache) DedupStats(ctx context.Context) (*DedupStats, error) {
+	var s DedupStats
+	err := c.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(o.size), 0)
+		FROM source_files f JOIN objects o ON f.hash = o.hash
+	`).Scan(&s.Files, &s.LogicalBytes)
+	if err != nil {
+		return nil, err
+	}
+	err = c.db.QueryRowContext(ctx, "SELECT COUNT(*), COALESCE(SUM(size), 0) FROM objects").Scan(&s.Objects, &s.StoredBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// objectPath returns where the object with the given hash is stored. Objects
+// live beside the source trees so that they can be hard linked.
+func (c *Cache) objectPath(sum string) string {
+	root := c.root
+	if local, ok := c.blobs.(*FileStore); ok {
+		root = local.root
+	}
+	return filepath.Join(root, "objects", sum[:2], sum[2:])
+}
+
+// dedupTree links every regular file under dir into the object store.
+func (c *Cache) dedupTree(ctx context.Context, paperID, dir string) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		return c.dedupFile(ctx, tx, paperID, filepath.ToSlash(rel), path)
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// dedupFile stores path in the object store (or links it to an existing
+// object with the same content) and records it in source_files.
+func (c *Cache) dedupFile(ctx context.Context, tx *sql.Tx, paperID, rel, path string) error {
+	sum, size, err := hashFile(path)
+	if err != nil {
+		return err
+	}
+
+	obj := c.objectPath(sum)
+	objInfo, err := os.Stat(obj)
+	switch {
+	case err == nil:
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if !os.SameFile(info, objInfo) {
+			// Replace the file with a link to the existing object.
+			tmp := path + ".dedup"
+			if err := os.Link(obj, tmp); err != nil {
+				return err
+			}
+			if err := os.Rename(tmp, path); err != nil {
+				os.Remove(tmp)
+				return err
+			}
+		}
+	case os.IsNotExist(err):
+		if err := os.MkdirAll(filepath.Dir(obj), 0755); err != nil {
+			return err
+		}
+		if err := os.Link(path, obj); err != nil {
+			return err
+		}
+	default:
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO objects (hash, size) VALUES (?, ?)", sum, size); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT OR REPLACE INTO source_files (paper_id, path, hash) VALUES (?, ?, ?)",
+		paperID, rel, sum)
+	return err
+}
+
+func hashFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
@@ -0,0 +1,102 @@
+package arxiv
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDedup(t *testing.T) {
+	ctx := context.Background()
+	c, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	local := c.blobs.(*FileStore)
+
+	trees := map[string]map[string]string{
+		"2301.00001": {"main.tex": "first", "neurips.sty": "style", "logo.txt": "logo"},
+		"2301.00002": {"main.tex": "second", "styles/neurips.sty": "style", "copy.txt": "logo"},
+	}
+	for id, tree := range trees {
+		dir := local.Path(sourceKey(id))
+		for name, data := range tree {
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := c.insertPapers(ctx, []Paper{{ID: id}}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.db.Exec("UPDATE papers SET src_downloaded = 1, src_path = ? WHERE id = ?", dir, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.Dedup(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !c.DedupEnabled(ctx) {
+		t.Error("Dedup did not enable dedup")
+	}
+	stats, err := c.DedupStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (DedupStats{Files: 6, Objects: 4, LogicalBytes: 29, StoredBytes: 20}); *stats != want {
+		t.Errorf("stats = %+v, want %+v", *stats, want)
+	}
+
+	tests := []struct {
+		a, b   string // Keys below src/2301
+		shared bool
+	}{
+		{"2301.00001/neurips.sty", "2301.00002/styles/neurips.sty", true},
+		{"2301.00001/logo.txt", "2301.00002/copy.txt", true},
+		{"2301.00001/main.tex", "2301.00002/main.tex", false},
+	}
+	for _, tt := range tests {
+		a, err := os.Stat(local.Path("src/2301/" + tt.a))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.Stat(local.Path("src/2301/" + tt.b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if os.SameFile(a, b) != tt.shared {
+			t.Errorf("%s and %s share an inode: %v, want %v", tt.a, tt.b, !tt.shared, tt.shared)
+		}
+	}
+
+	// Deleting one copy leaves the other, and the object, in place.
+	if err := c.blobs.Delete(ctx, "src/2301/2301.00001/neurips.sty"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.db.Exec("DELETE FROM source_files WHERE paper_id = '2301.00001' AND path = 'neurips.sty'"); err != nil {
+		t.Fatal(err)
+	}
+	gc, err := c.GC(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gc.Objects != 0 {
+		t.Errorf("GC removed %d objects still in use", gc.Objects)
+	}
+	rc, _, err := c.OpenSource(ctx, "2301.00002", "styles/neurips.sty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(data) != "style" {
+		t.Errorf("remaining copy = %q, %v; want %q", data, err, "style")
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"compress/gzip"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -14,12 +16,22 @@ import (
 	"time"
 )
 
+// ErrTooLarge is returned for downloads larger than DownloadOptions.MaxSize.
+var ErrTooLarge = errors.New("download too large")
+
+// ErrLicense is returned for papers whose license DownloadOptions.LicenseFilter
+// does not allow.
+var ErrLicense = errors.New("license not allowed")
+
 // DownloadOptions configures paper downloads.
 type DownloadOptions struct {
 	// Concurrency is the number of parallel downloads (default 1)
 	Concurrency int
 
-	// RateLimit is the delay between downloads (default 3s per arXiv guidelines)
+	// RateLimit is ignored.
+	//
+	// Deprecated: downloads are paced by the cache's shared per-host
+	// limiter; see Options.RateLimits.
 	RateLimit time.Duration
 
 	// DownloadPDF enables PDF downloads
@@ -28,6 +40,14 @@ type DownloadOptions struct {
 	// DownloadSource enables TeX source downloads
 	DownloadSource bool
 
+	// MaxSize skips files whose reported size exceeds this many bytes,
+	// returning ErrTooLarge (0: no limit)
+	MaxSize int64
+
+	// LicenseFilter restricts downloads to papers with these licenses,
+	// returning ErrLicense for others (default: any license)
+	LicenseFilter LicenseFilter
+
 	// Progress callback
 	Progress func(paperID string, downloaded, total int)
 }
@@ -42,9 +62,12 @@ func (c *Cache) DownloadPaper(ctx context.Context, paperID string, opts *Downloa
 	if err != nil {
 		return fmt.Errorf("get paper: %w", err)
 	}
+	if l := paper.LicenseType(); !opts.LicenseFilter.Allows(l) {
+		return fmt.Errorf("%w: %s", ErrLicense, l)
+	}
 
 	if opts.DownloadPDF && !paper.PDFDownloaded {
-		pdfPath, err := c.downloadPDF(ctx, paper)
+		pdfPath, err := c.downloadPDF(ctx, paper, opts.MaxSize)
 		if err != nil {
 			return fmt.Errorf("download pdf: %w", err)
 		}
@@ -53,15 +76,17 @@ func (c *Cache) DownloadPaper(ctx context.Context, paperID string, opts *Downloa
 	}
 
 	if opts.DownloadSource && !paper.SourceDownloaded {
-		srcPath, err := c.downloadSource(ctx, paper)
+		srcPath, err := c.downloadSource(ctx, paper, opts.MaxSize)
 		if err != nil {
 			return fmt.Errorf("download source: %w", err)
 		}
 		c.db.ExecContext(ctx, "UPDATE papers SET src_path = ?, src_downloaded = 1 WHERE id = ?",
 			srcPath, paperID)
+	}
 
-		// Extract and store citations
-		if err := c.UpdateCitations(ctx, paperID, srcPath); err != nil {
+	if (opts.DownloadPDF && !paper.PDFDownloaded) || (opts.DownloadSource && !paper.SourceDownloaded) {
+		// Extract and store citations, from the source or else the PDF
+		if err := c.indexPaper(ctx, paperID); err != nil {
 			// Non-fatal: log but don't fail the download
 			_ = err
 		}
@@ -75,19 +100,21 @@ func (c *Cache) GetPaper(ctx context.Context, id string) (*Paper, error) {
 	row := c.db.QueryRowContext(ctx, `
 		SELECT id, created, updated, title, abstract, authors, categories,
 		       comments, journal_ref, doi, license, pdf_path, src_path,
-		       pdf_downloaded, src_downloaded
+		       pdf_downloaded, src_downloaded, primary_category, version,
+		       metadata_updated
 		FROM papers WHERE id = ?
 	`, id)
 
 	var p Paper
 	var created, updated string
-	var pdfPath, srcPath sql.NullString
+	var pdfPath, srcPath, primary, metaUpdated sql.NullString
 	var pdfDl, srcDl int
+	var version sql.NullInt64
 
 	err := row.Scan(
 		&p.ID, &created, &updated, &p.Title, &p.Abstract, &p.Authors,
 		&p.Categories, &p.Comments, &p.JournalRef, &p.DOI, &p.License,
-		&pdfPath, &srcPath, &pdfDl, &srcDl,
+		&pdfPath, &srcPath, &pdfDl, &srcDl, &primary, &version, &metaUpdated,
 	)
 	if err != nil {
 		return nil, err
@@ -99,25 +126,26 @@ func (c *Cache) GetPaper(ctx context.Context, id string) (*Paper, error) {
 	p.SourcePath = srcPath.String
 	p.PDFDownloaded = pdfDl == 1
 	p.SourceDownloaded = srcDl == 1
+	p.Primary = primary.String
+	p.Version = int(version.Int64)
+	p.MetadataUpdated, _ = time.Parse(time.RFC3339, metaUpdated.String)
+
+	if err := c.loadAuthors(ctx, &p); err != nil {
+		return nil, err
+	}
 
 	return &p, nil
 }
 
-func (c *Cache) downloadPDF(ctx context.Context, paper *Paper) (string, error) {
+func (c *Cache) downloadPDF(ctx context.Context, paper *Paper, maxSize int64) (string, error) {
 	// Organize by paper ID prefix for large-scale storage
 	// e.g., 2301.00001 -> pdf/2301/2301.00001.pdf
-	prefix := paperPrefix(paper.ID)
-	dir := filepath.Join(c.root, "pdf", prefix)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return "", err
-	}
-
-	path := filepath.Join(dir, paper.ID+".pdf")
-	if _, err := os.Stat(path); err == nil {
-		return path, nil // Already exists
+	key := pdfKey(paper.ID)
+	if _, err := c.blobs.Stat(ctx, key); err == nil {
+		return c.blobPath(key), nil // Already exists
 	}
 
-	resp, err := httpGetWithContext(ctx, paper.PDFURL())
+	resp, err := c.httpGet(ctx, c.downloadURL+"/pdf/"+paper.ID+".pdf")
 	if err != nil {
 		return "", err
 	}
@@ -126,36 +154,42 @@ func (c *Cache) downloadPDF(ctx context.Context, paper *Paper) (string, error) {
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("http %s", resp.Status)
 	}
-
-	f, err := os.Create(path)
-	if err != nil {
-		return "", err
+	if maxSize > 0 && resp.ContentLength > maxSize {
+		return "", fmt.Errorf("%w: %d bytes", ErrTooLarge, resp.ContentLength)
 	}
-	defer f.Close()
 
-	_, err = io.Copy(f, resp.Body)
-	if err != nil {
-		os.Remove(path)
+	if err := c.blobs.Put(ctx, key, limitBody(resp.Body, maxSize)); err != nil {
 		return "", err
 	}
 
-	return path, nil
+	return c.blobPath(key), nil
 }
 
-func (c *Cache) downloadSource(ctx context.Context, paper *Paper) (string, error) {
-	prefix := paperPrefix(paper.ID)
-	dir := filepath.Join(c.root, "src", prefix)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return "", err
+func (c *Cache) downloadSource(ctx context.Context, paper *Paper, maxSize int64) (string, error) {
+	key := sourceKey(paper.ID)
+	if files, err := c.blobs.List(ctx, key+"/"); err == nil && len(files) > 0 {
+		return c.blobPath(key), nil // Already exists
 	}
 
-	// Source files are typically gzipped tar archives
-	srcDir := filepath.Join(dir, paper.ID)
-	if _, err := os.Stat(srcDir); err == nil {
-		return srcDir, nil // Already exists
+	// Sources are extracted in place for a local store, and staged in a
+	// temporary directory and uploaded file by file otherwise.
+	local, isLocal := c.blobs.(*FileStore)
+	var srcDir string
+	if isLocal {
+		srcDir = local.Path(key)
+		if err := os.MkdirAll(filepath.Dir(srcDir), 0755); err != nil {
+			return "", err
+		}
+	} else {
+		staging, err := os.MkdirTemp("", "arxiv-src-*")
+		if err != nil {
+			return "", err
+		}
+		defer os.RemoveAll(staging)
+		srcDir = filepath.Join(staging, "src")
 	}
 
-	resp, err := httpGetWithContext(ctx, paper.SourceURL())
+	resp, err := c.httpGet(ctx, c.downloadURL+"/e-print/"+paper.ID)
 	if err != nil {
 		return "", err
 	}
@@ -164,6 +198,9 @@ func (c *Cache) downloadSource(ctx context.Context, paper *Paper) (string, error
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("http %s", resp.Status)
 	}
+	if maxSize > 0 && resp.ContentLength > maxSize {
+		return "", fmt.Errorf("%w: %d bytes", ErrTooLarge, resp.ContentLength)
+	}
 
 	// Create temp file to determine content type
 	tmpFile, err := os.CreateTemp("", "arxiv-src-*")
@@ -173,7 +210,7 @@ func (c *Cache) downloadSource(ctx context.Context, paper *Paper) (string, error
 	tmpPath := tmpFile.Name()
 	defer os.Remove(tmpPath)
 
-	_, err = io.Copy(tmpFile, resp.Body)
+	_, err = io.Copy(tmpFile, limitBody(resp.Body, maxSize))
 	tmpFile.Close()
 	if err != nil {
 		return "", err
@@ -196,9 +233,177 @@ func (c *Cache) downloadSource(ctx context.Context, paper *Paper) (string, error
 		}
 	}
 
+	if !isLocal {
+		if err := c.putTree(ctx, key, srcDir); err != nil {
+			return "", fmt.Errorf("store source: %w", err)
+		}
+		return c.blobPath(key), nil
+	}
+
+	if c.DedupEnabled(ctx) {
+		if err := c.dedupTree(ctx, paper.ID, srcDir); err != nil {
+			return "", fmt.Errorf("dedup: %w", err)
+		}
+	}
+
 	return srcDir, nil
 }
 
+// limitBody limits a response body to maxSize bytes (0: no limit), failing
+// with ErrTooLarge past it: chunked responses have no Content-Length to
+// check up front.
+func limitBody(body io.Reader, maxSize int64) io.Reader {
+	if maxSize <= 0 {
+		return body
+	}
+	return &sizeLimitReader{r: io.LimitReader(body, maxSize+1), max: maxSize}
+}
+
+type sizeLimitReader struct {
+	r   io.Reader
+	n   int64
+	max int64
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.max {
+		return n, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, l.max)
+	}
+	return n, err
+}
+
+// putTree uploads every regular file under dir to the blob store below key.
+func (c *Cache) putTree(ctx context.Context, key, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return c.blobs.Put(ctx, key+"/"+filepath.ToSlash(rel), f)
+	})
+}
+
+// OpenPDF opens a paper's downloaded PDF.
+func (c *Cache) OpenPDF(ctx context.Context, paperID string) (io.ReadSeekCloser, *BlobInfo, error) {
+	return c.openBlob(ctx, pdfKey(paperID))
+}
+
+// OpenSource opens a file from a paper's downloaded source tree.
+// The name is slash-separated and relative to the source root.
+func (c *Cache) OpenSource(ctx context.Context, paperID, name string) (io.ReadSeekCloser, *BlobInfo, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return nil, nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if ps, err := c.packStoreFor(ctx, c.db, paperID); err != nil {
+		return nil, nil, err
+	} else if ps != nil {
+		return openBlob(ctx, ps, name)
+	}
+	return openBlob(ctx, c.blobs, sourceKey(paperID)+"/"+name)
+}
+
+func (c *Cache) openBlob(ctx context.Context, key string) (io.ReadSeekCloser, *BlobInfo, error) {
+	return openBlob(ctx, c.blobs, key)
+}
+
+func openBlob(ctx context.Context, store BlobStore, key string) (io.ReadSeekCloser, *BlobInfo, error) {
+	info, err := store.Stat(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	rc, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rc, info, nil
+}
+
+// packStoreFor returns a store over the paper's packed source files,
+// or nil if the paper's source is not packed.
+func (c *Cache) packStoreFor(ctx context.Context, db dbtx, paperID string) (*packStore, error) {
+	local, ok := c.blobs.(*FileStore)
+	if !ok {
+		return nil, nil
+	}
+	entries, err := packEntries(ctx, db, paperID)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return newPackStore(local, entries), nil
+}
+
+// SourceFiles lists the files in a paper's downloaded source tree,
+// relative to the source root.
+func (c *Cache) SourceFiles(ctx context.Context, paperID string) ([]string, error) {
+	store, prefix := c.blobs, sourceKey(paperID)+"/"
+	if ps, err := c.packStoreFor(ctx, c.db, paperID); err != nil {
+		return nil, err
+	} else if ps != nil {
+		store, prefix = ps, ""
+	}
+	blobs, err := store.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, len(blobs))
+	for i, b := range blobs {
+		files[i] = strings.TrimPrefix(b.Key, prefix)
+	}
+	return files, nil
+}
+
+// SourceFS returns a read-only view of a paper's downloaded source tree,
+// whether it is extracted, packed, or held in a remote store.
+func (c *Cache) SourceFS(ctx context.Context, paperID string) (fs.FS, error) {
+	return c.sourceFS(ctx, c.db, paperID)
+}
+
+// sourceFS is SourceFS, looking up packed files in db.
+func (c *Cache) sourceFS(ctx context.Context, db dbtx, paperID string) (fs.FS, error) {
+	if ps, err := c.packStoreFor(ctx, db, paperID); err != nil {
+		return nil, err
+	} else if ps != nil {
+		return newBlobFS(ctx, ps, "")
+	}
+
+	key := sourceKey(paperID)
+	if local, ok := c.blobs.(*FileStore); ok {
+		dir := local.Path(key)
+		if _, err := os.Stat(dir); err != nil {
+			return nil, err
+		}
+		return os.DirFS(dir), nil
+	}
+	return newBlobFS(ctx, c.blobs, key+"/")
+}
+
+// blobPath returns the value recorded in pdf_path and src_path for key:
+// a filesystem path for a local store, and the key itself otherwise.
+func (c *Cache) blobPath(key string) string {
+	if local, ok := c.blobs.(*FileStore); ok {
+		return local.Path(key)
+	}
+	return key
+}
+
+func pdfKey(id string) string {
+	return "pdf/" + paperPrefix(id) + "/" + id + ".pdf"
+}
+
+func sourceKey(id string) string {
+	return "src/" + paperPrefix(id) + "/" + id
+}
+
 func extractSource(srcPath, dstDir string) error {
 	f, err := os.Open(srcPath)
 	if err != nil {
@@ -0,0 +1,319 @@
+package arxiv
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Export formats accepted by EncodePapers.
+const (
+	FormatJSON    = "json"     // A JSON array of papers
+	FormatJSONL   = "jsonl"    // One JSON object per line
+	FormatCSV     = "csv"      // CSV with a header row
+	FormatBibTeX  = "bibtex"   // BibTeX entries as exported by arXiv
+	FormatRIS     = "ris"      // RIS records
+	FormatCSLJSON = "csl-json" // A CSL-JSON array, as used by Pandoc and Zotero
+)
+
+// ExportFormats lists the formats accepted by EncodePapers.
+var ExportFormats = []string{FormatJSON, FormatJSONL, FormatCSV, FormatBibTeX, FormatRIS, FormatCSLJSON}
+
+// EncodePapers writes papers to w in the given format.
+func EncodePapers(w io.Writer, format string, papers []Paper) error {
+	switch format {
+	case FormatJSON:
+		records := make([]paperRecord, len(papers))
+		for i := range papers {
+			records[i] = newPaperRecord(&papers[i])
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+
+	case FormatJSONL:
+		enc := json.NewEncoder(w)
+		for i := range papers {
+			if err := enc.Encode(newPaperRecord(&papers[i])); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{
+			"id", "version", "title", "authors", "categories", "primary_category",
+			"created", "updated", "doi", "journal_ref", "license", "url",
+		})
+		for i := range papers {
+			p := &papers[i]
+			version := ""
+			if p.Version > 0 {
+				version = strconv.Itoa(p.Version)
+			}
+			cw.Write([]string{
+				p.ID, version, normalizeSpace(p.Title), strings.Join(p.AuthorNames(), "; "),
+				p.Categories, p.PrimaryCategory(), formatDate(p.Created),
+				formatDate(p.Updated), p.DOI, p.JournalRef, p.LicenseType().String(),
+				p.AbstractURL(),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+
+	case FormatBibTeX:
+		for i := range papers {
+			if i > 0 {
+				io.WriteString(w, "\n")
+			}
+			if _, err := io.WriteString(w, papers[i].BibTeX()); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case FormatRIS:
+		for i := range papers {
+			if i > 0 {
+				io.WriteString(w, "\n")
+			}
+			if _, err := io.WriteString(w, papers[i].RIS()); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case FormatCSLJSON:
+		items := make([]cslItem, len(papers))
+		for i := range papers {
+			items[i] = newCSLItem(&papers[i])
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	}
+	return fmt.Errorf("unknown format %q (want one of %s)", format, strings.Join(ExportFormats, ", "))
+}
+
+// paperRecord is the JSON form of a Paper.
+type paperRecord struct {
+	ID               string   `json:"id"`
+	Version          int      `json:"version,omitempty"`
+	Title            string   `json:"title"`
+	Authors          []string `json:"authors"`
+	Abstract         string   `json:"abstract,omitempty"`
+	Categories       []string `json:"categories"`
+	PrimaryCategory  string   `json:"primary_category,omitempty"`
+	Comments         string   `json:"comments,omitempty"`
+	JournalRef       string   `json:"journal_ref,omitempty"`
+	DOI              string   `json:"doi,omitempty"`
+	License          string   `json:"license,omitempty"`
+	LicenseType      string   `json:"license_type"`
+	Created          string   `json:"created,omitempty"`
+	Updated          string   `json:"updated,omitempty"`
+	URL              string   `json:"url"`
+	PDFDownloaded    bool     `json:"pdf_downloaded"`
+	SourceDownloaded bool     `json:"source_downloaded"`
+}
+
+func newPaperRecord(p *Paper) paperRecord {
+	return paperRecord{
+		ID:               p.ID,
+		Version:          p.Version,
+		Title:            normalizeSpace(p.Title),
+		Authors:          p.AuthorNames(),
+		Abstract:         strings.TrimSpace(p.Abstract),
+		Categories:       p.CategoryList(),
+		PrimaryCategory:  p.PrimaryCategory(),
+		Comments:         p.Comments,
+		JournalRef:       p.JournalRef,
+		DOI:              p.DOI,
+		License:          p.License,
+		LicenseType:      p.LicenseType().String(),
+		Created:          formatDate(p.Created),
+		Updated:          formatDate(p.Updated),
+		URL:              p.AbstractURL(),
+		PDFDownloaded:    p.PDFDownloaded,
+		SourceDownloaded: p.SourceDownloaded,
+	}
+}
+
+// formatDate formats t as YYYY-MM-DD, or "" if it is zero.
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// BibTeX returns a BibTeX entry for the paper in the style of arXiv's own
+// export:
+//
+//	@misc{vaswani2017attentionneed,
+//	      title={Attention Is All You Need},
+//	      author={Ashish Vaswani and Noam Shazeer and ...},
+//	      year={2017},
+//	      eprint={1706.03762},
+//	      archivePrefix={arXiv},
+//	      primaryClass={cs.CL},
+//	      url={https://arxiv.org/abs/1706.03762},
+//	}
+func (p *Paper) BibTeX() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "@misc{%s,\n", p.BibTeXKey())
+	fmt.Fprintf(&b, "      title={%s},\n", normalizeSpace(p.Title))
+	fmt.Fprintf(&b, "      author={%s},\n", strings.Join(p.AuthorNames(), " and "))
+	fmt.Fprintf(&b, "      year={%d},\n", p.Created.Year())
+	fmt.Fprintf(&b, "      eprint={%s},\n", p.ID)
+	fmt.Fprintf(&b, "      archivePrefix={arXiv},\n")
+	if cat := p.PrimaryCategory(); cat != "" {
+		fmt.Fprintf(&b, "      primaryClass={%s},\n", cat)
+	}
+	fmt.Fprintf(&b, "      url={%s},\n", p.AbstractURL())
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// BibTeXKey returns the citation key used by BibTeX: the first author's
+// family name, the year, and the first two significant words of the title,
+// as arXiv generates them (e.g., vaswani2017attentionneed).
+func (p *Paper) BibTeXKey() string {
+	key := ""
+	if names := p.AuthorNames(); len(names) > 0 {
+		family, _ := splitName(names[0])
+		key = keyWord(family)
+	}
+	key += strconv.Itoa(p.Created.Year())
+	n := 0
+	for _, w := range strings.Fields(p.Title) {
+		w = keyWord(w)
+		if w == "" || bibStopWords[w] {
+			continue
+		}
+		key += w
+		if n++; n == 2 {
+			break
+		}
+	}
+	return key
+}
+
+var bibStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "by": true,
+	"for": true, "from": true, "in": true, "is": true, "of": true, "on": true, "or": true,
+	"the": true, "to": true, "via": true, "with": true, "all": true, "you": true,
+}
+
+// keyWord lower-cases s and drops everything but ASCII letters.
+func keyWord(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if r >= 'a' && r <= 'z' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// RIS returns an RIS record for the paper.
+func (p *Paper) RIS() string {
+	var b strings.Builder
+	line := func(tag, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "%s  - %s\n", tag, value)
+		}
+	}
+	if p.JournalRef != "" {
+		line("TY", "JOUR")
+	} else {
+		line("TY", "UNPB")
+	}
+	line("TI", normalizeSpace(p.Title))
+	for _, name := range p.AuthorNames() {
+		family, given := splitName(name)
+		if given != "" {
+			family += ", " + given
+		}
+		line("AU", family)
+	}
+	line("PY", strconv.Itoa(p.Created.Year()))
+	line("DA", p.Created.Format("2006/01/02"))
+	line("AB", normalizeSpace(p.Abstract))
+	for _, cat := range p.CategoryList() {
+		line("KW", cat)
+	}
+	line("DO", p.DOI)
+	line("N1", p.JournalRef)
+	line("AN", "arXiv:"+p.ID)
+	line("UR", p.AbstractURL())
+	b.WriteString("ER  - \n")
+	return b.String()
+}
+
+// cslItem is a CSL-JSON item.
+type cslItem struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Title     string    `json:"title"`
+	Author    []cslName `json:"author,omitempty"`
+	Issued    cslDate   `json:"issued"`
+	Abstract  string    `json:"abstract,omitempty"`
+	Publisher string    `json:"publisher"`
+	Number    string    `json:"number"`
+	DOI       string    `json:"DOI,omitempty"`
+	URL       string    `json:"URL"`
+	Keyword   string    `json:"keyword,omitempty"`
+	Note      string    `json:"note,omitempty"`
+}
+
+type cslName struct {
+	Family  string `json:"family,omitempty"`
+	Given   string `json:"given,omitempty"`
+	Literal string `json:"literal,omitempty"`
+}
+
+type cslDate struct {
+	DateParts [][]int `json:"date-parts"`
+}
+
+func newCSLItem(p *Paper) cslItem {
+	item := cslItem{
+		ID:        p.BibTeXKey(),
+		Type:      "article", // CSL's type for preprints
+		Title:     normalizeSpace(p.Title),
+		Issued:    cslDate{[][]int{{p.Created.Year(), int(p.Created.Month()), p.Created.Day()}}},
+		Abstract:  normalizeSpace(p.Abstract),
+		Publisher: "arXiv",
+		Number:    "arXiv:" + p.ID,
+		DOI:       p.DOI,
+		URL:       p.AbstractURL(),
+		Keyword:   strings.Join(p.CategoryList(), ", "),
+		Note:      p.JournalRef,
+	}
+	for _, name := range p.AuthorNames() {
+		family, given := splitName(name)
+		if given == "" {
+			item.Author = append(item.Author, cslName{Literal: family})
+			continue
+		}
+		item.Author = append(item.Author, cslName{Family: family, Given: given})
+	}
+	return item
+}
+
+// splitName splits a "Given Family" name at its last space. Multi-word
+// family names and suffixes such as "Jr." are not recognized.
+func splitName(name string) (family, given string) {
+	name = strings.TrimSpace(name)
+	i := strings.LastIndexFunc(name, unicode.IsSpace)
+	if i < 0 {
+		return name, ""
+	}
+	return name[i+1:], strings.TrimSpace(name[:i])
+}
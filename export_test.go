@@ -0,0 +1,173 @@
+package arxiv
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+var exportPaper = Paper{
+	ID:         "1706.03762",
+	Version:    7,
+	Title:      "Attention Is All\n  You Need",
+	Abstract:   " The dominant sequence transduction models. ",
+	Authors:    "Ashish Vaswani, Noam Shazeer, Plato",
+	Categories: "cs.CL cs.LG",
+	Primary:    "cs.CL",
+	License:    "http://arxiv.org/licenses/nonexclusive-distrib/1.0/",
+	Created:    time.Date(2017, 6, 12, 0, 0, 0, 0, time.UTC),
+	Updated:    time.Date(2023, 8, 2, 0, 0, 0, 0, time.UTC),
+}
+
+func TestBibTeXKey(t *testing.T) {
+	tests := []struct {
+		authors, title string
+		year           int
+		want           string
+	}{
+		{"Ashish Vaswani, Noam Shazeer", "Attention Is All You Need", 2017, "vaswani2017attentionneed"},
+		{"Kaiming He", "Deep Residual Learning for Image Recognition", 2015, "he2015deepresidual"},
+		{"Jürgen Schmidhuber", "On the Use of LSTMs", 1997, "schmidhuber1997uselstms"},
+		{"", "The Title", 2020, "2020title"},
+		{"Plato", "", 2001, "plato2001"},
+	}
+	for _, tt := range tests {
+		p := Paper{Authors: tt.authors, Title: tt.title, Created: time.Date(tt.year, 1, 1, 0, 0, 0, 0, time.UTC)}
+		if got := p.BibTeXKey(); got != tt.want {
+			t.Errorf("BibTeXKey(%q, %q) = %q, want %q", tt.authors, tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestBibTeX(t *testing.T) {
+	want := `@misc{vaswani2017attentionneed,
+      title={Attention Is All You Need},
+      author={Ashish Vaswani and Noam Shazeer and Plato},
+      year={2017},
+      eprint={1706.03762},
+      archivePrefix={arXiv},
+      primaryClass={cs.CL},
+      url={https://arxiv.org/abs/1706.03762},
+}
+`
+	if got := exportPaper.BibTeX(); got != want {
+		t.Errorf("BibTeX() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRIS(t *testing.T) {
+	want := `TY  - UNPB
+TI  - Attention Is All You Need
+AU  - Vaswani, Ashish
+AU  - Shazeer, Noam
+AU  - Plato
+PY  - 2017
+DA  - 2017/06/12
+AB  - The dominant sequence transduction models.
+KW  - cs.CL
+KW  - cs.LG
+AN  - arXiv:1706.03762
+UR  - https://arxiv.org/abs/1706.03762
+` + "ER  - \n"
+	if got := exportPaper.RIS(); got != want {
+		t.Errorf("RIS() =\n%s\nwant\n%s", got, want)
+	}
+
+	p := exportPaper
+	p.JournalRef = "NeurIPS 2017"
+	if got := p.RIS(); !strings.HasPrefix(got, "TY  - JOUR\n") || !strings.Contains(got, "N1  - NeurIPS 2017\n") {
+		t.Errorf("RIS() with journal ref =\n%s", got)
+	}
+}
+
+func TestEncodePapers(t *testing.T) {
+	papers := []Paper{exportPaper, {ID: "2401.00001", Title: "Second", Authors: "A B"}}
+	tests := []struct {
+		format string
+		check  func(t *testing.T, out string)
+	}{
+		{FormatJSON, func(t *testing.T, out string) {
+			var got []paperRecord
+			if err := json.Unmarshal([]byte(out), &got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 2 {
+				t.Fatalf("got %d records, want 2", len(got))
+			}
+			r := got[0]
+			if r.Title != "Attention Is All You Need" || r.Version != 7 || r.LicenseType != "arXiv" ||
+				r.Created != "2017-06-12" || len(r.Authors) != 3 || len(r.Categories) != 2 {
+				t.Errorf("record = %+v", r)
+			}
+			if got[1].Created != "" || got[1].LicenseType != "none" {
+				t.Errorf("record = %+v", got[1])
+			}
+		}},
+		{FormatJSONL, func(t *testing.T, out string) {
+			lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+			if len(lines) != 2 {
+				t.Fatalf("got %d lines, want 2", len(lines))
+			}
+			var r paperRecord
+			if err := json.Unmarshal([]byte(lines[1]), &r); err != nil {
+				t.Fatal(err)
+			}
+			if r.ID != "2401.00001" {
+				t.Errorf("second record ID = %q", r.ID)
+			}
+		}},
+		{FormatCSV, func(t *testing.T, out string) {
+			want := "id,version,title,authors,categories,primary_category,created,updated,doi,journal_ref,license,url\n" +
+				"1706.03762,7,Attention Is All You Need,Ashish Vaswani; Noam Shazeer; Plato,cs.CL cs.LG,cs.CL,2017-06-12,2023-08-02,,,arXiv,https://arxiv.org/abs/1706.03762\n" +
+				"2401.00001,,Second,A B,,,,,,,none,https://arxiv.org/abs/2401.00001\n"
+			if out != want {
+				t.Errorf("got\n%s\nwant\n%s", out, want)
+			}
+		}},
+		{FormatBibTeX, func(t *testing.T, out string) {
+			if !strings.HasPrefix(out, exportPaper.BibTeX()+"\n@misc{b") {
+				t.Errorf("got\n%s", out)
+			}
+		}},
+		{FormatRIS, func(t *testing.T, out string) {
+			if strings.Count(out, "ER  - \n") != 2 {
+				t.Errorf("got\n%s", out)
+			}
+		}},
+		{FormatCSLJSON, func(t *testing.T, out string) {
+			var got []cslItem
+			if err := json.Unmarshal([]byte(out), &got); err != nil {
+				t.Fatal(err)
+			}
+			item := got[0]
+			if item.ID != "vaswani2017attentionneed" || item.Number != "arXiv:1706.03762" ||
+				item.Keyword != "cs.CL, cs.LG" || item.Issued.DateParts[0][1] != 6 {
+				t.Errorf("item = %+v", item)
+			}
+			wantAuthors := []cslName{{Family: "Vaswani", Given: "Ashish"}, {Family: "Shazeer", Given: "Noam"}, {Literal: "Plato"}}
+			if len(item.Author) != len(wantAuthors) {
+				t.Fatalf("authors = %+v", item.Author)
+			}
+			for i, a := range item.Author {
+				if a != wantAuthors[i] {
+					t.Errorf("author %d = %+v, want %+v", i, a, wantAuthors[i])
+				}
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := EncodePapers(&buf, tt.format, papers); err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, buf.String())
+		})
+	}
+
+	if err := EncodePapers(new(bytes.Buffer), "xml", papers); err == nil {
+		t.Error("EncodePapers with an unknown format succeeded")
+	}
+}
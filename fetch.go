@@ -6,51 +6,32 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
 
-const apiBaseURL = "https://export.arxiv.org/api/query"
+const (
+	apiBaseURL      = "https://export.arxiv.org/api/query"
+	downloadBaseURL = "https://arxiv.org"
+)
 
 // Fetch retrieves a paper's metadata directly from arXiv API and stores it.
 // This is for fetching individual papers without a full OAI-PMH sync.
+// Cached metadata is returned as is unless it is older than Options.MaxAge.
 func (c *Cache) Fetch(ctx context.Context, id string) (*Paper, error) {
-	// Check if already in cache
-	paper, err := c.GetPaper(ctx, id)
-	if err == nil {
-		return paper, nil
-	}
-
-	// Fetch from arXiv API
-	paper, err = fetchPaperMetadata(ctx, id)
+	res, err := c.FetchBatch(ctx, []string{id})
 	if err != nil {
 		return nil, err
 	}
-
-	// Store in database
-	_, err = c.db.ExecContext(ctx, `
-		INSERT OR REPLACE INTO papers
-		(id, created, updated, title, abstract, authors, categories, comments, journal_ref, doi, license, metadata_updated)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`,
-		paper.ID,
-		paper.Created.Format("2006-01-02"),
-		paper.Updated.Format("2006-01-02"),
-		paper.Title,
-		paper.Abstract,
-		paper.Authors,
-		paper.Categories,
-		paper.Comments,
-		paper.JournalRef,
-		paper.DOI,
-		paper.License,
-		time.Now().Format(time.RFC3339),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("store paper: %w", err)
+	if len(res.Found) > 0 {
+		return res.Found[0], nil
 	}
-
-	return paper, nil
+	if len(res.Missing) > 0 {
+		return nil, fmt.Errorf("%s: %s", id, res.Missing[0].Reason)
+	}
+	return nil, res.Err()
 }
 
 // FetchMetadataOnly fetches just the metadata (title, authors, abstract) without downloading source.
@@ -59,87 +40,186 @@ func (c *Cache) FetchMetadataOnly(ctx context.Context, id string) (*Paper, error
 	return c.Fetch(ctx, id) // Fetch already does metadata-only
 }
 
-// FetchBatch fetches metadata for multiple papers in a single API call.
-// arXiv API supports up to ~100 IDs per request.
-func (c *Cache) FetchBatch(ctx context.Context, ids []string) ([]*Paper, error) {
-	if len(ids) == 0 {
-		return nil, nil
-	}
-
-	// Filter out papers we already have
+// FetchBatch fetches metadata for multiple papers. Papers already in the
+// cache are returned as is unless they are older than Options.MaxAge; the
+// rest are requested from the arXiv API in batches of up to 100 IDs.
+//
+// The result reports the outcome for every ID. IDs that arXiv has no paper
+// for are remembered and not requested again for a week, or a month if the
+// paper was withdrawn. The error is non-nil only if ctx is done.
+func (c *Cache) FetchBatch(ctx context.Context, ids []string) (*BatchResult, error) {
+	res := &BatchResult{}
+
+	// Filter out papers we already have, unless they are stale
 	var missing []string
-	var existing []*Paper
+	stale := make(map[string]*Paper)
 	for _, id := range ids {
-		if paper, err := c.GetPaper(ctx, id); err == nil {
-			existing = append(existing, paper)
-		} else {
+		if !validID(id) {
+			res.Missing = append(res.Missing, MissingID{ID: id, Reason: ReasonMalformed})
+			continue
+		}
+		paper, err := c.GetPaper(ctx, id)
+		switch {
+		case err == nil && !c.isStale(paper):
+			res.Found = append(res.Found, paper)
+		case err == nil:
+			missing = append(missing, id)
+			stale[paper.ID] = paper
+		default:
+			if reason, ok := c.knownMissing(ctx, bareID(id)); ok {
+				res.Missing = append(res.Missing, MissingID{ID: id, Reason: reason})
+				continue
+			}
 			missing = append(missing, id)
 		}
 	}
 
-	if len(missing) == 0 {
-		return existing, nil
+	if err := c.fetchAll(ctx, missing, res); err != nil {
+		return res, err
 	}
 
-	// Batch fetch from arXiv API (comma-separated IDs)
-	url := fmt.Sprintf("%s?id_list=%s&max_results=%d", apiBaseURL, strings.Join(missing, ","), len(missing))
+	// Keep stale copies of papers that could not be refreshed, unless
+	// arXiv no longer has them
+	for _, p := range res.Found {
+		delete(stale, p.ID)
+	}
+	for _, m := range res.Missing {
+		delete(stale, bareID(m.ID))
+	}
+	for _, id := range missing {
+		if p, ok := stale[bareID(id)]; ok {
+			res.Found = append(res.Found, p)
+		}
+	}
+	return res, nil
+}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+// fetchAll fetches ids in batches of apiBatchSize and records the outcome
+// in res. It stops early only if ctx is done.
+func (c *Cache) fetchAll(ctx context.Context, ids []string, res *BatchResult) error {
+	for i := 0; i < len(ids); i += apiBatchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := min(i+apiBatchSize, len(ids))
+		if err := c.fetchIDs(ctx, ids[i:end], res); err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// fetchIDs fetches and stores the metadata of the given papers in a single
+// API call and records the outcome in res.
+func (c *Cache) fetchIDs(ctx context.Context, ids []string, res *BatchResult) error {
+	params := url.Values{}
+	params.Set("id_list", strings.Join(ids, ","))
+	params.Set("max_results", strconv.Itoa(len(ids)))
+	feed, err := c.fetchFeed(ctx, params)
 	if err != nil {
-		return existing, err
+		for _, id := range ids {
+			res.Errors = append(res.Errors, BatchError{ID: id, Err: err})
+		}
+		return err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	// Unknown IDs are either left out of the feed or come back as an
+	// error entry without an /abs/ URL
+	returned := make(map[string]bool)
+	var papers []*Paper
+	for _, entry := range feed.Entries {
+		p := parseAtomEntry(entry)
+		if p.ID == "" {
+			continue
+		}
+		returned[p.ID] = true
+		if isWithdrawn(p) {
+			c.markMissing(ctx, p.ID, ReasonWithdrawn)
+			res.Missing = append(res.Missing, MissingID{ID: p.ID, Reason: ReasonWithdrawn})
+			continue
+		}
+		papers = append(papers, p)
+	}
+
+	stored, errs := c.storeAPIPapers(ctx, papers)
+	res.Found = append(res.Found, stored...)
+	res.Errors = append(res.Errors, errs...)
+
+	for _, id := range ids {
+		if bare := bareID(id); !returned[bare] {
+			c.markMissing(ctx, bare, ReasonNotFound)
+			res.Missing = append(res.Missing, MissingID{ID: id, Reason: ReasonNotFound})
+		}
+	}
+	return nil
+}
+
+// fetchFeed queries the arXiv API with the given parameters.
+func (c *Cache) fetchFeed(ctx context.Context, params url.Values) (*atomFeed, error) {
+	resp, err := c.httpGet(ctx, c.apiURL+"?"+params.Encode())
 	if err != nil {
-		return existing, err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return existing, fmt.Errorf("http %s", resp.Status)
+		return nil, fmt.Errorf("http %s", resp.Status)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return existing, err
+		return nil, err
 	}
 
 	var feed atomFeed
 	if err := xml.Unmarshal(body, &feed); err != nil {
-		return existing, fmt.Errorf("parse xml: %w", err)
+		return nil, fmt.Errorf("parse xml: %w", err)
 	}
+	return &feed, nil
+}
 
-	// Store each paper
-	for _, entry := range feed.Entries {
-		paper := parseAtomEntry(entry)
+// storeAPIPapers stores papers fetched from the arXiv API and returns
+// those that were stored and those that failed. Entries without an ID are
+// skipped.
+func (c *Cache) storeAPIPapers(ctx context.Context, papers []*Paper) ([]*Paper, []BatchError) {
+	now := time.Now().Format(time.RFC3339)
+	var stored []*Paper
+	var errs []BatchError
+	for _, paper := range papers {
 		if paper.ID == "" {
 			continue
 		}
-
-		_, err = c.db.ExecContext(ctx, `
-			INSERT OR REPLACE INTO papers
-			(id, created, updated, title, abstract, authors, categories, comments, journal_ref, doi, license, metadata_updated)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		`,
-			paper.ID,
-			paper.Created.Format("2006-01-02"),
-			paper.Updated.Format("2006-01-02"),
-			paper.Title,
-			paper.Abstract,
-			paper.Authors,
-			paper.Categories,
-			paper.Comments,
-			paper.JournalRef,
-			paper.DOI,
-			paper.License,
-			time.Now().Format(time.RFC3339),
-		)
-		if err == nil {
-			existing = append(existing, paper)
+		if err := c.storePaper(ctx, paper, now); err != nil {
+			errs = append(errs, BatchError{ID: paper.ID, Err: err})
+			continue
 		}
+		stored = append(stored, paper)
+	}
+
+	return stored, errs
+}
+
+// storePaper upserts one paper and its authors in a transaction.
+func (c *Cache) storePaper(ctx context.Context, paper *Paper, now string) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	return existing, nil
+	if _, err := recordChanges(ctx, tx, paper, now); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, upsertPaperSQL, paperArgs(paper, now)...); err != nil {
+		return err
+	}
+	if err := storeAuthors(ctx, tx, paper); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM fetch_failures WHERE paper_id = ?", paper.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // PrefetchReferenceTitles fetches metadata for all uncached references of a paper.
@@ -161,26 +241,13 @@ func (c *Cache) PrefetchReferenceTitles(ctx context.Context, paperID string) err
 		return nil
 	}
 
-	// Batch fetch in chunks of 50 (arXiv limit is ~100)
-	for i := 0; i < len(uncached); i += 50 {
-		end := i + 50
-		if end > len(uncached) {
-			end = len(uncached)
-		}
-		chunk := uncached[i:end]
-
-		if _, err := c.FetchBatch(ctx, chunk); err != nil {
-			// Non-fatal, continue with next chunk
-			continue
-		}
-
-		// Rate limit between batches
-		if end < len(uncached) {
-			time.Sleep(1 * time.Second)
-		}
+	// FetchBatch splits the request into batches; requests are paced by
+	// the cache's rate limiter
+	res, err := c.FetchBatch(ctx, uncached)
+	if err != nil {
+		return err
 	}
-
-	return nil
+	return res.Err()
 }
 
 // FetchAndDownload fetches metadata and downloads source/PDF for a paper.
@@ -198,120 +265,63 @@ func (c *Cache) FetchAndDownload(ctx context.Context, id string, opts *DownloadO
 	return c.GetPaper(ctx, id)
 }
 
-func fetchPaperMetadata(ctx context.Context, id string) (*Paper, error) {
-	url := fmt.Sprintf("%s?id_list=%s", apiBaseURL, id)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("http %s", resp.Status)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var feed atomFeed
-	if err := xml.Unmarshal(body, &feed); err != nil {
-		return nil, fmt.Errorf("parse xml: %w", err)
-	}
-
-	if len(feed.Entries) == 0 {
-		return nil, fmt.Errorf("paper not found: %s", id)
-	}
-
-	entry := feed.Entries[0]
-
-	// Extract ID from the URL (e.g., http://arxiv.org/abs/2301.00001v1 -> 2301.00001)
-	paperID := id
-	if idx := strings.LastIndex(entry.ID, "/abs/"); idx >= 0 {
-		paperID = entry.ID[idx+5:]
-		// Remove version suffix
-		if vIdx := strings.LastIndex(paperID, "v"); vIdx > 0 {
-			paperID = paperID[:vIdx]
-		}
-	}
-
-	var authors []string
-	for _, a := range entry.Authors {
-		authors = append(authors, a.Name)
-	}
-
-	var categories []string
-	for _, c := range entry.Categories {
-		categories = append(categories, c.Term)
-	}
-
-	paper := &Paper{
-		ID:         paperID,
-		Title:      strings.TrimSpace(entry.Title),
-		Abstract:   strings.TrimSpace(entry.Summary),
-		Authors:    strings.Join(authors, ", "),
-		Categories: strings.Join(categories, " "),
-		Comments:   entry.Comment,
-		JournalRef: entry.JournalRef,
-		DOI:        entry.DOI,
-	}
-
-	paper.Created, _ = time.Parse(time.RFC3339, entry.Published)
-	paper.Updated, _ = time.Parse(time.RFC3339, entry.Updated)
-
-	return paper, nil
-}
-
 // Atom feed structures for arXiv API
 
 type atomFeed struct {
-	XMLName xml.Name    `xml:"feed"`
-	Entries []atomEntry `xml:"entry"`
+	XMLName      xml.Name    `xml:"feed"`
+	TotalResults int         `xml:"http://a9.com/-/spec/opensearch/1.1/ totalResults"`
+	StartIndex   int         `xml:"http://a9.com/-/spec/opensearch/1.1/ startIndex"`
+	ItemsPerPage int         `xml:"http://a9.com/-/spec/opensearch/1.1/ itemsPerPage"`
+	Entries      []atomEntry `xml:"entry"`
 }
 
 type atomEntry struct {
-	ID         string         `xml:"id"`
-	Title      string         `xml:"title"`
-	Summary    string         `xml:"summary"`
-	Authors    []atomAuthor   `xml:"author"`
-	Categories []atomCategory `xml:"category"`
-	Published  string         `xml:"published"`
-	Updated    string         `xml:"updated"`
-	Comment    string         `xml:"comment"`
-	JournalRef string         `xml:"journal_ref"`
-	DOI        string         `xml:"doi"`
+	ID              string         `xml:"id"`
+	Title           string         `xml:"title"`
+	Summary         string         `xml:"summary"`
+	Authors         []atomAuthor   `xml:"author"`
+	Categories      []atomCategory `xml:"category"`
+	PrimaryCategory atomCategory   `xml:"primary_category"`
+	Links           []atomLink     `xml:"link"`
+	Published       string         `xml:"published"`
+	Updated         string         `xml:"updated"`
+	Comment         string         `xml:"comment"`
+	JournalRef      string         `xml:"journal_ref"`
+	DOI             string         `xml:"doi"`
 }
 
 type atomAuthor struct {
-	Name string `xml:"name"`
+	Name         string   `xml:"name"`
+	Affiliations []string `xml:"affiliation"`
 }
 
 type atomCategory struct {
 	Term string `xml:"term,attr"`
 }
 
-// parseAtomEntry converts an atom entry to a Paper.
+type atomLink struct {
+	Href  string `xml:"href,attr"`
+	Rel   string `xml:"rel,attr"`
+	Type  string `xml:"type,attr"`
+	Title string `xml:"title,attr"`
+}
+
+// parseAtomEntry converts an atom entry to a Paper. The ID is empty if the
+// entry is not a paper (e.g., the API's error entry for an unknown ID).
 func parseAtomEntry(entry atomEntry) *Paper {
-	// Extract ID from the URL (e.g., http://arxiv.org/abs/2301.00001v1 -> 2301.00001)
-	paperID := ""
+	// Extract ID from the URL (e.g., http://arxiv.org/abs/2301.00001v1 -> 2301.00001, v1)
+	var paperID string
+	var version int
 	if idx := strings.LastIndex(entry.ID, "/abs/"); idx >= 0 {
-		paperID = entry.ID[idx+5:]
-		// Remove version suffix
-		if vIdx := strings.LastIndex(paperID, "v"); vIdx > 0 {
-			paperID = paperID[:vIdx]
-		}
+		paperID, version = splitVersion(entry.ID[idx+5:])
 	}
 
 	var authors []string
+	var authorList []Author
 	for _, a := range entry.Authors {
-		authors = append(authors, a.Name)
+		name := strings.TrimSpace(a.Name)
+		authors = append(authors, name)
+		authorList = append(authorList, Author{Name: name, Affiliations: a.Affiliations})
 	}
 
 	var categories []string
@@ -324,10 +334,27 @@ func parseAtomEntry(entry atomEntry) *Paper {
 		Title:      strings.TrimSpace(entry.Title),
 		Abstract:   strings.TrimSpace(entry.Summary),
 		Authors:    strings.Join(authors, ", "),
+		AuthorList: authorList,
 		Categories: strings.Join(categories, " "),
 		Comments:   entry.Comment,
 		JournalRef: entry.JournalRef,
 		DOI:        entry.DOI,
+		Version:    version,
+		Primary:    entry.PrimaryCategory.Term,
+	}
+
+	for _, l := range entry.Links {
+		switch {
+		case l.Title == "doi" && paper.DOI == "":
+			// e.g., http://dx.doi.org/10.1103/PhysRevD.76.013009
+			if idx := strings.Index(l.Href, "10."); idx >= 0 {
+				paper.DOI = l.Href[idx:]
+			}
+		case l.Rel == "alternate" && paper.Version == 0:
+			if idx := strings.LastIndex(l.Href, "/abs/"); idx >= 0 {
+				_, paper.Version = splitVersion(l.Href[idx+5:])
+			}
+		}
 	}
 
 	paper.Created, _ = time.Parse(time.RFC3339, entry.Published)
@@ -335,3 +362,23 @@ func parseAtomEntry(entry atomEntry) *Paper {
 
 	return paper
 }
+
+// splitVersion splits a versioned arXiv ID such as "2301.00001v2" or
+// "hep-th/9901001v1" into the bare ID and version number.
+func splitVersion(s string) (string, int) {
+	idx := strings.LastIndex(s, "v")
+	if idx <= 0 || idx == len(s)-1 || !isDigits(s[idx+1:]) {
+		return s, 0
+	}
+	v, err := strconv.Atoi(s[idx+1:])
+	if err != nil {
+		return s, 0
+	}
+	return s[:idx], v
+}
+
+// bareID returns id without its version suffix.
+func bareID(id string) string {
+	id, _ = splitVersion(id)
+	return id
+}
@@ -0,0 +1,201 @@
+package arxiv
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+)
+
+// GraphDirection selects which citations a graph is expanded along.
+type GraphDirection string
+
+const (
+	GraphBoth       GraphDirection = ""           // References and citing papers
+	GraphReferences GraphDirection = "references" // Papers cited
+	GraphCitations  GraphDirection = "citations"  // Papers citing
+)
+
+// DefaultGraphNodes is the default GraphOptions.MaxNodes.
+const DefaultGraphNodes = 500
+
+// GraphOptions configures GetCitationGraphWithOptions. The filters apply
+// to every node but the center.
+type GraphOptions struct {
+	// Depth is the number of citation hops expanded from the center
+	// (default 1)
+	Depth int
+
+	// MaxNodes caps the size of the graph (default DefaultGraphNodes).
+	// Each hop keeps the most cited of its new papers that fit.
+	MaxNodes int
+
+	// Direction selects the citations followed (default: both)
+	Direction GraphDirection
+
+	// MinCitations drops papers cited by fewer cached papers
+	MinCitations int
+
+	// Categories keeps only cached papers in one of these categories or
+	// archives (e.g., cs.LG or cs)
+	Categories []string
+
+	// YearRange keeps only papers from these years, inclusive; 0 leaves
+	// a bound open
+	YearRange [2]int
+}
+
+// GetCitationGraphWithOptions returns the citation graph around a paper,
+// expanded breadth-first: each hop adds the papers citing or cited by the
+// previous hop's papers, filtered and pruned to the most cited, until
+// opts.Depth hops or opts.MaxNodes nodes are reached. The graph has every
+// edge between its nodes. If the paper is not cached, the error satisfies
+// errors.Is(err, sql.ErrNoRows).
+func (c *Cache) GetCitationGraphWithOptions(ctx context.Context, paperID string, opts *GraphOptions) (*CitationGraph, error) {
+	if opts == nil {
+		opts = &GraphOptions{}
+	}
+	depth := max(opts.Depth, 1)
+	maxNodes := cmp.Or(opts.MaxNodes, DefaultGraphNodes)
+
+	if _, err := c.GetPaper(ctx, paperID); err != nil {
+		return nil, err
+	}
+	center, err := c.graphNodes(ctx, []string{paperID})
+	if err != nil {
+		return nil, err
+	}
+	graph := &CitationGraph{
+		Nodes: []GraphNode{center[paperID]},
+		Edges: []GraphEdge{},
+	}
+	included := map[string]bool{paperID: true}
+
+	frontier := []string{paperID}
+	for hop := 1; hop <= depth && len(frontier) > 0 && len(graph.Nodes) < maxNodes; hop++ {
+		var edges []GraphEdge
+		if opts.Direction != GraphCitations {
+			out, err := c.graphEdges(ctx, "from_id", frontier)
+			if err != nil {
+				return nil, err
+			}
+			edges = append(edges, out...)
+		}
+		if opts.Direction != GraphReferences {
+			in, err := c.graphEdges(ctx, "to_id", frontier)
+			if err != nil {
+				return nil, err
+			}
+			edges = append(edges, in...)
+		}
+
+		// New papers, with the weight of their citations to and from
+		// the frontier to break ties between equally cited papers
+		var ids []string
+		links := make(map[string]float64)
+		for _, e := range edges {
+			for _, id := range []string{e.Source, e.Target} {
+				if included[id] {
+					continue
+				}
+				if _, ok := links[id]; !ok {
+					ids = append(ids, id)
+				}
+				links[id] += e.Weight
+			}
+		}
+		if len(ids) == 0 {
+			break
+		}
+		nodes, err := c.graphNodes(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		ids = slices.DeleteFunc(ids, func(id string) bool { return !opts.keep(nodes[id]) })
+		slices.SortStableFunc(ids, func(a, b string) int {
+			return cmp.Or(
+				cmp.Compare(nodes[b].Citations, nodes[a].Citations),
+				cmp.Compare(links[b], links[a]),
+			)
+		})
+		ids = ids[:min(len(ids), maxNodes-len(graph.Nodes))]
+
+		for _, id := range ids {
+			n := nodes[id]
+			n.Depth = hop
+			graph.Nodes = append(graph.Nodes, n)
+			included[id] = true
+		}
+		frontier = ids
+	}
+
+	ids := make([]string, len(graph.Nodes))
+	for i, n := range graph.Nodes {
+		ids[i] = n.ID
+	}
+	edges, err := c.graphEdges(ctx, "from_id", ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range edges {
+		if included[e.Target] {
+			graph.Edges = append(graph.Edges, e)
+		}
+	}
+	return graph, nil
+}
+
+// keep reports whether a node passes the filters of o.
+func (o *GraphOptions) keep(n GraphNode) bool {
+	if n.Citations < o.MinCitations {
+		return false
+	}
+	if from, to := o.YearRange[0], o.YearRange[1]; from > 0 && n.Year < from || to > 0 && n.Year > to {
+		return false
+	}
+	if len(o.Categories) == 0 {
+		return true
+	}
+	for _, cat := range strings.Fields(n.Categories) {
+		for _, category := range o.Categories {
+			if inCategory(cat, category) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// graphEdgeBatch is the number of papers whose edges graphEdges reads in
+// one query.
+const graphEdgeBatch = 500
+
+// graphEdges returns the citations whose column, from_id or to_id, is one
+// of the given papers.
+func (c *Cache) graphEdges(ctx context.Context, column string, ids []string) ([]GraphEdge, error) {
+	var edges []GraphEdge
+	for batch := range slices.Chunk(ids, graphEdgeBatch) {
+		in, args := inList(batch)
+		rows, err := c.db.QueryContext(ctx, `
+			SELECT from_id, to_id, weight, influential FROM citations
+			WHERE `+column+` IN (`+in+`)
+			ORDER BY weight DESC, from_id, to_id
+		`, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var e GraphEdge
+			if err := rows.Scan(&e.Source, &e.Target, &e.Weight, &e.Influential); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			edges = append(edges, e)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return edges, nil
+}
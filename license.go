@@ -0,0 +1,180 @@
+package arxiv
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// License is a normalized arXiv license.
+type License string
+
+// Licenses offered by arXiv, current and past versions alike.
+const (
+	LicenseUnknown  License = ""            // No license recorded
+	LicenseCCBY     License = "CC-BY"       // Creative Commons Attribution
+	LicenseCCBYSA   License = "CC-BY-SA"    // Attribution-ShareAlike
+	LicenseCCBYNC   License = "CC-BY-NC"    // Attribution-NonCommercial
+	LicenseCCBYNCSA License = "CC-BY-NC-SA" // Attribution-NonCommercial-ShareAlike
+	LicenseCCBYND   License = "CC-BY-ND"    // Attribution-NoDerivatives
+	LicenseCCBYNCND License = "CC-BY-NC-ND" // Attribution-NonCommercial-NoDerivatives
+	LicenseCC0      License = "CC0"         // Public domain dedication
+	LicenseArXiv    License = "arXiv"       // arXiv non-exclusive distribution license
+	LicenseOther    License = "other"       // A license URL not recognized
+)
+
+// Permissive reports whether the license lets anyone redistribute and adapt
+// the paper, including commercially.
+func (l License) Permissive() bool {
+	switch l {
+	case LicenseCCBY, LicenseCCBYSA, LicenseCC0:
+		return true
+	}
+	return false
+}
+
+func (l License) String() string {
+	if l == LicenseUnknown {
+		return "none"
+	}
+	return string(l)
+}
+
+// ParseLicense normalizes a license URL as recorded by arXiv (e.g.,
+// http://creativecommons.org/licenses/by-sa/4.0/). It also accepts the
+// names of the License constants, case-insensitively, and "none".
+func ParseLicense(s string) License {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "", "none":
+		return LicenseUnknown
+	case "arxiv":
+		return LicenseArXiv
+	case "cc0":
+		return LicenseCC0
+	}
+	if name, ok := strings.CutPrefix(s, "cc-"); ok {
+		return ccLicense(name)
+	}
+
+	switch {
+	case strings.Contains(s, "arxiv.org/licenses/"):
+		// nonexclusive-distrib and assumed-1991-2003
+		return LicenseArXiv
+	case strings.Contains(s, "creativecommons.org/publicdomain/"):
+		return LicenseCC0
+	case strings.Contains(s, "creativecommons.org/licenses/"):
+		_, rest, _ := strings.Cut(s, "creativecommons.org/licenses/")
+		name, _, _ := strings.Cut(rest, "/")
+		return ccLicense(name)
+	}
+	return LicenseOther
+}
+
+// ccLicense returns the Creative Commons license with the given
+// lower-case elements, e.g. "by-nc-sa".
+func ccLicense(name string) License {
+	for _, l := range []License{LicenseCCBY, LicenseCCBYSA, LicenseCCBYNC, LicenseCCBYNCSA, LicenseCCBYND, LicenseCCBYNCND} {
+		if strings.EqualFold(strings.TrimPrefix(string(l), "CC-"), name) {
+			return l
+		}
+	}
+	return LicenseOther
+}
+
+// LicenseType returns the paper's normalized license.
+func (p *Paper) LicenseType() License {
+	return ParseLicense(p.License)
+}
+
+// LicenseFilter is a set of allowed licenses. An empty filter allows all.
+type LicenseFilter []License
+
+// PermissiveLicenses allows only licenses that permit redistribution.
+var PermissiveLicenses = LicenseFilter{LicenseCCBY, LicenseCCBYSA, LicenseCC0}
+
+// ParseLicenseFilter parses a comma-separated list of license names, as
+// accepted by ParseLicense. "permissive" stands for PermissiveLicenses.
+func ParseLicenseFilter(s string) (LicenseFilter, error) {
+	var f LicenseFilter
+	for name := range strings.SplitSeq(s, ",") {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "":
+			continue
+		case strings.EqualFold(name, "permissive"):
+			f = append(f, PermissiveLicenses...)
+			continue
+		}
+		l := ParseLicense(name)
+		if l == LicenseOther && !strings.EqualFold(name, "other") {
+			return nil, fmt.Errorf("unknown license %q", name)
+		}
+		f = append(f, l)
+	}
+	return f, nil
+}
+
+// Allows reports whether the filter allows license l.
+func (f LicenseFilter) Allows(l License) bool {
+	return len(f) == 0 || slices.Contains(f, l)
+}
+
+func (f LicenseFilter) String() string {
+	names := make([]string, len(f))
+	for i, l := range f {
+		names[i] = l.String()
+	}
+	return strings.Join(names, ",")
+}
+
+// licenseMatch returns an SQL condition, and its arguments, matching rows
+// whose license column f allows. Licenses are stored as recorded by arXiv,
+// so the condition lists the recorded values that normalize to an allowed
+// license; there are only a few dozen of them.
+func (c *Cache) licenseMatch(ctx context.Context, column string, f LicenseFilter) (string, []any, error) {
+	rows, err := c.db.QueryContext(ctx, "SELECT DISTINCT COALESCE(license, '') FROM papers")
+	if err != nil {
+		return "", nil, err
+	}
+	defer rows.Close()
+
+	var args []any
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			return "", nil, err
+		}
+		if f.Allows(ParseLicense(url)) {
+			args = append(args, url)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", nil, err
+	}
+	if len(args) == 0 {
+		return "0", nil, nil
+	}
+	return "COALESCE(" + column + ", '') IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ") + ")", args, nil
+}
+
+// LicenseCounts returns the number of cached papers under each license.
+func (c *Cache) LicenseCounts(ctx context.Context) (map[License]int, error) {
+	rows, err := c.db.QueryContext(ctx, "SELECT COALESCE(license, ''), COUNT(*) FROM papers GROUP BY 1")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[License]int)
+	for rows.Next() {
+		var url string
+		var n int
+		if err := rows.Scan(&url, &n); err != nil {
+			return nil, err
+		}
+		counts[ParseLicense(url)] += n
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,219 @@
+package arxiv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Announcement kinds, as in arXiv's daily "new" listings.
+const (
+	AnnouncedNew         = "new"
+	AnnouncedCrossList   = "cross"
+	AnnouncedReplacement = "replace"
+)
+
+// listingTTL is how long a pull of a day's records is reused while the day
+// may still gain announcements.
+const listingTTL = time.Hour
+
+// announceWindow bounds how long before the announcement day a paper may
+// have been submitted (or replaced) and still count as announced that day.
+// It covers weekends and holidays; older records in a day's OAI pull are
+// metadata corrections, not announcements.
+const announceWindow = 7 * 24 * time.Hour
+
+// Listing is one day's announcements in a category.
+type Listing struct {
+	Category string
+	Day      time.Time
+
+	// New are papers submitted with Category as their primary category
+	New []Paper
+
+	// CrossLists are new papers from other categories cross-listed to Category
+	CrossLists []Paper
+
+	// Replacements are new versions of earlier papers in Category
+	Replacements []Paper
+}
+
+// NewSubmissions returns the papers announced in category on day, separated
+// into new submissions, cross-lists and replacements. Category may be a
+// subject class (cs.LG) or a whole archive (hep-th, math).
+//
+// The first call for a day does a small incremental OAI-PMH pull of the
+// records changed that day and stores their metadata; later calls are
+// answered from the cache. A day pulled before it ended may not be complete,
+// so it is pulled again once the last pull is an hour old.
+func (c *Cache) NewSubmissions(ctx context.Context, category string, day time.Time) (*Listing, error) {
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	dayStr := day.Format("2006-01-02")
+	key := "announced/" + category + "/" + dayStr
+
+	var v string
+	c.db.QueryRowContext(ctx, "SELECT value FROM sync_state WHERE key = ?", key).Scan(&v)
+	pulled, err := time.Parse(time.RFC3339, v)
+	if err != nil || pulled.Before(day.AddDate(0, 0, 1)) && time.Since(pulled) > listingTTL {
+		if err := c.pullDay(ctx, category, day); err != nil {
+			return nil, err
+		}
+		c.db.ExecContext(ctx, "INSERT OR REPLACE INTO sync_state (key, value) VALUES (?, ?)",
+			key, time.Now().UTC().Format(time.RFC3339))
+	}
+
+	return c.listing(ctx, category, day)
+}
+
+// pullDay fetches the OAI-PMH records changed on day in category's set,
+// stores them, and records which of them were announced in category.
+func (c *Cache) pullDay(ctx context.Context, category string, day time.Time) error {
+	client := c.oaiClient()
+
+	var papers []Paper
+	token := ""
+	for {
+		resp, err := client.ListRecords(ctx, oaiSet(category), day, day, token)
+		if err != nil {
+			if errors.Is(err, ErrNoRecords) {
+				break
+			}
+			return fmt.Errorf("list records: %w", err)
+		}
+		papers = append(papers, resp.Papers...)
+		if resp.ResumptionToken == "" {
+			break
+		}
+		token = resp.ResumptionToken
+	}
+
+	if len(papers) > 0 {
+		if err := c.insertPapers(ctx, papers); err != nil {
+			return fmt.Errorf("insert papers: %w", err)
+		}
+	}
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	dayStr := day.Format("2006-01-02")
+	for i := range papers {
+		kind := announcementKind(&papers[i], category, day)
+		if kind == "" {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, `
+			INSERT OR REPLACE INTO announcements (paper_id, category, day, kind)
+			VALUES (?, ?, ?, ?)
+		`, papers[i].ID, category, dayStr, kind)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// announcementKind classifies a paper from a day's OAI pull, or returns ""
+// if it was not announced in category that day.
+func announcementKind(p *Paper, category string, day time.Time) string {
+	cats := strings.Fields(p.Categories)
+	listed := false
+	for _, cat := range cats {
+		if inCategory(cat, category) {
+			listed = true
+			break
+		}
+	}
+	if !listed {
+		return ""
+	}
+
+	cutoff := day.Add(-announceWindow)
+	switch {
+	case p.Updated.After(p.Created):
+		if p.Updated.Before(cutoff) {
+			return ""
+		}
+		return AnnouncedReplacement
+	case p.Created.Before(cutoff):
+		return ""
+	case inCategory(p.PrimaryCategory(), category):
+		return AnnouncedNew
+	default:
+		return AnnouncedCrossList
+	}
+}
+
+// inCategory reports whether cat (e.g., cs.LG) falls under category, which
+// is either a subject class or a whole archive (cs, hep-th).
+func inCategory(cat, category string) bool {
+	return cat == category || strings.HasPrefix(cat, category+".")
+}
+
+// categoryMatch returns an SQL condition, and its arguments, matching rows
+// whose column of space-separated categories holds one falling under any
+// of categories, as inCategory does.
+func categoryMatch(column string, categories []string) (string, []any) {
+	var conds []string
+	var args []any
+	for _, cat := range categories {
+		// An exact category, or any category in an archive
+		conds = append(conds, "(' ' || "+column+" || ' ') LIKE '% ' || ? || ' %'",
+			"(' ' || "+column+") LIKE '% ' || ? || '.%'")
+		args = append(args, cat, cat)
+	}
+	return "(" + strings.Join(conds, " OR ") + ")", args
+}
+
+// oaiSet returns the OAI-PMH set containing category. Physics archives are
+// grouped under the "physics" set.
+func oaiSet(category string) string {
+	archive, _, _ := strings.Cut(category, ".")
+	switch archive {
+	case "cs", "econ", "eess", "math", "q-bio", "q-fin", "stat":
+		return archive
+	default:
+		return "physics:" + archive
+	}
+}
+
+// listing reads a day's announcements from the cache.
+func (c *Cache) listing(ctx context.Context, category string, day time.Time) (*Listing, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT a.kind, p.id, p.created, p.updated, p.title, p.abstract, p.authors, p.categories,
+		       p.comments, p.journal_ref, p.doi, p.license, COALESCE(p.primary_category, ''), p.pdf_downloaded, p.src_downloaded
+		FROM announcements a
+		JOIN papers p ON p.id = a.paper_id
+		WHERE a.category = ? AND a.day = ?
+		ORDER BY p.id
+	`, category, day.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	l := &Listing{Category: category, Day: day}
+	for rows.Next() {
+		var kind string
+		p, err := scanPaperRow(rows, &kind)
+		if err != nil {
+			return nil, err
+		}
+
+		switch kind {
+		case AnnouncedNew:
+			l.New = append(l.New, *p)
+		case AnnouncedCrossList:
+			l.CrossLists = append(l.CrossLists, *p)
+		case AnnouncedReplacement:
+			l.Replacements = append(l.Replacements, *p)
+		}
+	}
+
+	return l, rows.Err()
+}
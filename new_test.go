@@ -0,0 +1,138 @@
+package arxiv
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnnouncementKind(t *testing.T) {
+	day := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	before := func(days int) time.Time { return day.AddDate(0, 0, -days) }
+	tests := []struct {
+		name     string
+		paper    Paper
+		category string
+		want     string
+	}{
+		{
+			name:     "new",
+			paper:    Paper{Categories: "cs.LG stat.ML", Created: before(1), Updated: before(1)},
+			category: "cs.LG",
+			want:     AnnouncedNew,
+		},
+		{
+			name:     "new in archive",
+			paper:    Paper{Categories: "cs.LG stat.ML", Created: before(1), Updated: before(1)},
+			category: "cs",
+			want:     AnnouncedNew,
+		},
+		{
+			name:     "cross-list",
+			paper:    Paper{Categories: "cs.LG stat.ML", Created: before(1), Updated: before(1)},
+			category: "stat.ML",
+			want:     AnnouncedCrossList,
+		},
+		{
+			name:     "recorded primary category",
+			paper:    Paper{Categories: "cs.LG stat.ML", Primary: "stat.ML", Created: before(1), Updated: before(1)},
+			category: "stat.ML",
+			want:     AnnouncedNew,
+		},
+		{
+			name:     "cross-list within the archive",
+			paper:    Paper{Categories: "cs.CL cs.LG", Created: before(1), Updated: before(1)},
+			category: "cs.LG",
+			want:     AnnouncedCrossList,
+		},
+		{
+			name:     "replacement",
+			paper:    Paper{Categories: "cs.LG", Created: before(300), Updated: before(2)},
+			category: "cs.LG",
+			want:     AnnouncedReplacement,
+		},
+		{
+			name:     "replacement of a cross-list",
+			paper:    Paper{Categories: "math.OC cs.LG", Created: before(300), Updated: before(2)},
+			category: "cs.LG",
+			want:     AnnouncedReplacement,
+		},
+		{
+			name:     "submitted over a long weekend",
+			paper:    Paper{Categories: "cs.LG", Created: before(6), Updated: before(6)},
+			category: "cs.LG",
+			want:     AnnouncedNew,
+		},
+		{
+			name:     "metadata correction",
+			paper:    Paper{Categories: "cs.LG", Created: before(300), Updated: before(300)},
+			category: "cs.LG",
+		},
+		{
+			name:     "old replacement",
+			paper:    Paper{Categories: "cs.LG", Created: before(300), Updated: before(30)},
+			category: "cs.LG",
+		},
+		{
+			name:     "other category",
+			paper:    Paper{Categories: "math.OC", Created: before(1), Updated: before(1)},
+			category: "cs.LG",
+		},
+		{
+			name:     "category prefix",
+			paper:    Paper{Categories: "cs.LGX", Created: before(1), Updated: before(1)},
+			category: "cs.LG",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := announcementKind(&tt.paper, tt.category, day); got != tt.want {
+				t.Errorf("announcementKind(%q in %s) = %q, want %q", tt.paper.Categories, tt.category, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCategoryMatch(t *testing.T) {
+	ctx := context.Background()
+	c, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tests := []struct {
+		categories string
+		filter     []string
+		want       bool
+	}{
+		{"cs.LG stat.ML", []string{"cs.LG"}, true},
+		{"cs.LG stat.ML", []string{"stat.ML"}, true},
+		{"cs.LG stat.ML", []string{"stat"}, true},
+		{"cs.LG stat.ML", []string{"math", "cs"}, true},
+		{"cs.LGX", []string{"cs.LG"}, false},
+		{"cs.LG", []string{"c"}, false},
+		{"hep-th", []string{"hep-th"}, true},
+		{"hep-th", []string{"hep"}, false},
+		{"math-ph", []string{"math"}, false},
+	}
+	for _, tt := range tests {
+		cond, args := categoryMatch("categories", tt.filter)
+		var got bool
+		query := "SELECT " + cond + " FROM (SELECT ? AS categories)"
+		if err := c.db.QueryRowContext(ctx, query, append(args, tt.categories)...).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		// categoryMatch and inCategory agree
+		want := false
+		for _, cat := range strings.Fields(tt.categories) {
+			for _, f := range tt.filter {
+				want = want || inCategory(cat, f)
+			}
+		}
+		if got != tt.want || want != tt.want {
+			t.Errorf("%q matching %q: SQL %v, inCategory %v, want %v", tt.categories, tt.filter, got, want, tt.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package arxiv
 import (
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,10 +14,33 @@ import (
 
 const oaiBaseURL = "https://export.arxiv.org/oai2"
 
+// ErrNoRecords matches the OAIError returned when no records match a
+// ListRecords request, which OAI-PMH reports as an error.
+var ErrNoRecords = errors.New("no records match")
+
+// OAIError is an error reported by an OAI-PMH server.
+type OAIError struct {
+	Code    string // e.g., noRecordsMatch or badResumptionToken
+	Message string
+}
+
+func (e *OAIError) Error() string {
+	return fmt.Sprintf("oai error %s: %s", e.Code, e.Message)
+}
+
+// Is reports whether e is a noRecordsMatch error, for errors.Is(err,
+// ErrNoRecords).
+func (e *OAIError) Is(target error) bool {
+	return target == ErrNoRecords && e.Code == "noRecordsMatch"
+}
+
 // OAIClient is an OAI-PMH client for arXiv.
 type OAIClient struct {
 	client  *http.Client
 	baseURL string
+
+	// wait, if set, is called before each request to pace it.
+	wait func(ctx context.Context, url string) error
 }
 
 // NewOAIClient creates a new OAI-PMH client.
@@ -29,6 +53,15 @@ func NewOAIClient() *OAIClient {
 	}
 }
 
+// oaiClient returns an OAI-PMH client for the cache's OAI endpoint, paced
+// by the cache's rate limiter.
+func (c *Cache) oaiClient() *OAIClient {
+	client := NewOAIClient()
+	client.baseURL = c.oaiURL
+	client.wait = c.wait
+	return client
+}
+
 // ListRecords fetches records from arXiv via OAI-PMH.
 // If resumptionToken is empty, starts from the beginning with the given params.
 // If resumptionToken is non-empty, continues from that point.
@@ -52,6 +85,11 @@ func (c *OAIClient) ListRecords(ctx context.Context, set string, from, until tim
 	}
 
 	reqURL := c.baseURL + "?" + params.Encode()
+	if c.wait != nil {
+		if err := c.wait(ctx, reqURL); err != nil {
+			return nil, err
+		}
+	}
 	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
@@ -83,7 +121,7 @@ func (c *OAIClient) ListRecords(ctx context.Context, set string, from, until tim
 	}
 
 	if oaiResp.Error.Code != "" {
-		return nil, fmt.Errorf("oai error %s: %s", oaiResp.Error.Code, oaiResp.Error.Value)
+		return nil, &OAIError{Code: oaiResp.Error.Code, Message: strings.TrimSpace(oaiResp.Error.Value)}
 	}
 
 	result := &OAIResponse{
@@ -104,6 +142,16 @@ func (c *OAIClient) ListRecords(ctx context.Context, set string, from, until tim
 			DOI:        rec.Metadata.ArXiv.DOI,
 			License:    rec.Metadata.ArXiv.License,
 		}
+		// In the arXiv metadata format the primary category is listed first
+		if cats := strings.Fields(paper.Categories); len(cats) > 0 {
+			paper.Primary = cats[0]
+		}
+		for _, a := range rec.Metadata.ArXiv.Authors {
+			paper.AuthorList = append(paper.AuthorList, Author{
+				Name:         a.name(),
+				Affiliations: a.Affiliations,
+			})
+		}
 
 		if rec.Metadata.ArXiv.Created != "" {
 			paper.Created, _ = time.Parse("2006-01-02", rec.Metadata.ArXiv.Created)
@@ -131,15 +179,19 @@ type OAIResponse struct {
 func formatAuthors(authors []oaiAuthor) string {
 	var parts []string
 	for _, a := range authors {
-		name := a.Forenames + " " + a.Keyname
-		if a.Suffix != "" {
-			name += " " + a.Suffix
-		}
-		parts = append(parts, strings.TrimSpace(name))
+		parts = append(parts, a.name())
 	}
 	return strings.Join(parts, ", ")
 }
 
+func (a oaiAuthor) name() string {
+	name := a.Forenames + " " + a.Keyname
+	if a.Suffix != "" {
+		name += " " + a.Suffix
+	}
+	return strings.TrimSpace(name)
+}
+
 // XML structures for OAI-PMH parsing
 
 type oaiPMHResponse struct {
@@ -194,7 +246,8 @@ type oaiArXiv struct {
 }
 
 type oaiAuthor struct {
-	Keyname   string `xml:"keyname"`
-	Forenames string `xml:"forenames"`
-	Suffix    string `xml:"suffix"`
+	Keyname      string   `xml:"keyname"`
+	Forenames    string   `xml:"forenames"`
+	Suffix       string   `xml:"suffix"`
+	Affiliations []string `xml:"affiliation"`
 }
@@ -0,0 +1,514 @@
+package arxiv
+
+import (
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"compress/flate"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Packs group the extracted sources of cold papers into one zip file per
+// month (packs/2301-*.zip), trading millions of small files for a handful
+// of large ones. The pack_entries table records which file holds each
+// pack and where each source file's data starts within it, so a single
+// file can be read without scanning the zip's central directory or
+// unpacking the tree.
+//
+// A pack is never rewritten in place: each change writes a new file under
+// a new name, commits the index pointing at it, and only then removes the
+// old file, so readers and a failed write always find the offsets they
+// were indexed with.
+
+// PackOptions configures Pack.
+type PackOptions struct {
+	// OlderThan selects papers submitted at least this long ago
+	OlderThan time.Duration
+
+	// Progress callback, called once per pack written
+	Progress func(pack string, papers, done, total int)
+}
+
+// PackStats summarizes a Pack or Unpack run.
+type PackStats struct {
+	Papers int
+	Files  int
+	Packs  int
+}
+
+// Pack moves the source trees of cold papers into per-month packs.
+// Trees are removed from the source directory once their pack is written.
+func (c *Cache) Pack(ctx context.Context, opts *PackOptions) (*PackStats, error) {
+	if opts == nil {
+		opts = &PackOptions{}
+	}
+	local, ok := c.blobs.(*FileStore)
+	if !ok {
+		return nil, errors.New("packing requires a local file store")
+	}
+
+	cutoff := time.Now().Add(-opts.OlderThan).Format("2006-01-02")
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT id FROM papers
+		WHERE src_downloaded = 1 AND created < ?
+		  AND id NOT IN (SELECT DISTINCT paper_id FROM pack_entries)
+		ORDER BY id
+	`, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byPack := make(map[string][]string)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		byPack[packName(id)] = append(byPack[packName(id)], id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	packs := make([]string, 0, len(byPack))
+	for name := range byPack {
+		packs = append(packs, name)
+	}
+	sort.Strings(packs)
+
+	stats := &PackStats{}
+	for i, name := range packs {
+		select {
+		case <-ctx.Done():
+			return stats, ctx.Err()
+		default:
+		}
+
+		add := make(map[string]string)
+		for _, id := range byPack[name] {
+			dir := local.Path(sourceKey(id))
+			if _, err := os.Stat(dir); err == nil {
+				add[id] = dir
+			}
+		}
+		if len(add) == 0 {
+			continue
+		}
+
+		files, err := c.writePack(ctx, local, name, add, nil)
+		if err != nil {
+			return stats, fmt.Errorf("pack %s: %w", name, err)
+		}
+		for _, dir := range add {
+			if err := os.RemoveAll(dir); err != nil {
+				return stats, fmt.Errorf("pack %s: %w", name, err)
+			}
+		}
+
+		stats.Packs++
+		stats.Papers += len(add)
+		stats.Files += files
+		if opts.Progress != nil {
+			opts.Progress(name, len(add), i+1, len(packs))
+		}
+	}
+
+	return stats, nil
+}
+
+// Unpack restores a packed paper's source tree to the source directory and
+// removes it from its pack.
+func (c *Cache) Unpack(ctx context.Context, paperID string) error {
+	local, ok := c.blobs.(*FileStore)
+	if !ok {
+		return errors.New("packing requires a local file store")
+	}
+
+	entries, err := packEntries(ctx, c.db, paperID)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("%s is not packed", paperID)
+	}
+
+	ps := newPackStore(local, entries)
+	key := sourceKey(paperID)
+	for _, e := range entries {
+		rc, err := ps.Get(ctx, e.Name)
+		if err != nil {
+			return err
+		}
+		err = local.Put(ctx, key+"/"+e.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = c.writePack(ctx, local, entries[0].Pack, nil, map[string]bool{paperID: true})
+	return err
+}
+
+// IsPacked reports whether a paper's source tree is stored in a pack.
+func (c *Cache) IsPacked(ctx context.Context, paperID string) bool {
+	var n int
+	err := c.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM pack_entries WHERE paper_id = ?", paperID).Scan(&n)
+	return err == nil && n > 0
+}
+
+// writePack writes a new version of the named pack, keeping its existing
+// papers except those in drop, and adding the source trees in add (paper
+// ID -> directory). The pack index is replaced in one transaction, and the
+// previous file is removed once it commits. It returns the number of files
+// added.
+func (c *Cache) writePack(ctx context.Context, local *FileStore, name string, add map[string]string, drop map[string]bool) (int, error) {
+	packDir := local.Path("packs")
+	if err := os.MkdirAll(packDir, 0755); err != nil {
+		return 0, err
+	}
+	var oldFile string
+	err := c.db.QueryRowContext(ctx, "SELECT file FROM pack_entries WHERE pack = ? LIMIT 1", name).Scan(&oldFile)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	packPath := filepath.Join(packDir, packFileName(name, oldFile))
+
+	tmp, err := os.CreateTemp(packDir, "."+name+"-*.zip")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	newFile := strings.TrimPrefix(filepath.Base(tmp.Name()), ".")
+
+	files, err := writePackZip(tmp, packPath, add, drop)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// Index the new pack before moving it into place.
+	zr, err := zip.OpenReader(tmp.Name())
+	if err != nil {
+		return 0, err
+	}
+	defer zr.Close()
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM pack_entries WHERE pack = ?", name); err != nil {
+		return 0, err
+	}
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO pack_entries (paper_id, name, pack, offset, size, csize, method, modified, file)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	for _, f := range zr.File {
+		id, rel, ok := cutPackName(f.Name)
+		if !ok {
+			continue
+		}
+		offset, err := f.DataOffset()
+		if err != nil {
+			return 0, err
+		}
+		_, err = stmt.ExecContext(ctx, id, rel, name, offset,
+			int64(f.UncompressedSize64), int64(f.CompressedSize64), f.Method,
+			f.Modified.UTC().Format(time.RFC3339), newFile)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Packed trees are no longer hard linked into the object store, nor
+	// found at src_path once Pack removes them; unpacked ones are back.
+	for id := range add {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM source_files WHERE paper_id = ?", id); err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE papers SET src_path = NULL WHERE id = ?", id); err != nil {
+			return 0, err
+		}
+	}
+	for id := range drop {
+		_, err := tx.ExecContext(ctx, "UPDATE papers SET src_path = ? WHERE id = ?", local.Path(sourceKey(id)), id)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if len(zr.File) > 0 {
+		newPath := filepath.Join(packDir, newFile)
+		if err := os.Rename(tmp.Name(), newPath); err != nil {
+			return 0, err
+		}
+		if err := tx.Commit(); err != nil {
+			os.Remove(newPath)
+			return 0, err
+		}
+	} else if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	if err := os.Remove(packPath); err != nil && !os.IsNotExist(err) {
+		return files, fmt.Errorf("remove old pack: %w", err)
+	}
+	return files, nil
+}
+
+// writePackZip writes the entries of the pack at oldPath, if it exists,
+// except those of papers in drop or add, followed by the source trees in
+// add, as a zip file to w. It returns the number of files added.
+func writePackZip(w io.Writer, oldPath string, add map[string]string, drop map[string]bool) (int, error) {
+	zw := zip.NewWriter(w)
+
+	// Copy the existing pack's entries without recompressing them.
+	if old, err := zip.OpenReader(oldPath); err == nil {
+		for _, f := range old.File {
+			id, _, _ := cutPackName(f.Name)
+			if drop[id] || add[id] != "" {
+				continue
+			}
+			if err := zw.Copy(f); err != nil {
+				old.Close()
+				return 0, err
+			}
+		}
+		old.Close()
+	} else if !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	ids := make([]string, 0, len(add))
+	for id := range add {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	files := 0
+	for _, id := range ids {
+		dir := add[id]
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			hdr, err := zip.FileInfoHeader(info)
+			if err != nil {
+				return err
+			}
+			hdr.Name = id + "/" + filepath.ToSlash(rel)
+			hdr.Method = zip.Deflate
+			w, err := zw.CreateHeader(hdr)
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if _, err := io.Copy(w, f); err != nil {
+				return err
+			}
+			files++
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return files, zw.Close()
+}
+
+// packFileName returns the name of the zip file holding a pack, given the file
+// recorded in its index. Packs written before files were versioned have
+// none recorded and are named after the pack.
+func packFileName(pack, file string) string {
+	return cmp.Or(file, pack+".zip")
+}
+
+// packName returns the pack holding a paper's source: the YYMM prefix for
+// new-style IDs ("2301"), or archive and YYMM for old ones ("hep-th-9901").
+func packName(id string) string {
+	if archive, num, ok := strings.Cut(id, "/"); ok {
+		if len(num) >= 4 {
+			num = num[:4]
+		}
+		return strings.ReplaceAll(archive, ".", "-") + "-" + num
+	}
+	return paperPrefix(id)
+}
+
+// cutPackName splits a pack entry name into paper ID and relative path.
+// Old-style IDs contain a slash themselves ("hep-th/9901001/main.tex").
+func cutPackName(name string) (id, rel string, ok bool) {
+	parts := strings.SplitN(name, "/", 3)
+	if len(parts) == 3 && !strings.Contains(parts[0], ".") && len(parts[1]) >= 7 && isDigits(parts[1][:7]) {
+		return parts[0] + "/" + parts[1], parts[2], true
+	}
+	id, rel, ok = strings.Cut(name, "/")
+	return id, rel, ok
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// packEntry locates one file within a pack.
+type packEntry struct {
+	Name     string // Path relative to the paper's source root
+	Pack     string
+	File     string // Zip file under packs/, if versioned
+	Offset   int64
+	Size     int64
+	CSize    int64
+	Method   uint16
+	Modified time.Time
+}
+
+func packEntries(ctx context.Context, db dbtx, paperID string) ([]packEntry, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT name, pack, file, offset, size, csize, method, modified
+		FROM pack_entries WHERE paper_id = ? ORDER BY name
+	`, paperID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []packEntry
+	for rows.Next() {
+		var e packEntry
+		var modified string
+		if err := rows.Scan(&e.Name, &e.Pack, &e.File, &e.Offset, &e.Size, &e.CSize, &e.Method, &modified); err != nil {
+			return nil, err
+		}
+		e.Modified, _ = time.Parse(time.RFC3339, modified)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// packStore is a read-only BlobStore over one paper's packed files,
+// keyed by path relative to the paper's source root.
+type packStore struct {
+	dir     string
+	entries map[string]packEntry
+}
+
+func newPackStore(local *FileStore, entries []packEntry) *packStore {
+	ps := &packStore{dir: local.Path("packs"), entries: make(map[string]packEntry)}
+	for _, e := range entries {
+		ps.entries[e.Name] = e
+	}
+	return ps
+}
+
+var errReadOnlyPack = errors.New("packs are read-only")
+
+func (ps *packStore) Put(ctx context.Context, key string, r io.Reader) error {
+	return errReadOnlyPack
+}
+
+func (ps *packStore) Delete(ctx context.Context, key string) error {
+	return errReadOnlyPack
+}
+
+// Get reads the entry directly at its recorded offset. Stored entries are
+// returned as a section of the pack file; compressed entries are inflated
+// into memory, since source files are small.
+func (ps *packStore) Get(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	e, ok := ps.entries[key]
+	if !ok {
+		return nil, &fs.PathError{Op: "get", Path: key, Err: fs.ErrNotExist}
+	}
+	f, err := os.Open(filepath.Join(ps.dir, packFileName(e.Pack, e.File)))
+	if err != nil {
+		return nil, err
+	}
+	section := io.NewSectionReader(f, e.Offset, e.CSize)
+
+	switch e.Method {
+	case zip.Store:
+		return &packFile{ReadSeeker: section, f: f}, nil
+	case zip.Deflate:
+		defer f.Close()
+		fr := flate.NewReader(section)
+		defer fr.Close()
+		data, err := io.ReadAll(io.LimitReader(fr, e.Size))
+		if err != nil {
+			return nil, fmt.Errorf("inflate %s: %w", key, err)
+		}
+		return &packFile{ReadSeeker: bytes.NewReader(data)}, nil
+	default:
+		f.Close()
+		return nil, fmt.Errorf("%s: unsupported compression method %d", key, e.Method)
+	}
+}
+
+func (ps *packStore) Stat(ctx context.Context, key string) (*BlobInfo, error) {
+	e, ok := ps.entries[key]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: key, Err: fs.ErrNotExist}
+	}
+	return &BlobInfo{Key: key, Size: e.Size, ModTime: e.Modified}, nil
+}
+
+func (ps *packStore) List(ctx context.Context, prefix string) ([]BlobInfo, error) {
+	var blobs []BlobInfo
+	for name, e := range ps.entries {
+		if strings.HasPrefix(name, prefix) {
+			blobs = append(blobs, BlobInfo{Key: name, Size: e.Size, ModTime: e.Modified})
+		}
+	}
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].Key < blobs[j].Key })
+	return blobs, nil
+}
+
+type packFile struct {
+	io.ReadSeeker
+	f *os.File
+}
+
+func (p *packFile) Close() error {
+	if p.f != nil {
+		return p.f.Close()
+	}
+	return nil
+}
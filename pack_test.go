@@ -0,0 +1,222 @@
+package arxiv
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"context"
+	"database/sql"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// packTrees are the source trees packed by the tests, by paper ID.
+var packTrees = map[string]map[string]string{
+	"2301.00001":     {"main.tex": strings.Repeat("\\section{Intro}\n", 100), "figs/a.txt": "a"},
+	"2301.00002":     {"paper.tex": "short", "empty.bbl": ""},
+	"hep-th/9901001": {"main.tex": "old-style"},
+}
+
+// newPackCache returns a cache holding packTrees as the downloaded sources
+// of papers submitted in 2023.
+func newPackCache(t *testing.T) *Cache {
+	t.Helper()
+	ctx := context.Background()
+	c, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	local := c.blobs.(*FileStore)
+	for id, tree := range packTrees {
+		if err := c.insertPapers(ctx, []Paper{{ID: id, Created: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)}}); err != nil {
+			t.Fatal(err)
+		}
+		dir := local.Path(sourceKey(id))
+		for name, data := range tree {
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		_, err := c.db.Exec("UPDATE papers SET src_downloaded = 1, src_path = ? WHERE id = ?", dir, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
+
+// readSource returns a paper's source files as served by OpenSource.
+func readSource(t *testing.T, c *Cache, id string) map[string]string {
+	t.Helper()
+	ctx := context.Background()
+	names, err := c.SourceFiles(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := make(map[string]string)
+	for _, name := range names {
+		rc, _, err := c.OpenSource(ctx, id, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree[name] = string(data)
+	}
+	return tree
+}
+
+func srcPath(t *testing.T, c *Cache, id string) sql.NullString {
+	t.Helper()
+	var path sql.NullString
+	if err := c.db.QueryRow("SELECT src_path FROM papers WHERE id = ?", id).Scan(&path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPack(t *testing.T) {
+	ctx := context.Background()
+	c := newPackCache(t)
+	local := c.blobs.(*FileStore)
+
+	stats, err := c.Pack(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (PackStats{Papers: 3, Files: 5, Packs: 2}); *stats != want {
+		t.Errorf("stats = %+v, want %+v", *stats, want)
+	}
+
+	// Each indexed offset is where the entry's data starts in the pack file.
+	for id, tree := range packTrees {
+		t.Run(id, func(t *testing.T) {
+			entries, err := packEntries(ctx, c.db, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]string)
+			for _, e := range entries {
+				if e.Pack != packName(id) {
+					t.Errorf("%s is in pack %s, want %s", e.Name, e.Pack, packName(id))
+				}
+				data, err := os.ReadFile(filepath.Join(local.Path("packs"), packFileName(e.Pack, e.File)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				raw := data[e.Offset : e.Offset+e.CSize]
+				if e.Method == zip.Store {
+					got[e.Name] = string(raw)
+					continue
+				}
+				inflated, err := io.ReadAll(flate.NewReader(bytes.NewReader(raw)))
+				if err != nil {
+					t.Fatalf("inflate %s at offset %d: %v", e.Name, e.Offset, err)
+				}
+				if int64(len(inflated)) != e.Size {
+					t.Errorf("%s: size %d, indexed %d", e.Name, len(inflated), e.Size)
+				}
+				got[e.Name] = string(inflated)
+			}
+			if !maps.Equal(got, tree) {
+				t.Errorf("packed files = %q, want %q", got, tree)
+			}
+			if got := readSource(t, c, id); !maps.Equal(got, tree) {
+				t.Errorf("OpenSource files = %q, want %q", got, tree)
+			}
+
+			if _, err := os.Stat(local.Path(sourceKey(id))); !os.IsNotExist(err) {
+				t.Errorf("source tree still present: %v", err)
+			}
+			if path := srcPath(t, c, id); path.Valid {
+				t.Errorf("src_path = %q, want NULL", path.String)
+			}
+		})
+	}
+
+	// Packed papers are not packed again.
+	stats, err = c.Pack(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *stats != (PackStats{}) {
+		t.Errorf("second Pack stats = %+v, want none", *stats)
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	ctx := context.Background()
+	c := newPackCache(t)
+	local := c.blobs.(*FileStore)
+	if _, err := c.Pack(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id     string
+		packed []string // Papers left in a pack afterwards
+	}{
+		{"2301.00001", []string{"2301.00002", "hep-th/9901001"}},
+		{"hep-th/9901001", []string{"2301.00002"}},
+		{"2301.00002", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			if err := c.Unpack(ctx, tt.id); err != nil {
+				t.Fatal(err)
+			}
+			if c.IsPacked(ctx, tt.id) {
+				t.Error("still packed")
+			}
+			if err := c.Unpack(ctx, tt.id); err == nil {
+				t.Error("unpacking twice succeeded")
+			}
+
+			dir := local.Path(sourceKey(tt.id))
+			for name, want := range packTrees[tt.id] {
+				data, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != want {
+					t.Errorf("%s = %q, want %q", name, data, want)
+				}
+			}
+			if path := srcPath(t, c, tt.id); path.String != dir {
+				t.Errorf("src_path = %q, want %q", path.String, dir)
+			}
+
+			for _, id := range tt.packed {
+				if !c.IsPacked(ctx, id) {
+					t.Errorf("%s is no longer packed", id)
+				}
+				if got := readSource(t, c, id); !maps.Equal(got, packTrees[id]) {
+					t.Errorf("%s files = %q, want %q", id, got, packTrees[id])
+				}
+			}
+		})
+	}
+
+	// Emptied packs are removed.
+	files, err := os.ReadDir(local.Path("packs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("packs left: %v", files)
+	}
+}
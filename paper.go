@@ -40,6 +40,16 @@ type Paper struct {
 	// License URL
 	License string
 
+	// Primary is the primary category reported by arXiv, if known; see
+	// PrimaryCategory
+	Primary string
+
+	// Version is the latest version number (e.g., 2 for v2), if known
+	Version int
+
+	// AuthorList holds per-author details such as affiliations, when known
+	AuthorList []Author
+
 	// PDFPath is the local path to the PDF (if downloaded)
 	PDFPath string
 
@@ -51,10 +61,23 @@ type Paper struct {
 
 	// SourceDownloaded indicates if the source has been downloaded
 	SourceDownloaded bool
+
+	// MetadataUpdated is when the metadata was last fetched from arXiv
+	MetadataUpdated time.Time
+}
+
+// Author is one author of a paper.
+type Author struct {
+	Name         string
+	Affiliations []string
 }
 
-// PrimaryCategory returns the primary (first) category.
+// PrimaryCategory returns the primary category as reported by arXiv,
+// falling back to the first listed category.
 func (p *Paper) PrimaryCategory() string {
+	if p.Primary != "" {
+		return p.Primary
+	}
 	cats := strings.Fields(p.Categories)
 	if len(cats) == 0 {
 		return ""
@@ -62,6 +85,25 @@ func (p *Paper) PrimaryCategory() string {
 	return cats[0]
 }
 
+// AuthorNames returns the authors' names, from AuthorList if it is set and
+// otherwise by splitting Authors.
+func (p *Paper) AuthorNames() []string {
+	if len(p.AuthorList) > 0 {
+		names := make([]string, len(p.AuthorList))
+		for i, a := range p.AuthorList {
+			names[i] = a.Name
+		}
+		return names
+	}
+	var names []string
+	for _, name := range strings.Split(strings.ReplaceAll(p.Authors, " and ", ", "), ",") {
+		if name = normalizeSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
 // CategoryList returns all categories as a slice.
 func (p *Paper) CategoryList() []string {
 	return strings.Fields(p.Categories)
@@ -0,0 +1,599 @@
+package arxiv
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// This file implements just enough of PDF to read the text of papers:
+// the object syntax, recovery of objects without trusting the
+// cross-reference table, object streams and the common stream filters.
+
+type (
+	pdfName    string
+	pdfString  string // Bytes, not necessarily UTF-8
+	pdfKeyword string // Operators in content streams, and obj, R, ...
+	pdfDict    map[pdfName]any
+	pdfRef     struct{ num, gen int }
+	pdfStream  struct {
+		dict pdfDict
+		data []byte // Still encoded
+	}
+)
+
+// maxStreamSize bounds the decoded size of a stream.
+const maxStreamSize = 64 << 20
+
+// maxPDFDepth bounds the nesting of arrays and dictionaries.
+const maxPDFDepth = 64
+
+var (
+	errPDFSyntax = errors.New("pdf: syntax error")
+	errPDFDepth  = errors.New("pdf: objects nested too deeply")
+)
+
+// pdfLexer reads PDF objects from a buffer.
+type pdfLexer struct {
+	buf   []byte
+	pos   int
+	depth int // Arrays and dictionaries being read
+}
+
+func isPDFSpace(b byte) bool {
+	return b == ' ' || b == '\n' || b == '\r' || b == '\t' || b == '\f' || b == 0
+}
+
+func isPDFDelim(b byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), b) >= 0
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.buf) {
+		switch b := l.buf[l.pos]; {
+		case isPDFSpace(b):
+			l.pos++
+		case b == '%':
+			for l.pos < len(l.buf) && l.buf[l.pos] != '\n' && l.buf[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// regular reads a run of regular characters.
+func (l *pdfLexer) regular() string {
+	start := l.pos
+	for l.pos < len(l.buf) && !isPDFSpace(l.buf[l.pos]) && !isPDFDelim(l.buf[l.pos]) {
+		l.pos++
+	}
+	return string(l.buf[start:l.pos])
+}
+
+// object reads the next object. The delimiters ], >> and } are returned
+// as keywords, so that arrays and dictionaries can find their ends.
+func (l *pdfLexer) object() (any, error) {
+	l.skipSpace()
+	if l.pos >= len(l.buf) {
+		return nil, io.EOF
+	}
+	switch b := l.buf[l.pos]; b {
+	case '/':
+		l.pos++
+		return pdfName(unescapeName(l.regular())), nil
+	case '(':
+		l.pos++
+		return l.literalString(), nil
+	case '<':
+		if l.pos+1 < len(l.buf) && l.buf[l.pos+1] == '<' {
+			l.pos += 2
+			if l.depth >= maxPDFDepth {
+				return nil, errPDFDepth
+			}
+			l.depth++
+			defer func() { l.depth-- }()
+			return l.dict()
+		}
+		l.pos++
+		return l.hexString(), nil
+	case '>':
+		if l.pos+1 < len(l.buf) && l.buf[l.pos+1] == '>' {
+			l.pos += 2
+			return pdfKeyword(">>"), nil
+		}
+		l.pos++
+		return nil, errPDFSyntax
+	case '[':
+		l.pos++
+		if l.depth >= maxPDFDepth {
+			return nil, errPDFDepth
+		}
+		l.depth++
+		defer func() { l.depth-- }()
+		var arr []any
+		for {
+			v, err := l.object()
+			if err != nil {
+				return arr, err
+			}
+			if v == pdfKeyword("]") {
+				return arr, nil
+			}
+			arr = append(arr, v)
+		}
+	case ']', '{', '}', ')':
+		l.pos++
+		return pdfKeyword(string(b)), nil
+	}
+
+	tok := l.regular()
+	if tok == "" {
+		l.pos++ // Stray delimiter
+		return nil, errPDFSyntax
+	}
+	switch tok {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	if n, err := strconv.ParseFloat(tok, 64); err == nil {
+		// An integer may start an indirect reference: num gen R
+		if num, err := strconv.Atoi(tok); err == nil {
+			save := l.pos
+			l.skipSpace()
+			if gen, err := strconv.Atoi(l.regular()); err == nil {
+				l.skipSpace()
+				if l.regular() == "R" {
+					return pdfRef{num, gen}, nil
+				}
+			}
+			l.pos = save
+		}
+		return n, nil
+	}
+	return pdfKeyword(tok), nil
+}
+
+func (l *pdfLexer) dict() (pdfDict, error) {
+	d := make(pdfDict)
+	for {
+		k, err := l.object()
+		if err != nil {
+			return d, err
+		}
+		if k == pdfKeyword(">>") {
+			return d, nil
+		}
+		name, ok := k.(pdfName)
+		if !ok {
+			continue
+		}
+		v, err := l.object()
+		if err != nil {
+			return d, err
+		}
+		if v == pdfKeyword(">>") {
+			return d, nil
+		}
+		d[name] = v
+	}
+}
+
+func (l *pdfLexer) literalString() pdfString {
+	var b []byte
+	depth := 1
+	for l.pos < len(l.buf) {
+		c := l.buf[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return pdfString(b)
+			}
+		case '\\':
+			if l.pos >= len(l.buf) {
+				break
+			}
+			c = l.buf[l.pos]
+			l.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.pos < len(l.buf) && l.buf[l.pos] == '\n' {
+					l.pos++
+				}
+				continue // Line continuation
+			case '\n':
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					n := int(c - '0')
+					for i := 0; i < 2 && l.pos < len(l.buf) && l.buf[l.pos] >= '0' && l.buf[l.pos] <= '7'; i++ {
+						n = n*8 + int(l.buf[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(n)
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return pdfString(b)
+}
+
+func (l *pdfLexer) hexString() pdfString {
+	var digits []byte
+	for l.pos < len(l.buf) && l.buf[l.pos] != '>' {
+		if c := l.buf[l.pos]; !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	b := make([]byte, len(digits)/2)
+	hex.Decode(b, digits)
+	return pdfString(b)
+}
+
+// unescapeName decodes the #xx escapes of a name.
+func unescapeName(s string) string {
+	if !bytes.Contains([]byte(s), []byte("#")) {
+		return s
+	}
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] == '#' && i+2 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b = append(b, byte(n))
+				i += 2
+				continue
+			}
+		}
+		b = append(b, s[i])
+	}
+	return string(b)
+}
+
+// pdfDoc is a parsed PDF file.
+type pdfDoc struct {
+	buf     []byte
+	offsets map[int]int    // Object number to offset of "num gen obj"
+	inStm   map[int][2]int // Object number to its object stream and index
+	objStms map[int]*pdfObjStm
+	objects map[int]any
+	trailer pdfDict
+}
+
+var (
+	pdfObjStart = regexp.MustCompile(`(?:^|[\r\n \t\f\x00>])(\d+)[ \t\r\n\f\x00]+(\d+)[ \t\r\n\f\x00]+obj\b`)
+	pdfTrailer  = regexp.MustCompile(`trailer[ \t\r\n\f\x00]*<<`)
+)
+
+// parsePDF indexes the objects of a PDF file. Rather than reading the
+// cross-reference table, which is often inexact, it finds objects by
+// scanning for their headers; later definitions replace earlier ones, as
+// in incremental updates.
+func parsePDF(data []byte) (*pdfDoc, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF")) {
+		return nil, errors.New("pdf: not a PDF file")
+	}
+	d := &pdfDoc{
+		buf:     data,
+		offsets: make(map[int]int),
+		inStm:   make(map[int][2]int),
+		objStms: make(map[int]*pdfObjStm),
+		objects: make(map[int]any),
+	}
+	for _, m := range pdfObjStart.FindAllSubmatchIndex(data, -1) {
+		num, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		d.offsets[num] = m[2]
+	}
+
+	// Register the members of object streams, and find the trailer of the
+	// last cross-reference stream
+	var xref []int
+	for num, off := range d.offsets {
+		head := data[off:min(len(data), off+512)]
+		if i := bytes.Index(head, []byte("stream")); i >= 0 {
+			head = head[:i]
+		}
+		switch {
+		case bytes.Contains(head, []byte("/ObjStm")):
+			d.registerObjStm(num)
+		case bytes.Contains(head, []byte("/XRef")):
+			xref = append(xref, off)
+		}
+	}
+	if locs := pdfTrailer.FindAllIndex(data, -1); len(locs) > 0 {
+		l := &pdfLexer{buf: data, pos: locs[len(locs)-1][1] - 2}
+		if v, err := l.object(); err == nil {
+			d.trailer, _ = v.(pdfDict)
+		}
+	}
+	if d.trailer == nil && len(xref) > 0 {
+		sort.Ints(xref)
+		if s, ok := d.parseObject(xref[len(xref)-1]).(*pdfStream); ok {
+			d.trailer = s.dict
+		}
+	}
+	return d, nil
+}
+
+func (d *pdfDoc) registerObjStm(num int) {
+	stm := d.objStm(num)
+	if stm == nil {
+		return
+	}
+	for i, member := range stm.members {
+		if _, ok := d.offsets[member]; !ok {
+			d.inStm[member] = [2]int{num, i}
+		}
+	}
+}
+
+// pdfObjStm is a decoded object stream.
+type pdfObjStm struct {
+	data    []byte
+	first   int   // Offset of the first member
+	members []int // Object numbers
+	offsets []int // Offsets of the members, relative to first
+}
+
+// objStm returns object stream num, decoding it on first use: its members
+// are read one at a time, and decoding it for each would be quadratic.
+func (d *pdfDoc) objStm(num int) *pdfObjStm {
+	if stm, ok := d.objStms[num]; ok {
+		return stm
+	}
+	d.objStms[num] = nil
+	s, ok := d.object(num).(*pdfStream)
+	if !ok {
+		return nil
+	}
+	data, err := d.decode(s)
+	if err != nil {
+		return nil
+	}
+	stm := &pdfObjStm{data: data, first: d.int(s.dict["First"])}
+	l := &pdfLexer{buf: data}
+	for range d.int(s.dict["N"]) {
+		member, _ := l.object()
+		off, _ := l.object()
+		m, ok1 := member.(float64)
+		o, ok2 := off.(float64)
+		if !ok1 || !ok2 {
+			break
+		}
+		stm.members = append(stm.members, int(m))
+		stm.offsets = append(stm.offsets, int(o))
+	}
+	d.objStms[num] = stm
+	return stm
+}
+
+// object returns object num, or nil if there is none.
+func (d *pdfDoc) object(num int) any {
+	if v, ok := d.objects[num]; ok {
+		return v
+	}
+	d.objects[num] = nil // Break reference cycles
+	var v any
+	if off, ok := d.offsets[num]; ok {
+		v = d.parseObject(off)
+	} else if loc, ok := d.inStm[num]; ok {
+		v = d.streamObject(loc[0], loc[1])
+	}
+	d.objects[num] = v
+	return v
+}
+
+// parseObject parses the indirect object at off.
+func (d *pdfDoc) parseObject(off int) any {
+	l := &pdfLexer{buf: d.buf, pos: off}
+	for range 3 { // num gen obj
+		if _, err := l.object(); err != nil {
+			return nil
+		}
+	}
+	v, err := l.object()
+	if err != nil {
+		return nil
+	}
+	dict, ok := v.(pdfDict)
+	if !ok {
+		return v
+	}
+	l.skipSpace()
+	if !bytes.HasPrefix(l.buf[l.pos:], []byte("stream")) {
+		return dict
+	}
+	l.pos += len("stream")
+	if l.pos < len(l.buf) && l.buf[l.pos] == '\r' {
+		l.pos++
+	}
+	if l.pos < len(l.buf) && l.buf[l.pos] == '\n' {
+		l.pos++
+	}
+	start := l.pos
+	end := start + d.int(dict["Length"])
+	if end <= start || end > len(d.buf) || !bytes.Contains(d.buf[end:min(len(d.buf), end+32)], []byte("endstream")) {
+		// Missing or wrong length: look for the end instead
+		i := bytes.Index(d.buf[start:], []byte("endstream"))
+		if i < 0 {
+			return dict
+		}
+		end = start + i
+	}
+	return &pdfStream{dict: dict, data: d.buf[start:end]}
+}
+
+// streamObject returns the index'th object of object stream num.
+func (d *pdfDoc) streamObject(num, index int) any {
+	stm := d.objStm(num)
+	if stm == nil || index >= len(stm.offsets) {
+		return nil
+	}
+	pos := stm.first + stm.offsets[index]
+	if pos < 0 || pos > len(stm.data) {
+		return nil
+	}
+	l := &pdfLexer{buf: stm.data, pos: pos}
+	v, _ := l.object()
+	return v
+}
+
+// resolve follows indirect references.
+func (d *pdfDoc) resolve(v any) any {
+	for range 16 {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		v = d.object(ref.num)
+	}
+	return nil
+}
+
+func (d *pdfDoc) dict(v any) pdfDict {
+	switch v := d.resolve(v).(type) {
+	case pdfDict:
+		return v
+	case *pdfStream:
+		return v.dict
+	}
+	return nil
+}
+
+func (d *pdfDoc) int(v any) int {
+	f, _ := d.resolve(v).(float64)
+	return int(f)
+}
+
+// decode returns the decoded data of a stream.
+func (d *pdfDoc) decode(s *pdfStream) ([]byte, error) {
+	var filters []any
+	switch f := d.resolve(s.dict["Filter"]).(type) {
+	case pdfName:
+		filters = []any{f}
+	case []any:
+		filters = f
+	}
+	data := s.data
+	for _, f := range filters {
+		var err error
+		switch name, _ := d.resolve(f).(pdfName); name {
+		case "FlateDecode", "Fl":
+			var zr io.ReadCloser
+			zr, err = zlib.NewReader(bytes.NewReader(data))
+			if err == nil {
+				data, err = io.ReadAll(io.LimitReader(zr, maxStreamSize))
+				if len(data) > 0 && errors.Is(err, io.ErrUnexpectedEOF) {
+					err = nil // Keep what was written before truncation
+				}
+			}
+		case "ASCII85Decode", "A85":
+			data = bytes.TrimPrefix(bytes.TrimSpace(data), []byte("<~"))
+			if i := bytes.Index(data, []byte("~>")); i >= 0 {
+				data = data[:i]
+			}
+			out := make([]byte, 4*len(data)/5+4)
+			var n int
+			n, _, err = ascii85.Decode(out, data, true)
+			data = out[:n]
+		case "ASCIIHexDecode", "AHx":
+			l := &pdfLexer{buf: append(bytes.Clone(data), '>')}
+			data = []byte(l.hexString())
+		default:
+			err = fmt.Errorf("pdf: unsupported filter %v", f)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// pages returns the page dictionaries in order, with inherited resources
+// filled in.
+func (d *pdfDoc) pages() []pdfDict {
+	var pages []pdfDict
+	seen := make(map[any]bool)
+	var walk func(node any, resources any)
+	walk = func(node any, resources any) {
+		if ref, ok := node.(pdfRef); ok {
+			if seen[ref] {
+				return
+			}
+			seen[ref] = true
+		}
+		n := d.dict(node)
+		if n == nil {
+			return
+		}
+		if r, ok := n["Resources"]; ok {
+			resources = r
+		}
+		if kids, ok := d.resolve(n["Kids"]).([]any); ok {
+			for _, kid := range kids {
+				walk(kid, resources)
+			}
+			return
+		}
+		if n["Type"] == pdfName("Page") || n["Contents"] != nil {
+			page := make(pdfDict, len(n)+1)
+			for k, v := range n {
+				page[k] = v
+			}
+			page["Resources"] = resources
+			pages = append(pages, page)
+		}
+	}
+	if root := d.dict(d.trailer["Root"]); root != nil {
+		walk(root["Pages"], nil)
+	}
+	if len(pages) > 0 {
+		return pages
+	}
+
+	// No usable page tree: take page objects in object order
+	var nums []int
+	for num := range d.offsets {
+		nums = append(nums, num)
+	}
+	for num := range d.inStm {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		if n, ok := d.object(num).(pdfDict); ok && n["Type"] == pdfName("Page") {
+			pages = append(pages, n)
+		}
+	}
+	return pages
+}
@@ -0,0 +1,142 @@
+package arxiv
+
+import (
+	"context"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPDFLexer(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []any
+	}{
+		{"literal string", `(Hello, world)`, []any{pdfString("Hello, world")}},
+		{"nested parentheses", `(a (b) c)`, []any{pdfString("a (b) c")}},
+		{"escapes", `(\n\r\t\b\f\(\)\\)`, []any{pdfString("\n\r\t\b\f()\\")}},
+		{"octal escapes", `(\101\60x\0053)`, []any{pdfString("A0x\x053")}},
+		{"unknown escape", `(\q)`, []any{pdfString("q")}},
+		{"line continuation", "(ab\\\ncd\\\r\nef)", []any{pdfString("abcdef")}},
+		{"hex string", `<48 65 6c6C6f>`, []any{pdfString("Hello")}},
+		{"odd hex string", `<414>`, []any{pdfString("A@")}},
+		{"empty hex string", `<>`, []any{pdfString("")}},
+		{"reference", `12 0 R`, []any{pdfRef{12, 0}}},
+		{"numbers", `12 0 -3.5 .5`, []any{12.0, 0.0, -3.5, 0.5}},
+		{"number before keyword", `12 0 obj`, []any{12.0, 0.0, pdfKeyword("obj")}},
+		{"references in array", `[1 0 R 2 5 R 7]`, []any{[]any{pdfRef{1, 0}, pdfRef{2, 5}, 7.0}}},
+		{"name", `/Type`, []any{pdfName("Type")}},
+		{"escaped name", `/A#20B#2fC /Lin#65`, []any{pdfName("A B/C"), pdfName("Line")}},
+		{"bad name escape", `/A#zz`, []any{pdfName("A#zz")}},
+		{"dictionary", `<< /Type /Page /Count 3 /Kids [4 0 R] >>`, []any{pdfDict{
+			"Type": pdfName("Page"), "Count": 3.0, "Kids": []any{pdfRef{4, 0}},
+		}}},
+		{"keywords", `true false null BT ET`, []any{true, false, nil, pdfKeyword("BT"), pdfKeyword("ET")}},
+		{"comment", "1 % comment (not a string)\n2", []any{1.0, 2.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &pdfLexer{buf: []byte(tt.in)}
+			var got []any
+			for {
+				v, err := l.object()
+				if err != nil {
+					break
+				}
+				got = append(got, v)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lexing %q:\ngot  %#v\nwant %#v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPDFLexerDepth(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		wantErr error
+	}{
+		{"arrays at the limit", strings.Repeat("[", maxPDFDepth) + strings.Repeat("]", maxPDFDepth), nil},
+		{"arrays past the limit", strings.Repeat("[", maxPDFDepth+1) + strings.Repeat("]", maxPDFDepth+1), errPDFDepth},
+		{"dictionaries past the limit", strings.Repeat("<< /A ", maxPDFDepth+1) + strings.Repeat(">> ", maxPDFDepth+1), errPDFDepth},
+		{"unterminated", strings.Repeat("[<< /A ", 100000), errPDFDepth},
+		{"siblings", strings.Repeat("[[1] << /A [2] >>]", maxPDFDepth), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &pdfLexer{buf: []byte(tt.in)}
+			for {
+				_, err := l.object()
+				if err == io.EOF {
+					err = nil
+				}
+				if err != nil || l.pos >= len(l.buf) {
+					if err != tt.wantErr {
+						t.Errorf("got error %v, want %v", err, tt.wantErr)
+					}
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestPDFTextRun(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{
+			name:    "lines",
+			content: "BT /F1 12 Tf 72 720 Td (First) Tj 0 -14 Td (Second) Tj ET",
+			want:    "First\nSecond",
+		},
+		{
+			name:    "word spacing in TJ",
+			content: "BT /F1 12 Tf [(Ker) -20 (ning) -300 (words)] TJ ET",
+			want:    "Kerning words",
+		},
+		{
+			name:    "inline image",
+			content: "BT /F1 12 Tf (Before) Tj ET BI /W 2 /H 1 /BPC 8 /CS /G ID \x00EI(Lost) Tj\xff EI\nBT (After) Tj ET",
+			want:    "BeforeAfter",
+		},
+		{
+			name:    "unterminated inline image",
+			content: "BT /F1 12 Tf (Before) Tj ET BI /W 1 ID \x00\x01 (Lost) Tj",
+			want:    "Before",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x := &pdfText{doc: &pdfDoc{objects: make(map[int]any)}, fonts: make(map[any]*pdfFont)}
+			x.run([]byte(tt.content), nil, 0)
+			if got := x.out.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// testdata/objstm.pdf keeps its page tree and font in a compressed object
+// stream, its content in a FlateDecode stream, and has a cross-reference
+// stream instead of a trailer.
+func TestPDFTextObjectStream(t *testing.T) {
+	f, err := os.Open("testdata/objstm.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	got, err := PDFTextExtractor{}.PDFText(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Hello, PDF\nObject streams\n"; got != want {
+		t.Errorf("PDFText = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,501 @@
+package arxiv
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// TextExtractor extracts the text of PDF files, for finding references in
+// papers that have no TeX source.
+type TextExtractor interface {
+	PDFText(ctx context.Context, r io.Reader) (string, error)
+}
+
+// PDFTextExtractor is the built-in TextExtractor, written in Go. It reads
+// the text of PDFs made by TeX and most other typesetting software, using
+// their ToUnicode maps where present; it does not do OCR.
+type PDFTextExtractor struct{}
+
+// Pdftotext is a TextExtractor that runs pdftotext from Poppler, which is
+// faster and copes with more unusual fonts than PDFTextExtractor.
+type Pdftotext struct {
+	// Path is the pdftotext binary (default: "pdftotext" from $PATH)
+	Path string
+}
+
+// PDFText copies the PDF to a temporary file, since r may not be backed by
+// the local disk, and runs pdftotext on it.
+func (p Pdftotext) PDFText(ctx context.Context, r io.Reader) (string, error) {
+	tmp, err := os.CreateTemp("", "arxiv-*.pdf")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, r)
+	tmp.Close()
+	if err != nil {
+		return "", err
+	}
+
+	path := p.Path
+	if path == "" {
+		path = "pdftotext"
+	}
+	cmd := exec.CommandContext(ctx, path, tmp.Name(), "-")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// PDFText returns the text of each page in order, one text line per line.
+func (PDFTextExtractor) PDFText(ctx context.Context, r io.Reader) (text string, err error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	// The parser trusts offsets and lengths found in the file; a damaged
+	// file must not take the caller down with it.
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("pdf: %v", e)
+		}
+	}()
+
+	doc, err := parsePDF(data)
+	if err != nil {
+		return "", err
+	}
+	t := &pdfText{doc: doc, fonts: make(map[any]*pdfFont)}
+	for _, page := range doc.pages() {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		var content []byte
+		switch c := doc.resolve(page["Contents"]).(type) {
+		case *pdfStream:
+			content, _ = doc.decode(c)
+		case []any:
+			for _, part := range c {
+				if s, ok := doc.resolve(part).(*pdfStream); ok {
+					data, _ := doc.decode(s)
+					content = append(append(content, data...), '\n')
+				}
+			}
+		}
+		t.run(content, doc.dict(page["Resources"]), 0)
+		t.newline()
+	}
+	return t.out.String(), nil
+}
+
+// pdfText interprets the text operators of content streams.
+type pdfText struct {
+	doc   *pdfDoc
+	fonts map[any]*pdfFont
+	out   strings.Builder
+
+	font     *pdfFont
+	size     float64
+	leading  float64
+	tm, tlm  [6]float64
+	lastY    float64
+	hasLastY bool
+}
+
+// maxFormDepth bounds the nesting of form XObjects.
+const maxFormDepth = 8
+
+func (t *pdfText) run(content []byte, resources pdfDict, depth int) {
+	l := &pdfLexer{buf: content}
+	var args []any
+	for {
+		v, err := l.object()
+		if err == io.EOF {
+			return
+		}
+		op, ok := v.(pdfKeyword)
+		if !ok {
+			if err == nil {
+				args = append(args, v)
+			}
+			continue
+		}
+		switch op {
+		case "BT":
+			t.tm = [6]float64{1, 0, 0, 1, 0, 0}
+			t.tlm = t.tm
+		case "Tf":
+			if len(args) == 2 {
+				t.font = t.loadFont(resources, args[0])
+				t.size, _ = args[1].(float64)
+			}
+		case "TL":
+			if len(args) == 1 {
+				t.leading, _ = args[0].(float64)
+			}
+		case "Td", "TD":
+			if len(args) == 2 {
+				tx, _ := args[0].(float64)
+				ty, _ := args[1].(float64)
+				if op == "TD" {
+					t.leading = -ty
+				}
+				t.moveLine(tx, ty)
+			}
+		case "T*":
+			t.moveLine(0, -t.leading)
+		case "Tm":
+			if len(args) == 6 {
+				for i, a := range args {
+					t.tlm[i], _ = a.(float64)
+				}
+				t.tm = t.tlm
+				t.moved()
+			}
+		case "Tj":
+			if len(args) == 1 {
+				t.show(args[0])
+			}
+		case "'", "\"":
+			t.moveLine(0, -t.leading)
+			if len(args) > 0 {
+				t.show(args[len(args)-1])
+			}
+		case "TJ":
+			if len(args) == 1 {
+				arr, _ := args[0].([]any)
+				for _, a := range arr {
+					if n, ok := a.(float64); ok {
+						// Adjustments are in thousandths of an em; kerning
+						// stays under 0.15 em, word spaces (0.25 em in
+						// Times) do not.
+						if n < -150 {
+							t.space()
+						}
+						continue
+					}
+					t.show(a)
+				}
+			}
+		case "Do":
+			if len(args) == 1 && depth < maxFormDepth {
+				xobjects := t.doc.dict(resources["XObject"])
+				if s, ok := t.doc.resolve(xobjects[pdfNameOf(args[0])]).(*pdfStream); ok && s.dict["Subtype"] == pdfName("Form") {
+					data, err := t.doc.decode(s)
+					if err == nil {
+						res := t.doc.dict(s.dict["Resources"])
+						if res == nil {
+							res = resources
+						}
+						t.run(data, res, depth+1)
+					}
+				}
+			}
+		case "BI":
+			// Skip inline image data, which is binary
+			for {
+				v, err := l.object()
+				if err != nil || v == pdfKeyword("ID") {
+					break
+				}
+			}
+			end := bytes.Index(l.buf[l.pos:], []byte("EI"))
+			for end >= 0 && !(end > 0 && isPDFSpace(l.buf[l.pos+end-1]) &&
+				(l.pos+end+2 >= len(l.buf) || isPDFSpace(l.buf[l.pos+end+2]))) {
+				next := bytes.Index(l.buf[l.pos+end+2:], []byte("EI"))
+				if next < 0 {
+					end = -1
+					break
+				}
+				end += 2 + next
+			}
+			if end < 0 {
+				return
+			}
+			l.pos += end + 2
+		}
+		args = args[:0]
+	}
+}
+
+func pdfNameOf(v any) pdfName {
+	n, _ := v.(pdfName)
+	return n
+}
+
+// moveLine starts a new text line offset by (tx, ty) from the current one.
+func (t *pdfText) moveLine(tx, ty float64) {
+	m := t.tlm
+	t.tlm[4] = m[4] + tx*m[0] + ty*m[2]
+	t.tlm[5] = m[5] + tx*m[1] + ty*m[3]
+	t.tm = t.tlm
+	t.moved()
+}
+
+// moved separates the text at a new position from the text before: by a
+// line break if the baseline moved by more than half a line, or else by a
+// space.
+func (t *pdfText) moved() {
+	y := t.tm[5]
+	height := math.Abs(t.size * t.tm[3])
+	if height == 0 {
+		height = math.Abs(t.size)
+	}
+	switch {
+	case !t.hasLastY:
+	case math.Abs(y-t.lastY) > height/2:
+		t.newline()
+	default:
+		t.space()
+	}
+	t.lastY, t.hasLastY = y, true
+}
+
+func (t *pdfText) show(v any) {
+	s, ok := v.(pdfString)
+	if !ok || t.font == nil {
+		return
+	}
+	t.out.WriteString(t.font.decode(s))
+}
+
+func (t *pdfText) space() {
+	if s := t.out.String(); s != "" && !strings.HasSuffix(s, " ") && !strings.HasSuffix(s, "\n") {
+		t.out.WriteByte(' ')
+	}
+}
+
+func (t *pdfText) newline() {
+	if s := t.out.String(); s != "" && !strings.HasSuffix(s, "\n") {
+		t.out.WriteByte('\n')
+	}
+}
+
+// pdfFont maps the character codes of a font to text.
+type pdfFont struct {
+	codeLen   int // Bytes per code: 1, or 2 for composite fonts
+	toUnicode map[uint32]string
+	encoding  [256]string // For simple fonts without a ToUnicode entry
+}
+
+func (t *pdfText) loadFont(resources pdfDict, name any) *pdfFont {
+	ref := t.doc.dict(resources["Font"])[pdfNameOf(name)]
+	key := any(ref)
+	if _, ok := ref.(pdfRef); !ok {
+		key = fmt.Sprintf("%p/%v", resources, name)
+	}
+	if f, ok := t.fonts[key]; ok {
+		return f
+	}
+
+	dict := t.doc.dict(ref)
+	f := &pdfFont{codeLen: 1, encoding: standardEncoding}
+	if dict["Subtype"] == pdfName("Type0") {
+		f.codeLen = 2
+	}
+	if s, ok := t.doc.resolve(dict["ToUnicode"]).(*pdfStream); ok {
+		if data, err := t.doc.decode(s); err == nil {
+			f.parseCMap(data)
+		}
+	}
+	switch enc := t.doc.resolve(dict["Encoding"]).(type) {
+	case pdfName:
+		f.applyEncoding(enc)
+	case pdfDict:
+		if base, ok := enc["BaseEncoding"].(pdfName); ok {
+			f.applyEncoding(base)
+		}
+		diffs, _ := t.doc.resolve(enc["Differences"]).([]any)
+		code := 0
+		for _, d := range diffs {
+			switch d := t.doc.resolve(d).(type) {
+			case float64:
+				code = int(d)
+			case pdfName:
+				if code >= 0 && code < 256 {
+					f.encoding[code] = glyphText(string(d))
+				}
+				code++
+			}
+		}
+	}
+	t.fonts[key] = f
+	return f
+}
+
+func (f *pdfFont) decode(s pdfString) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		n := f.codeLen
+		if i+n > len(s) {
+			n = len(s) - i
+		}
+		var code uint32
+		for j := 0; j < n; j++ {
+			code = code<<8 | uint32(s[i+j])
+		}
+		i += n
+		if text, ok := f.toUnicode[code]; ok {
+			b.WriteString(text)
+		} else if n == 1 {
+			b.WriteString(f.encoding[code])
+		}
+	}
+	return b.String()
+}
+
+// parseCMap reads the bfchar and bfrange mappings of a ToUnicode CMap.
+func (f *pdfFont) parseCMap(data []byte) {
+	f.toUnicode = make(map[uint32]string)
+	l := &pdfLexer{buf: data}
+	var operands []any
+	for {
+		v, err := l.object()
+		if err == io.EOF {
+			return
+		}
+		kw, ok := v.(pdfKeyword)
+		if !ok {
+			operands = append(operands, v)
+			continue
+		}
+		switch kw {
+		case "endcodespacerange":
+			if len(operands) >= 2 {
+				if lo, ok := operands[0].(pdfString); ok && len(lo) > 0 {
+					f.codeLen = len(lo)
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, _ := operands[i].(pdfString)
+				dst, _ := operands[i+1].(pdfString)
+				f.toUnicode[cmapCode(src)] = utf16Text(dst)
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, _ := operands[i].(pdfString)
+				hi, _ := operands[i+1].(pdfString)
+				from, to := cmapCode(lo), cmapCode(hi)
+				if to < from || to-from > 0xffff {
+					continue
+				}
+				switch dst := operands[i+2].(type) {
+				case pdfString:
+					// Successive codes map to successive characters
+					base := []byte(dst)
+					for code := from; code <= to; code++ {
+						f.toUnicode[code] = utf16Text(pdfString(base))
+						if len(base) > 0 {
+							base = bytes.Clone(base)
+							base[len(base)-1]++
+						}
+					}
+				case []any:
+					for j, d := range dst {
+						if s, ok := d.(pdfString); ok && from+uint32(j) <= to {
+							f.toUnicode[from+uint32(j)] = utf16Text(s)
+						}
+					}
+				}
+			}
+		}
+		if strings.HasPrefix(string(kw), "begin") || strings.HasPrefix(string(kw), "end") {
+			operands = operands[:0]
+		}
+	}
+}
+
+func cmapCode(s pdfString) uint32 {
+	var code uint32
+	for i := 0; i < len(s); i++ {
+		code = code<<8 | uint32(s[i])
+	}
+	return code
+}
+
+// utf16Text decodes the UTF-16BE text of a CMap destination.
+func utf16Text(s pdfString) string {
+	units := make([]uint16, len(s)/2)
+	for i := range units {
+		units[i] = uint16(s[2*i])<<8 | uint16(s[2*i+1])
+	}
+	return string(utf16.Decode(units))
+}
+
+// standardEncoding decodes simple fonts that give no encoding: ASCII, the
+// ligatures at the low codes of TeX's OT1 fonts, and Latin-1 above.
+var standardEncoding = func() [256]string {
+	var enc [256]string
+	for i := 32; i < 256; i++ {
+		if i < 127 || i >= 160 {
+			enc[i] = string(rune(i))
+		}
+	}
+	for code, s := range map[int]string{
+		0x0b: "ff", 0x0c: "fi", 0x0d: "fl", 0x0e: "ffi", 0x0f: "ffl",
+		0x91: "‘", 0x92: "’", 0x93: "“", 0x94: "”", 0x96: "–", 0x97: "—",
+	} {
+		enc[code] = s
+	}
+	return enc
+}()
+
+// applyEncoding applies a named base encoding. Only the codes where
+// MacRomanEncoding and WinAnsiEncoding differ from the standard encoding
+// in ways that matter for text are handled.
+func (f *pdfFont) applyEncoding(name pdfName) {
+	switch name {
+	case "MacRomanEncoding":
+		for code, s := range map[int]string{0xd0: "–", 0xd1: "—", 0xd2: "“", 0xd3: "”", 0xd4: "‘", 0xd5: "’", 0xde: "fi", 0xdf: "fl"} {
+			f.encoding[code] = s
+		}
+	case "StandardEncoding":
+		f.encoding['\''] = "’"
+		f.encoding['`'] = "‘"
+		f.encoding[0xae] = "fi"
+		f.encoding[0xaf] = "fl"
+	}
+}
+
+// glyphText returns the text of a glyph name from an encoding's
+// Differences array.
+func glyphText(name string) string {
+	if len(name) == 1 {
+		return name
+	}
+	if s, ok := glyphNames[name]; ok {
+		return s
+	}
+	if hexCode, ok := strings.CutPrefix(name, "uni"); ok && len(hexCode) == 4 {
+		if n, err := strconv.ParseUint(hexCode, 16, 16); err == nil {
+			return string(rune(n))
+		}
+	}
+	return ""
+}
+
+var glyphNames = map[string]string{
+	"space": " ", "exclam": "!", "quotedbl": "\"", "numbersign": "#", "dollar": "$",
+	"percent": "%", "ampersand": "&", "quotesingle": "'", "parenleft": "(", "parenright": ")",
+	"asterisk": "*", "plus": "+", "comma": ",", "hyphen": "-", "period": ".", "slash": "/",
+	"zero": "0", "one": "1", "two": "2", "three": "3", "four": "4", "five": "5", "six": "6",
+	"seven": "7", "eight": "8", "nine": "9", "colon": ":", "semicolon": ";", "less": "<",
+	"equal": "=", "greater": ">", "question": "?", "at": "@", "bracketleft": "[",
+	"backslash": "\\", "bracketright": "]", "asciicircum": "^", "underscore": "_",
+	"quoteleft": "‘", "quoteright": "’", "quotedblleft": "“", "quotedblright": "”",
+	"braceleft": "{", "bar": "|", "braceright": "}", "asciitilde": "~", "endash": "–",
+	"emdash": "—", "fi": "fi", "fl": "fl", "ff": "ff", "ffi": "ffi", "ffl": "ffl",
+	"dotlessi": "ı", "bullet": "•", "minus": "−", "periodcentered": "·",
+}
@@ -0,0 +1,198 @@
+package arxiv
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DownloadPolicy is a rule that queues downloads for papers as they are
+// first added to the cache by SyncMetadata or NewSubmissions. A paper
+// matches if it satisfies every predicate that is set; within a predicate,
+// any listed value matches.
+type DownloadPolicy struct {
+	ID   int64
+	Name string
+
+	// Categories matches papers in any of these categories or archives
+	Categories []string
+
+	// Authors matches papers by any of these authors (substring match)
+	Authors []string
+
+	// Licenses matches papers whose license it allows
+	Licenses LicenseFilter
+
+	// MaxAge matches papers submitted at most this long ago, so that a
+	// backfill sync does not queue the whole archive
+	MaxAge time.Duration
+
+	// MaxSize skips downloads larger than this many bytes when the queue is
+	// processed (0: no limit)
+	MaxSize int64
+
+	// Kind is what to download: QueueSource (default), QueuePDF or QueueAll
+	Kind string
+
+	Created time.Time
+}
+
+func (p *DownloadPolicy) validate() error {
+	switch p.Kind {
+	case "", QueueSource, QueuePDF, QueueAll:
+		return nil
+	default:
+		return fmt.Errorf("invalid download kind %q", p.Kind)
+	}
+}
+
+// AddPolicy saves a download policy and returns its ID.
+func (c *Cache) AddPolicy(ctx context.Context, p *DownloadPolicy) (int64, error) {
+	if err := p.validate(); err != nil {
+		return 0, err
+	}
+	res, err := c.db.ExecContext(ctx, `
+		INSERT INTO download_policies (name, categories, authors, licenses, max_age, max_size, kind, created)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, p.Name, strings.Join(p.Categories, " "), strings.Join(p.Authors, "\n"), p.Licenses.String(),
+		int64(p.MaxAge/time.Second), p.MaxSize, queueKind(p.Kind), time.Now().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// RemovePolicy deletes a download policy. Downloads it has already queued
+// stay queued.
+func (c *Cache) RemovePolicy(ctx context.Context, id int64) error {
+	res, err := c.db.ExecContext(ctx, "DELETE FROM download_policies WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Policies returns the download policies stored in the cache. Policies
+// given in Options.Policies are not included.
+func (c *Cache) Policies(ctx context.Context) ([]DownloadPolicy, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT id, name, categories, authors, licenses, max_age, max_size, kind, created
+		FROM download_policies
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []DownloadPolicy
+	for rows.Next() {
+		var p DownloadPolicy
+		var cats, authors, licenses, created string
+		var maxAge int64
+		err := rows.Scan(&p.ID, &p.Name, &cats, &authors, &licenses, &maxAge, &p.MaxSize, &p.Kind, &created)
+		if err != nil {
+			return nil, err
+		}
+		p.Categories = strings.Fields(cats)
+		if authors != "" {
+			p.Authors = strings.Split(authors, "\n")
+		}
+		if p.Licenses, err = ParseLicenseFilter(licenses); err != nil {
+			return nil, fmt.Errorf("policy %d: %w", p.ID, err)
+		}
+		p.MaxAge = time.Duration(maxAge) * time.Second
+		p.Created, _ = time.Parse(time.RFC3339, created)
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+// applyPolicies queues downloads for the papers in ids that match a stored
+// or configured download policy. Like evaluateWatches, it is called with
+// the IDs of papers that were just added to the cache.
+func (c *Cache) applyPolicies(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	stored, err := c.Policies(ctx)
+	if err != nil {
+		return err
+	}
+	policies := append(stored, c.policies...)
+	if len(policies) == 0 {
+		return nil
+	}
+
+	idList, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, p := range policies {
+		query := `
+			SELECT p.id FROM papers p
+			WHERE p.id IN (SELECT value FROM json_each(?))
+		`
+		args := []any{string(idList)}
+		if len(p.Categories) > 0 {
+			cond, catArgs := categoryMatch("p.categories", p.Categories)
+			query += " AND " + cond
+			args = append(args, catArgs...)
+		}
+		if len(p.Authors) > 0 {
+			var conds []string
+			for _, author := range p.Authors {
+				conds = append(conds, "p.authors LIKE '%' || ? || '%'")
+				args = append(args, author)
+			}
+			query += " AND (" + strings.Join(conds, " OR ") + ")"
+		}
+		if len(p.Licenses) > 0 {
+			cond, licenseArgs, err := c.licenseMatch(ctx, "p.license", p.Licenses)
+			if err != nil {
+				return err
+			}
+			query += " AND " + cond
+			args = append(args, licenseArgs...)
+		}
+		if p.MaxAge > 0 {
+			query += " AND p.created >= ?"
+			args = append(args, time.Now().Add(-p.MaxAge).UTC().Format("2006-01-02"))
+		}
+
+		matches, err := c.queryIDs(ctx, query, args...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("policy %s: %w", p.label(), err))
+			continue
+		}
+		for _, id := range matches {
+			if err := c.enqueue(ctx, id, queueKind(p.Kind), p.MaxSize); err != nil {
+				return err
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *DownloadPolicy) label() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return fmt.Sprint(p.ID)
+}
+
+func queueKind(kind string) string {
+	if kind == "" {
+		return QueueSource
+	}
+	return kind
+}
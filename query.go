@@ -0,0 +1,120 @@
+package arxiv
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// QueryOptions configures a search against the arXiv API.
+type QueryOptions struct {
+	// MaxResults is the maximum number of papers to import (default 100)
+	MaxResults int
+
+	// PageSize is the number of results requested per API call
+	// (default 100, at most 2000 per arXiv's API documentation)
+	PageSize int
+
+	// Start is the offset of the first result (default 0)
+	Start int
+
+	// SortBy is "relevance", "lastUpdatedDate" or "submittedDate"
+	// (default: the API's default, relevance)
+	SortBy string
+
+	// SortOrder is "ascending" or "descending"
+	SortOrder string
+
+	// Progress callback, called after each page
+	Progress func(fetched, total int)
+}
+
+// QueryResult is the result of a Query.
+type QueryResult struct {
+	// TotalResults is the number of papers matching the query on arXiv
+	TotalResults int
+
+	// Papers are the matching papers that were imported into the cache
+	Papers []*Paper
+}
+
+// Query searches arXiv using the API's search_query syntax
+// (e.g., "cat:cs.LG AND ti:diffusion") and imports the matching papers'
+// metadata into the cache, the same way FetchBatch does. Results are
+// fetched page by page, and each request is paced by the cache's rate
+// limiter.
+func (c *Cache) Query(ctx context.Context, q string, opts *QueryOptions) (*QueryResult, error) {
+	if opts == nil {
+		opts = &QueryOptions{}
+	}
+	maxResults := opts.MaxResults
+	if maxResults <= 0 {
+		maxResults = 100
+	}
+	pageSize := opts.PageSize
+	if pageSize <= 0 {
+		pageSize = 100
+	}
+	if pageSize > 2000 {
+		pageSize = 2000
+	}
+
+	result := &QueryResult{}
+	start := opts.Start
+	fetched := 0
+	for fetched < maxResults {
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		default:
+		}
+
+		n := pageSize
+		if remaining := maxResults - fetched; n > remaining {
+			n = remaining
+		}
+
+		params := url.Values{}
+		params.Set("search_query", q)
+		params.Set("start", strconv.Itoa(start))
+		params.Set("max_results", strconv.Itoa(n))
+		if opts.SortBy != "" {
+			params.Set("sortBy", opts.SortBy)
+		}
+		if opts.SortOrder != "" {
+			params.Set("sortOrder", opts.SortOrder)
+		}
+
+		feed, err := c.fetchFeed(ctx, params)
+		if err != nil {
+			return result, fmt.Errorf("query: %w", err)
+		}
+		result.TotalResults = feed.TotalResults
+
+		var papers []*Paper
+		for _, entry := range feed.Entries {
+			papers = append(papers, parseAtomEntry(entry))
+		}
+		stored, errs := c.storeAPIPapers(ctx, papers)
+		if len(errs) > 0 {
+			return result, fmt.Errorf("store papers: %w", errs[0])
+		}
+		result.Papers = append(result.Papers, stored...)
+
+		fetched += len(feed.Entries)
+		start += len(feed.Entries)
+
+		if opts.Progress != nil {
+			opts.Progress(fetched, min(maxResults, feed.TotalResults))
+		}
+
+		// An empty page means the result set is exhausted (or the API
+		// returned a short page; either way, stop rather than loop).
+		if len(feed.Entries) == 0 || start >= feed.TotalResults {
+			break
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,104 @@
+package arxiv
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	api, c := newFakeAPI(t)
+	var all []string
+	for i := range 5 {
+		id := fmt.Sprintf("2301.%05d", i+1)
+		api.add(id, 1, "Paper "+id, "")
+		all = append(all, id)
+	}
+
+	tests := []struct {
+		name     string
+		opts     *QueryOptions
+		want     []string
+		pages    []string
+		progress []string // fetched/total per page
+	}{
+		{
+			name:  "defaults",
+			want:  all,
+			pages: []string{"0+100"},
+		},
+		{
+			name:     "pages",
+			opts:     &QueryOptions{PageSize: 2},
+			want:     all,
+			pages:    []string{"0+2", "2+2", "4+2"},
+			progress: []string{"2/5", "4/5", "5/5"},
+		},
+		{
+			name:     "max results",
+			opts:     &QueryOptions{MaxResults: 3, PageSize: 2},
+			want:     all[:3],
+			pages:    []string{"0+2", "2+1"},
+			progress: []string{"2/3", "3/3"},
+		},
+		{
+			name:  "start",
+			opts:  &QueryOptions{Start: 3},
+			want:  all[3:],
+			pages: []string{"3+100"},
+		},
+		{
+			name:  "start past the end",
+			opts:  &QueryOptions{Start: 10, PageSize: 2},
+			pages: []string{"10+2"},
+		},
+		{
+			name:  "page size capped",
+			opts:  &QueryOptions{MaxResults: 5000, PageSize: 5000},
+			want:  all,
+			pages: []string{"0+2000"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var progress []string
+			if tt.opts != nil {
+				tt.opts.Progress = func(fetched, total int) {
+					progress = append(progress, fmt.Sprintf("%d/%d", fetched, total))
+				}
+			}
+			res, err := c.Query(context.Background(), "cat:cs.LG", tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.TotalResults != len(all) {
+				t.Errorf("TotalResults = %d, want %d", res.TotalResults, len(all))
+			}
+			var got []string
+			for _, p := range res.Papers {
+				got = append(got, p.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("papers = %v, want %v", got, tt.want)
+			}
+			api.mu.Lock()
+			pages := api.pages
+			api.pages = nil
+			api.mu.Unlock()
+			if !slices.Equal(pages, tt.pages) {
+				t.Errorf("pages = %v, want %v", pages, tt.pages)
+			}
+			if tt.progress != nil && !slices.Equal(progress, tt.progress) {
+				t.Errorf("progress = %v, want %v", progress, tt.progress)
+			}
+
+			// Imported papers are in the cache
+			for _, id := range got {
+				if _, err := c.GetPaper(context.Background(), id); err != nil {
+					t.Errorf("GetPaper(%s): %v", id, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package arxiv
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// Download queue entry types.
+const (
+	QueueSource = "source"
+	QueuePDF    = "pdf"
+	QueueAll    = "all" // both source and PDF
+)
+
+// Enqueue adds a paper to the download queue. Queuing a paper that is
+// already queued for a different type queues it for both.
+func (c *Cache) Enqueue(ctx context.Context, paperID, kind string) error {
+	return c.enqueue(ctx, paperID, kind, 0)
+}
+
+// enqueue queues a download, skipping files larger than maxSize bytes
+// (0: no limit). The most permissive size limit of merged entries wins,
+// and a queued entry that had failed gets a fresh set of attempts.
+func (c *Cache) enqueue(ctx context.Context, paperID, kind string, maxSize int64) error {
+	_, err := c.db.ExecContext(ctx, `
+		INSERT INTO download_queue (paper_id, type, added, max_size) VALUES (?, ?, ?, ?)
+		ON CONFLICT(paper_id) DO UPDATE SET
+			type = CASE WHEN type = excluded.type THEN type ELSE 'all' END,
+			max_size = CASE WHEN max_size = 0 OR excluded.max_size = 0 THEN 0
+			                ELSE MAX(max_size, excluded.max_size) END,
+			attempts = 0,
+			last_error = NULL
+	`, paperID, kind, time.Now().Format(time.RFC3339), maxSize)
+	return err
+}
+
+// QueueOptions configures ProcessDownloadQueue.
+type QueueOptions struct {
+	// Limit is the maximum number of entries to process (default: all)
+	Limit int
+
+	// MaxAttempts is how many times an entry is tried before it is left in
+	// the queue for inspection (default 3)
+	MaxAttempts int
+
+	// Progress callback, called after each entry with its error, if any
+	Progress func(paperID string, err error)
+}
+
+// QueueStats summarizes a ProcessDownloadQueue run.
+type QueueStats struct {
+	Downloaded int
+	Failed     int
+	Skipped    int // Larger than the entry's size limit
+}
+
+// ProcessDownloadQueue downloads queued papers, highest priority and oldest
+// first. Metadata is fetched for papers not yet in the cache. Completed
+// entries are removed; failed ones record the error and are retried on the
+// next run until MaxAttempts is reached.
+func (c *Cache) ProcessDownloadQueue(ctx context.Context, opts *QueueOptions) (*QueueStats, error) {
+	if opts == nil {
+		opts = &QueueOptions{}
+	}
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT paper_id, type, max_size FROM download_queue
+		WHERE attempts < ?
+		ORDER BY priority DESC, added
+		LIMIT ?
+	`, maxAttempts, limit)
+	if err != nil {
+		return nil, err
+	}
+	type entry struct {
+		id, kind string
+		maxSize  int64
+	}
+	var entries []entry
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.id, &e.kind, &e.maxSize); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	stats := &QueueStats{}
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		dl := &DownloadOptions{
+			DownloadPDF:    e.kind == QueuePDF || e.kind == QueueAll,
+			DownloadSource: e.kind != QueuePDF,
+			MaxSize:        e.maxSize,
+		}
+		_, err := c.FetchAndDownload(ctx, e.id, dl)
+		if ctx.Err() != nil {
+			// Interrupted, not failed: leave the entry as it was
+			return stats, ctx.Err()
+		}
+		switch {
+		case errors.Is(err, ErrTooLarge):
+			// Retrying won't help
+			stats.Skipped++
+			c.db.ExecContext(ctx, "DELETE FROM download_queue WHERE paper_id = ?", e.id)
+		case err != nil:
+			stats.Failed++
+			c.db.ExecContext(ctx, `
+				UPDATE download_queue SET attempts = attempts + 1, last_error = ? WHERE paper_id = ?
+			`, err.Error(), e.id)
+		default:
+			stats.Downloaded++
+			c.db.ExecContext(ctx, "DELETE FROM download_queue WHERE paper_id = ?", e.id)
+		}
+		if opts.Progress != nil {
+			opts.Progress(e.id, err)
+		}
+	}
+
+	return stats, nil
+}
+
+// GCStats summarizes a GC run.
+type GCStats struct {
+	Objects       int   // Unreferenced dedup objects removed
+	ObjectBytes   int64 // Bytes freed by removing them
+	FetchFailures int   // Expired negative cache entries removed
+	QueueEntries  int   // Queue entries for papers already downloaded
+}
+
+// GC removes data the cache no longer needs: dedup objects no paper's
+// source tree refers to (e.g., after packing), expired negative cache
+// entries, and queue entries whose downloads already exist.
+func (c *Cache) GC(ctx context.Context) (*GCStats, error) {
+	stats := &GCStats{}
+
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT hash, size FROM objects
+		WHERE hash NOT IN (SELECT hash FROM source_files)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	type object struct {
+		hash string
+		size int64
+	}
+	var orphans []object
+	for rows.Next() {
+		var o object
+		if err := rows.Scan(&o.hash, &o.size); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		orphans = append(orphans, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, o := range orphans {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		if err := os.Remove(c.objectPath(o.hash)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return stats, err
+		}
+		if _, err := c.db.ExecContext(ctx, "DELETE FROM objects WHERE hash = ?", o.hash); err != nil {
+			return stats, err
+		}
+		stats.Objects++
+		stats.ObjectBytes += o.size
+	}
+
+	now := time.Now()
+	res, err := c.db.ExecContext(ctx, `
+		DELETE FROM fetch_failures
+		WHERE last_attempt < CASE reason WHEN ? THEN ? ELSE ? END
+	`, string(ReasonWithdrawn), now.Add(-withdrawnCacheTTL).Format(time.RFC3339),
+		now.Add(-negativeCacheTTL).Format(time.RFC3339))
+	if err != nil {
+		return stats, err
+	}
+	n, _ := res.RowsAffected()
+	stats.FetchFailures = int(n)
+
+	res, err = c.db.ExecContext(ctx, `
+		DELETE FROM download_queue WHERE paper_id IN (
+			SELECT q.paper_id FROM download_queue q JOIN papers p ON p.id = q.paper_id
+			WHERE (q.type = 'pdf' AND p.pdf_downloaded = 1)
+			   OR (q.type = 'source' AND p.src_downloaded = 1)
+			   OR (q.type = 'all' AND p.pdf_downloaded = 1 AND p.src_downloaded = 1)
+		)
+	`)
+	if err != nil {
+		return stats, err
+	}
+	n, _ = res.RowsAffected()
+	stats.QueueEntries = int(n)
+
+	return stats, nil
+}
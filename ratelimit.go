@@ -0,0 +1,136 @@
+package arxiv
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// RateLimit paces requests to one upstream host.
+type RateLimit struct {
+	// Interval is the sustained spacing between requests
+	Interval time.Duration
+
+	// Burst is how many requests may be made back to back (default 1)
+	Burst int
+}
+
+// DefaultRateLimits follow arXiv's guidance of one request every three
+// seconds, for both the API/OAI host and the PDF/source host.
+var DefaultRateLimits = map[string]RateLimit{
+	"export.arxiv.org": {Interval: 3 * time.Second, Burst: 1},
+	"arxiv.org":        {Interval: 3 * time.Second, Burst: 1},
+}
+
+// limiter is a per-host token bucket shared by everything using a Cache.
+//
+// Reservations are made in the rate_limits table using the generic cell
+// rate algorithm: each host has a theoretical arrival time (TAT), and a
+// request reserves the slot max(TAT, now) and advances TAT by one interval.
+// A single UPSERT makes the reservation atomic, so separate processes
+// sharing a cache directory (sync, fetch, serve) are paced together. If the
+// database is unavailable the limiter falls back to in-process pacing.
+type limiter struct {
+	c      *Cache
+	limits map[string]RateLimit
+	now    func() time.Time
+
+	mu    sync.Mutex
+	local map[string]time.Time // fallback TAT per host
+}
+
+func newLimiter(c *Cache, overrides map[string]RateLimit) *limiter {
+	limits := make(map[string]RateLimit, len(DefaultRateLimits)+len(overrides))
+	for host, rl := range DefaultRateLimits {
+		limits[host] = rl
+	}
+	for host, rl := range overrides {
+		limits[host] = rl
+	}
+	return &limiter{c: c, limits: limits, now: time.Now, local: make(map[string]time.Time)}
+}
+
+// wait blocks until a request to host is allowed.
+func (l *limiter) wait(ctx context.Context, host string) error {
+	delay := l.delay(ctx, host)
+	if delay <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// delay reserves a request to host and returns how long the caller must
+// wait before making it.
+func (l *limiter) delay(ctx context.Context, host string) time.Duration {
+	rl, ok := l.limits[host]
+	if !ok || rl.Interval <= 0 {
+		return 0
+	}
+	burst := rl.Burst
+	if burst < 1 {
+		burst = 1
+	}
+
+	now := l.now()
+	tat, err := l.reserve(ctx, host, now, rl.Interval)
+	if err != nil {
+		tat = l.reserveLocal(host, now, rl.Interval)
+	}
+
+	allowAt := tat.Add(-time.Duration(burst) * rl.Interval)
+	return allowAt.Sub(now)
+}
+
+// reserve advances the host's TAT in the database and returns the new value.
+func (l *limiter) reserve(ctx context.Context, host string, now time.Time, interval time.Duration) (time.Time, error) {
+	var tat int64
+	err := l.c.db.QueryRowContext(ctx, `
+		INSERT INTO rate_limits (host, tat) VALUES (?1, ?2 + ?3)
+		ON CONFLICT(host) DO UPDATE SET tat = MAX(tat, ?2) + ?3
+		RETURNING tat
+	`, host, now.UnixNano(), int64(interval)).Scan(&tat)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, tat), nil
+}
+
+func (l *limiter) reserveLocal(host string, now time.Time, interval time.Duration) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	tat := l.local[host]
+	if tat.Before(now) {
+		tat = now
+	}
+	tat = tat.Add(interval)
+	l.local[host] = tat
+	return tat
+}
+
+// wait blocks until a request to rawURL's host is allowed by the cache's
+// shared rate limiter.
+func (c *Cache) wait(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	return c.limiter.wait(ctx, u.Hostname())
+}
+
+// httpGet performs a rate-limited GET request.
+func (c *Cache) httpGet(ctx context.Context, rawURL string) (*http.Response, error) {
+	if err := c.wait(ctx, rawURL); err != nil {
+		return nil, err
+	}
+	return httpGetWithContext(ctx, rawURL)
+}
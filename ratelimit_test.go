@@ -0,0 +1,93 @@
+package arxiv
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	const host = "export.arxiv.org"
+	type step struct {
+		advance time.Duration // Clock change before the request
+		reopen  bool          // Reopen the cache before the request
+		want    time.Duration // Delay of the request
+	}
+	tests := []struct {
+		name  string
+		limit RateLimit
+		steps []step
+	}{
+		{
+			name:  "spacing",
+			limit: RateLimit{Interval: 3 * time.Second},
+			steps: []step{
+				{want: 0},
+				{want: 3 * time.Second},
+				{want: 6 * time.Second},
+				{advance: 10 * time.Second, want: 0},
+				{advance: time.Second, want: 2 * time.Second},
+			},
+		},
+		{
+			name:  "burst",
+			limit: RateLimit{Interval: time.Second, Burst: 3},
+			steps: []step{
+				{want: 0},
+				{want: 0},
+				{want: 0},
+				{want: time.Second},
+				{advance: 500 * time.Millisecond, want: 1500 * time.Millisecond},
+				// Idle time refills the burst, but no further
+				{advance: time.Minute, want: 0},
+				{want: 0},
+				{want: 0},
+				{want: time.Second},
+			},
+		},
+		{
+			name:  "reopened cache",
+			limit: RateLimit{Interval: 3 * time.Second, Burst: 2},
+			steps: []step{
+				{want: 0},
+				{want: 0},
+				{reopen: true, want: 3 * time.Second},
+				{advance: time.Second, reopen: true, want: 5 * time.Second},
+				{advance: time.Minute, reopen: true, want: 0},
+			},
+		},
+		{
+			name:  "unlimited",
+			limit: RateLimit{},
+			steps: []step{{want: 0}, {want: 0}, {want: 0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+			clock := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+			open := func() *Cache {
+				c, err := OpenWithOptions(dir, &Options{RateLimits: map[string]RateLimit{host: tt.limit}})
+				if err != nil {
+					t.Fatal(err)
+				}
+				c.limiter.now = func() time.Time { return clock }
+				return c
+			}
+			c := open()
+			defer func() { c.Close() }()
+
+			for i, s := range tt.steps {
+				clock = clock.Add(s.advance)
+				if s.reopen {
+					c.Close()
+					c = open()
+				}
+				if got := max(c.limiter.delay(ctx, host), 0); got != s.want {
+					t.Errorf("request %d: delay %v, want %v", i, got, s.want)
+				}
+			}
+		})
+	}
+}
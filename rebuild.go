@@ -0,0 +1,261 @@
+package arxiv
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExtractorVersion identifies the reference extraction code. It is
+// recorded for each indexed paper, and RebuildCitations re-indexes papers
+// indexed by another version. Increase it whenever extraction changes.
+const ExtractorVersion = 1
+
+// RebuildOptions configures RebuildCitations.
+type RebuildOptions struct {
+	// Workers is the number of papers extracted in parallel
+	// (default: GOMAXPROCS)
+	Workers int
+
+	// Force re-indexes every paper, including those unchanged since they
+	// were last indexed
+	Force bool
+
+	// Progress callback, called after each paper
+	Progress func(paperID string, done, total int)
+}
+
+// RebuildStats summarizes a RebuildCitations run.
+type RebuildStats struct {
+	Indexed   int // Papers whose references were extracted again
+	Unchanged int // Papers skipped as unchanged
+	Missing   int // Papers whose source and PDF were not found
+}
+
+// RebuildAllCitations re-indexes the references, and so the citations
+// view, of every paper with source or PDF downloaded.
+func (c *Cache) RebuildAllCitations(ctx context.Context) error {
+	_, err := c.RebuildCitations(ctx, nil)
+	return err
+}
+
+// RebuildCitations re-indexes the references of the papers with source or
+// PDF downloaded, skipping those whose source hash and extractor version
+// are unchanged since they were last indexed.
+//
+// Papers are extracted in parallel and written in a single transaction,
+// so an interrupted rebuild leaves the previous citation graph in place.
+// References of papers no longer downloaded are removed. References
+// without an arXiv ID are resolved for the papers indexed, and for the
+// other papers where a paper added or updated since the last rebuild
+// could match them.
+func (c *Cache) RebuildCitations(ctx context.Context, opts *RebuildOptions) (*RebuildStats, error) {
+	if opts == nil {
+		opts = &RebuildOptions{}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	type paper struct {
+		id, hash  string
+		extractor int
+	}
+	rows, err := tx.QueryContext(ctx, `
+		SELECT p.id, COALESCE(i.source_hash, ''), COALESCE(i.extractor, 0)
+		FROM papers p LEFT JOIN paper_index i ON i.paper_id = p.id
+		WHERE p.src_downloaded = 1 OR p.pdf_downloaded = 1
+		ORDER BY p.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	var papers []paper
+	for rows.Next() {
+		var p paper
+		if err := rows.Scan(&p.id, &p.hash, &p.extractor); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		papers = append(papers, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Workers read the database through tx too: once the transaction has
+	// written enough to spill its cache, other connections cannot read
+	// until it commits.
+	type result struct {
+		id, hash  string
+		unchanged bool
+		refs      []BibReference
+		contexts  []CitationContext
+		err       error
+	}
+	jobs := make(chan paper)
+	results := make(chan result)
+	var wg sync.WaitGroup
+	for range cmp.Or(opts.Workers, runtime.GOMAXPROCS(0)) {
+		wg.Go(func() {
+			for p := range jobs {
+				r := result{id: p.id}
+				r.hash, r.err = c.sourceHash(ctx, tx, p.id)
+				if r.err == nil && !opts.Force && r.hash == p.hash && p.extractor == ExtractorVersion {
+					r.unchanged = true
+				} else if r.err == nil {
+					r.refs, r.contexts, r.err = c.extractPaper(ctx, tx, p.id)
+				}
+				select {
+				case results <- r:
+				case <-ctx.Done():
+					return
+				}
+			}
+		})
+	}
+	go func() {
+		defer close(jobs)
+		for _, p := range papers {
+			select {
+			case jobs <- p:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	stats := &RebuildStats{}
+	indexed := make(map[string]bool)
+	done := 0
+	for r := range results {
+		switch {
+		case errors.Is(r.err, fs.ErrNotExist):
+			stats.Missing++
+		case r.err != nil:
+			cancel()
+			return stats, fmt.Errorf("%s: %w", r.id, r.err)
+		case r.unchanged:
+			stats.Unchanged++
+		default:
+			if err := writeReferences(ctx, tx, r.id, r.refs, r.contexts); err != nil {
+				cancel()
+				return stats, err
+			}
+			if err := recordIndex(ctx, tx, r.id, r.hash); err != nil {
+				cancel()
+				return stats, err
+			}
+			indexed[r.id] = true
+			stats.Indexed++
+		}
+		done++
+		if opts.Progress != nil {
+			opts.Progress(r.id, done, len(papers))
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return stats, err
+	}
+
+	for _, table := range []string{"paper_references", "citation_contexts", "paper_index"} {
+		_, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE paper_id NOT IN (
+			SELECT id FROM papers WHERE src_downloaded = 1 OR pdf_downloaded = 1
+		)`)
+		if err != nil {
+			return stats, err
+		}
+	}
+	for id := range indexed {
+		if _, err := c.resolveReferences(ctx, tx, id); err != nil {
+			return stats, err
+		}
+	}
+	if _, err := c.resolveChanged(ctx, tx, indexed); err != nil {
+		return stats, err
+	}
+	return stats, tx.Commit()
+}
+
+// recordIndex records that a paper's references were extracted from
+// source with the given hash by the current extractor.
+func recordIndex(ctx context.Context, db dbtx, paperID, hash string) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO paper_index (paper_id, source_hash, extractor, indexed)
+		VALUES (?, ?, ?, ?)
+	`, paperID, hash, ExtractorVersion, time.Now().Format(time.RFC3339))
+	return err
+}
+
+// sourceHash returns a hash of what a paper's references are extracted
+// from: the name and size of each source file, the contents of its TeX
+// and bibliography files, and the size of its downloaded PDF. Packing a
+// source tree leaves its hash unchanged. It returns an error satisfying
+// errors.Is(err, fs.ErrNotExist) if neither source nor PDF is downloaded.
+func (c *Cache) sourceHash(ctx context.Context, db dbtx, paperID string) (string, error) {
+	h := sha256.New()
+	found := false
+
+	fsys, err := c.sourceFS(ctx, db, paperID)
+	switch {
+	case err == nil:
+		found = true
+		err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "%s %d\n", path, info.Size())
+			switch strings.ToLower(filepath.Ext(path)) {
+			case ".tex", ".bbl", ".bib":
+				data, err := fs.ReadFile(fsys, path)
+				if err != nil {
+					return err
+				}
+				h.Write(data)
+			}
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return "", err
+	}
+
+	info, err := c.blobs.Stat(ctx, pdfKey(paperID))
+	switch {
+	case err == nil:
+		found = true
+		fmt.Fprintf(h, "pdf %d\n", info.Size)
+	case !errors.Is(err, fs.ErrNotExist):
+		return "", err
+	}
+
+	if !found {
+		return "", fs.ErrNotExist
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,172 @@
+package arxiv
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRebuildCitations(t *testing.T) {
+	ctx := context.Background()
+	c, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	local := c.blobs.(*FileStore)
+
+	writeSource := func(t *testing.T, id, bbl string) {
+		t.Helper()
+		dir := local.Path(sourceKey(id))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "main.bbl"), []byte(bbl), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := c.db.Exec("UPDATE papers SET src_downloaded = 1, src_path = ? WHERE id = ?", dir, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// referenceTo returns what the reference at position 1 of id resolved to.
+	referenceTo := func(t *testing.T, id string) string {
+		t.Helper()
+		var arxivID string
+		err := c.db.QueryRow("SELECT arxiv_id FROM paper_references WHERE paper_id = ? AND position = 1", id).Scan(&arxivID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return arxivID
+	}
+
+	err = c.insertPapers(ctx, []Paper{
+		{ID: "2301.00001", Title: "First"},
+		{ID: "2301.00002", Title: "Second"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeSource(t, "2301.00001", `\bibitem{a} A. Vaswani. \newblock Attention. \newblock arXiv:1706.03762, 2017.
+\bibitem{b} T. M\"uller. \newblock Kernel methods in particle physics. \newblock Phys. Rev., 2020.`)
+	writeSource(t, "2301.00002", `\bibitem{a} K. He. \newblock Residual learning. \newblock arXiv:1512.03385, 2015.`)
+
+	tests := []struct {
+		name  string
+		setup func(t *testing.T)
+		opts  *RebuildOptions
+		want  RebuildStats
+		check func(t *testing.T)
+	}{
+		{
+			name: "first rebuild",
+			want: RebuildStats{Indexed: 2},
+			check: func(t *testing.T) {
+				if id := referenceTo(t, "2301.00001"); id != "" {
+					t.Errorf("reference resolved to %q before the paper was cached", id)
+				}
+			},
+		},
+		{
+			name: "unchanged",
+			want: RebuildStats{Unchanged: 2},
+		},
+		{
+			name: "source changed",
+			setup: func(t *testing.T) {
+				writeSource(t, "2301.00002", `\bibitem{a} K. He. \newblock Residual learning. \newblock arXiv:1603.05027, 2016.`)
+			},
+			want: RebuildStats{Indexed: 1, Unchanged: 1},
+			check: func(t *testing.T) {
+				refs, err := c.References(ctx, "2301.00002")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(refs) != 1 || refs[0].ID != "1603.05027" {
+					t.Errorf("references = %+v, want 1603.05027", refs)
+				}
+			},
+		},
+		{
+			name: "extractor version bumped",
+			setup: func(t *testing.T) {
+				_, err := c.db.Exec("UPDATE paper_index SET extractor = ? WHERE paper_id = '2301.00001'", ExtractorVersion-1)
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: RebuildStats{Indexed: 1, Unchanged: 1},
+		},
+		{
+			name: "force",
+			opts: &RebuildOptions{Force: true},
+			want: RebuildStats{Indexed: 2},
+		},
+		{
+			name: "source missing",
+			setup: func(t *testing.T) {
+				if err := os.RemoveAll(local.Path(sourceKey("2301.00002"))); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: RebuildStats{Unchanged: 1, Missing: 1},
+		},
+		{
+			name: "no longer downloaded",
+			setup: func(t *testing.T) {
+				if _, err := c.db.Exec("UPDATE papers SET src_downloaded = 0 WHERE id = '2301.00002'"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: RebuildStats{Unchanged: 1},
+			check: func(t *testing.T) {
+				var n int
+				c.db.QueryRow("SELECT COUNT(*) FROM paper_references WHERE paper_id = '2301.00002'").Scan(&n)
+				if n != 0 {
+					t.Errorf("%d references left", n)
+				}
+			},
+		},
+		{
+			// An unchanged paper's reference resolves to a paper added
+			// since the last rebuild
+			name: "cited paper added",
+			setup: func(t *testing.T) {
+				err := c.insertPapers(ctx, []Paper{{
+					ID:      "2001.00001",
+					Title:   "Kernel Methods in Particle Physics",
+					Authors: "Thomas Müller",
+					Created: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+				}})
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: RebuildStats{Unchanged: 1},
+			check: func(t *testing.T) {
+				if id := referenceTo(t, "2301.00001"); id != "2001.00001" {
+					t.Errorf("reference resolved to %q, want 2001.00001", id)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.setup != nil {
+				tt.setup(t)
+			}
+			stats, err := c.RebuildCitations(ctx, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *stats != tt.want {
+				t.Errorf("stats = %+v, want %+v", *stats, tt.want)
+			}
+			if tt.check != nil {
+				tt.check(t)
+			}
+		})
+	}
+}
@@ -0,0 +1,578 @@
+package arxiv
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// BibReference is one entry of a paper's bibliography, parsed from the
+// .bbl or .bib files of its source.
+type BibReference struct {
+	// Position is the entry's index in the bibliography
+	Position int
+
+	// Key is the citation key, or "" for an arXiv ID found outside the
+	// bibliography
+	Key string
+
+	Authors string
+	Title   string
+	Venue   string
+	Year    int
+	DOI     string
+
+	// ArxivID is the cited arXiv paper, or "" if none is known
+	ArxivID string
+
+	// Raw is the text of a .bbl entry with TeX markup removed, or the
+	// .bib entry as written
+	Raw string
+
+	// Confidence is how certain the ArxivID is: 1 if the reference gives
+	// it, less if ResolveReferences matched it
+	Confidence float64
+
+	// ResolvedBy is how ResolveReferences matched ArxivID: "doi" or
+	// "title", or "" if the reference gives it
+	ResolvedBy string
+
+	// Mentions is the number of times the paper's text cites the entry
+	Mentions int
+
+	// Weight is the sum of the entry's mentions, each scaled by the
+	// section it is in, or 1 if the text is not known to cite it
+	Weight float64
+}
+
+// ExtractBibliography parses the bibliography of the source in srcPath.
+func ExtractBibliography(ctx context.Context, srcPath string) []BibReference {
+	if srcPath == "" {
+		return nil
+	}
+	return ExtractBibliographyFS(ctx, os.DirFS(srcPath))
+}
+
+// ExtractBibliographyFS is like ExtractBibliography but reads the source
+// tree from fsys.
+//
+// The .bbl files are preferred, as they hold exactly the cited entries in
+// order; the .bib files are used if there are none. arXiv IDs found by
+// ExtractReferencesFS that no entry accounts for are appended as entries
+// of their own, so every citation edge has a reference.
+func ExtractBibliographyFS(ctx context.Context, fsys fs.FS) []BibReference {
+	return extractBibliography(ctx, fsys, PDFTextExtractor{})
+}
+
+// extractBibliography is ExtractBibliographyFS, reading PDFs with te.
+func extractBibliography(ctx context.Context, fsys fs.FS, te TextExtractor) []BibReference {
+	var bbl, bib []BibReference
+	fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext != ".bbl" && ext != ".bib" {
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return nil
+		}
+		if ext == ".bbl" {
+			bbl = append(bbl, parseBibliography(path, string(data))...)
+		} else {
+			bib = append(bib, parseBibliography(path, string(data))...)
+		}
+		return nil
+	})
+
+	refs := bbl
+	if len(refs) == 0 {
+		refs, bib = bib, nil
+	}
+	seen := make(map[string]bool)
+	for _, r := range refs {
+		seen[r.ArxivID] = true
+	}
+	// Entries of unused .bib files that cite one of the extra IDs
+	byID := make(map[string]BibReference)
+	for _, r := range bib {
+		if _, ok := byID[r.ArxivID]; !ok && r.ArxivID != "" {
+			byID[r.ArxivID] = r
+		}
+	}
+	for _, id := range extractReferences(ctx, fsys, te) {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		r, ok := byID[id]
+		if !ok {
+			r = BibReference{ArxivID: id}
+		}
+		refs = append(refs, r)
+	}
+
+	for i := range refs {
+		refs[i].Position = i
+	}
+	return refs
+}
+
+var (
+	bibEntryStart = regexp.MustCompile(`(?m)^\s*@(\w+)\s*[{(]\s*([^,\s]*)\s*,`)
+	bibItemStart  = regexp.MustCompile(`\\bibitem\s*(?:\[((?:[^\[\]]|\[[^\]]*\])*)\])?\s*\{([^}]*)\}`)
+	bibDOI        = regexp.MustCompile(`(?i)(?:\bdoi\s*=\s*[{"]?\s*|\\doi\s*\{|doi\.org/|\bdoi:\s*)(10\.\d{4,9}/[^\s{}",]+)`)
+	arxivDOI      = regexp.MustCompile(`(?i)^10\.48550/arxiv\.(.+)$`)
+	bibYear       = regexp.MustCompile(`\b((?:19|20)\d\d)[a-z]?\b`)
+	bibQuoted     = regexp.MustCompile("``(.+?)''")
+	bibNoise      = regexp.MustCompile(`\d{4}\.\d{4,5}(?:v\d+)?|10\.\d{4,9}/\S+|https?://\S+`)
+	bblEnd        = regexp.MustCompile(`\\end\s*\{thebibliography\}`)
+)
+
+// parseBibliography parses a .bbl file's \bibitem entries or a .bib file's
+// @type{key, ...} entries, according to the file's extension.
+func parseBibliography(file, text string) []BibReference {
+	// Each entry runs from the end of its header to the start of the next
+	type span struct {
+		key, label       string
+		head, start, end int
+	}
+	bbl := strings.EqualFold(filepath.Ext(file), ".bbl")
+	var spans []span
+	if bbl {
+		if loc := bblEnd.FindStringIndex(text); loc != nil {
+			text = text[:loc[0]]
+		}
+		for _, m := range bibItemStart.FindAllStringSubmatchIndex(text, -1) {
+			s := span{key: text[m[4]:m[5]], head: m[0], start: m[1]}
+			if m[2] >= 0 {
+				s.label = text[m[2]:m[3]]
+			}
+			spans = append(spans, s)
+		}
+	} else {
+		for _, m := range bibEntryStart.FindAllStringSubmatchIndex(text, -1) {
+			switch strings.ToLower(text[m[2]:m[3]]) {
+			case "comment", "string", "preamble":
+				continue
+			}
+			spans = append(spans, span{key: text[m[4]:m[5]], head: m[0], start: m[1]})
+		}
+	}
+	for i := range spans {
+		spans[i].end = len(text)
+		if i+1 < len(spans) {
+			spans[i].end = spans[i+1].head
+		}
+	}
+
+	refs := make([]BibReference, 0, len(spans))
+	for _, s := range spans {
+		body := text[s.start:s.end]
+		var r BibReference
+		if bbl {
+			r = parseBibItem(body, s.label)
+		} else {
+			r = parseBibFields(body)
+			r.Raw = normalizeSpace(text[s.head:s.end])
+		}
+		r.Key = strings.TrimSpace(s.key)
+
+		if m := bibDOI.FindStringSubmatch(body); m != nil && r.DOI == "" {
+			r.DOI = strings.TrimRight(m[1], ".")
+		}
+		if m := arxivDOI.FindStringSubmatch(r.DOI); m != nil && validID(m[1]) {
+			r.ArxivID = normalizeArxivID(m[1])
+		}
+		if r.ArxivID == "" {
+			for _, pat := range arxivIDPatterns {
+				if m := pat.FindStringSubmatch(body); m != nil {
+					r.ArxivID = normalizeArxivID(m[1])
+					break
+				}
+			}
+		}
+		refs = append(refs, r)
+	}
+	return refs
+}
+
+// parseBibItem parses the text of a \bibitem. Styles built with
+// \newblock (plain, natbib, ACL, ...) give authors, title and venue as
+// separate blocks; otherwise a title in TeX quotes is looked for.
+func parseBibItem(body, label string) BibReference {
+	r := BibReference{Raw: detex(body)}
+	if blocks := strings.Split(body, `\newblock`); len(blocks) > 1 {
+		r.Authors = bibAuthorList(detex(blocks[0]))
+		r.Title = trimBibField(detex(blocks[1]))
+		if len(blocks) > 2 {
+			r.Venue = trimBibField(detex(strings.Join(blocks[2:], " ")))
+		}
+	} else if m := bibQuoted.FindStringSubmatchIndex(body); m != nil {
+		r.Authors = bibAuthorList(detex(body[:m[0]]))
+		r.Title = trimBibField(detex(body[m[2]:m[3]]))
+		r.Venue = trimBibField(detex(body[m[1]:]))
+	}
+
+	// natbib labels carry the year: Vaswani et~al.(2017)
+	r.Year = lastYear(label)
+	if r.Year == 0 {
+		r.Year = lastYear(r.Raw)
+	}
+	return r
+}
+
+// lastYear returns the last plausible publication year in s, ignoring
+// digits that are part of arXiv IDs, DOIs and URLs.
+func lastYear(s string) int {
+	m := bibYear.FindAllStringSubmatch(bibNoise.ReplaceAllString(s, " "), -1)
+	if len(m) == 0 {
+		return 0
+	}
+	year, _ := strconv.Atoi(m[len(m)-1][1])
+	return year
+}
+
+// bibAuthorList normalizes a bibliography's author list to the
+// comma-separated form of Paper.Authors.
+func bibAuthorList(s string) string {
+	s = strings.TrimRight(strings.TrimSpace(s), ".,")
+	s = strings.ReplaceAll(s, ", and ", ", ")
+	s = strings.ReplaceAll(s, " and ", ", ")
+	return s
+}
+
+// trimBibField strips the punctuation that bibliography styles put after
+// each block.
+func trimBibField(s string) string {
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(s), ".,;"))
+}
+
+// parseBibFields parses the fields of a .bib entry, body starting after
+// its key.
+func parseBibFields(body string) BibReference {
+	var r BibReference
+	fields := bibFields(body)
+	r.Title = detex(fields["title"])
+	if authors := fields["author"]; authors != "" {
+		var names []string
+		for name := range strings.SplitSeq(authors, " and ") {
+			// "Family, Given" -> "Given Family"
+			if family, given, ok := strings.Cut(name, ","); ok {
+				name = given + " " + family
+			}
+			if name = detex(name); name != "" {
+				names = append(names, name)
+			}
+		}
+		r.Authors = strings.Join(names, ", ")
+	}
+	for _, f := range []string{"journal", "journaltitle", "booktitle", "howpublished", "publisher", "school", "institution"} {
+		if v := detex(fields[f]); v != "" {
+			r.Venue = v
+			break
+		}
+	}
+	r.Year = lastYear(fields["year"])
+	if r.Year == 0 {
+		r.Year = lastYear(fields["date"])
+	}
+	r.DOI = strings.TrimSpace(fields["doi"])
+	r.DOI = strings.TrimPrefix(strings.TrimPrefix(r.DOI, "https://doi.org/"), "http://dx.doi.org/")
+
+	eprint := strings.TrimSpace(fields["eprint"])
+	prefix := strings.ToLower(fields["archiveprefix"] + fields["eprinttype"])
+	if id := normalizeArxivID(strings.TrimPrefix(eprint, "arXiv:")); validID(id) && (prefix == "" || strings.Contains(prefix, "arxiv")) {
+		r.ArxivID = id
+	}
+	return r
+}
+
+// bibFields returns the fields of a .bib entry by lower-cased name. Values
+// may be {braced}, "quoted", bare numbers or macros, and concatenated
+// with #. Braces inside values are kept.
+func bibFields(body string) map[string]string {
+	fields := make(map[string]string)
+	i := 0
+	for i < len(body) {
+		// Field name
+		for i < len(body) && strings.IndexByte(" \t\r\n,", body[i]) >= 0 {
+			i++
+		}
+		if i >= len(body) || body[i] == '}' || body[i] == ')' {
+			break
+		}
+		start := i
+		for i < len(body) && body[i] != '=' && body[i] != ',' && body[i] != '}' {
+			i++
+		}
+		if i >= len(body) || body[i] != '=' {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(body[start:i]))
+		i++
+
+		// Value parts joined by #
+		var value strings.Builder
+		for {
+			for i < len(body) && strings.IndexByte(" \t\r\n", body[i]) >= 0 {
+				i++
+			}
+			if i >= len(body) {
+				break
+			}
+			switch body[i] {
+			case '{':
+				end := matchBrace(body, i)
+				value.WriteString(body[i+1 : end])
+				i = end + 1
+			case '"':
+				end := i + 1
+				for depth := 0; end < len(body); end++ {
+					if body[end] == '{' {
+						depth++
+					} else if body[end] == '}' {
+						depth--
+					} else if body[end] == '"' && depth <= 0 && body[end-1] != '\\' {
+						break
+					}
+				}
+				value.WriteString(body[i+1 : min(end, len(body))])
+				i = end + 1
+			default:
+				start := i
+				for i < len(body) && strings.IndexByte(",})#\n", body[i]) < 0 {
+					i++
+				}
+				value.WriteString(strings.TrimSpace(body[start:i]))
+			}
+			for i < len(body) && strings.IndexByte(" \t\r\n", body[i]) >= 0 {
+				i++
+			}
+			if i < len(body) && body[i] == '#' {
+				i++
+				continue
+			}
+			break
+		}
+		fields[name] = value.String()
+	}
+	return fields
+}
+
+// matchBrace returns the index of the brace closing the one at s[i], or
+// len(s) if it is unbalanced.
+func matchBrace(s string, i int) int {
+	depth := 0
+	for j := i; j < len(s); j++ {
+		switch s[j] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return j
+			}
+		case '\\':
+			j++ // skip escaped braces
+		}
+	}
+	return len(s)
+}
+
+var (
+	texComment = regexp.MustCompile(`(?m)(^|[^\\])%.*$\n?`)
+	texAccent  = regexp.MustCompile(`\\[\"'^` + "`" + `~=.]`)
+	texCommand = regexp.MustCompile(`\\[a-zA-Z]+\*?(?:\[[^\]]*\])?\s*`)
+	texEscape  = strings.NewReplacer(`\&`, "&", `\%`, "%", `\_`, "_", `\$`, "$", `\#`, "#", `\{`, "", `\}`, "", `\\`, " ", `\ `, " ", `\,`, " ", `\@`, "")
+	texSpecial = strings.NewReplacer("~", " ", "{", "", "}", "", "$", "", "--", "-", "``", `"`, "''", `"`)
+)
+
+// detex reduces TeX markup to plain text: comments, accents and command
+// names are dropped and their arguments kept, so \emph{Attention} becomes
+// "Attention" and {\"o} becomes "o".
+func detex(s string) string {
+	s = texComment.ReplaceAllString(s, "$1")
+	s = texEscape.Replace(s)
+	s = texAccent.ReplaceAllString(s, "")
+	s = texCommand.ReplaceAllString(s, "")
+	s = texSpecial.Replace(s)
+	return normalizeSpace(s)
+}
+
+// storeReferences replaces a paper's bibliography and citation contexts
+// and resolves the references without an arXiv ID. An empty bibliography
+// leaves the stored one alone, as extraction may simply have failed.
+func (c *Cache) storeReferences(ctx context.Context, paperID string, refs []BibReference, contexts []CitationContext) error {
+	if len(refs) == 0 {
+		return nil
+	}
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := writeReferences(ctx, tx, paperID, refs, contexts); err != nil {
+		return err
+	}
+	if _, err := c.resolveReferences(ctx, tx, paperID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertBatch is the number of rows written by each INSERT statement.
+const insertBatch = 200
+
+// writeReferences replaces a paper's bibliography and citation contexts
+// within tx, weighting each reference by its mentions.
+func writeReferences(ctx context.Context, tx *sql.Tx, paperID string, refs []BibReference, contexts []CitationContext) error {
+	for _, table := range []string{"paper_references", "citation_contexts"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE paper_id = ?", paperID); err != nil {
+			return err
+		}
+	}
+
+	mentions := make(map[string]int)
+	for _, cc := range contexts {
+		mentions[cc.Key]++
+	}
+	weights := referenceWeights(contexts)
+	rows := make([][]any, len(refs))
+	for i, r := range refs {
+		n, weight := 0, 1.0
+		if r.Key != "" && mentions[r.Key] > 0 {
+			n, weight = mentions[r.Key], weights[r.Key]
+		}
+		rows[i] = []any{paperID, r.Position, r.Key, r.Authors, r.Title,
+			r.Venue, r.Year, r.DOI, r.ArxivID, r.Raw, n, weight}
+	}
+	err := insertRows(ctx, tx, `INSERT INTO paper_references
+		(paper_id, position, cite_key, authors, title, venue, year, doi, arxiv_id, raw, mentions, weight)`, rows)
+	if err != nil {
+		return err
+	}
+
+	rows = make([][]any, len(contexts))
+	for i, cc := range contexts {
+		rows[i] = []any{paperID, i, cc.Key, cc.Section, cc.Sentence}
+	}
+	return insertRows(ctx, tx, `INSERT OR IGNORE INTO citation_contexts
+		(paper_id, seq, cite_key, section, sentence)`, rows)
+}
+
+// insertRows runs insert, an INSERT statement without its VALUES clause,
+// for rows, insertBatch rows at a time.
+func insertRows(ctx context.Context, tx *sql.Tx, insert string, rows [][]any) error {
+	for len(rows) > 0 {
+		batch := rows[:min(len(rows), insertBatch)]
+		rows = rows[len(batch):]
+
+		values := "(?" + strings.Repeat(", ?", len(batch[0])-1) + ")"
+		var query strings.Builder
+		query.WriteString(insert)
+		query.WriteString(" VALUES ")
+		args := make([]any, 0, len(batch)*len(batch[0]))
+		for i, row := range batch {
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			query.WriteString(values)
+			args = append(args, row...)
+		}
+		if _, err := tx.ExecContext(ctx, query.String(), args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Bibliography returns every reference of a paper in bibliography order,
+// including those that do not cite an arXiv paper.
+func (c *Cache) Bibliography(ctx context.Context, paperID string) ([]BibReference, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT position, cite_key, authors, title, venue, year, doi, arxiv_id, raw,
+		       confidence, resolved_by, mentions, weight
+		FROM paper_references
+		WHERE paper_id = ?
+		ORDER BY position
+	`, paperID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refs []BibReference
+	for rows.Next() {
+		var r BibReference
+		err := rows.Scan(&r.Position, &r.Key, &r.Authors, &r.Title, &r.Venue, &r.Year, &r.DOI, &r.ArxivID, &r.Raw,
+			&r.Confidence, &r.ResolvedBy, &r.Mentions, &r.Weight)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, r)
+	}
+	return refs, rows.Err()
+}
+
+// citationsView defines the citations view: the bibliography entries that
+// cite another arXiv paper, with the confidence of the best of them, the
+// number of times the text cites them, their weight and whether that makes
+// the citation influential (weight of at least InfluentialWeight).
+var citationsView = fmt.Sprintf(`CREATE VIEW citations AS
+	SELECT paper_id AS from_id, arxiv_id AS to_id, MAX(confidence) AS confidence,
+	       SUM(mentions) AS mentions, SUM(weight) AS weight, SUM(weight) >= %v AS influential
+	FROM paper_references
+	WHERE arxiv_id != '' AND arxiv_id != paper_id
+	GROUP BY paper_id, arxiv_id`, InfluentialWeight)
+
+// migrateCitations replaces the citations table of older caches, which
+// held only arXiv edges, with the view over paper_references. Existing
+// edges become references with nothing but an arXiv ID until their papers
+// are re-indexed. The view is also recreated whenever its definition
+// changes.
+func (c *Cache) migrateCitations() error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var kind, def string
+	err = tx.QueryRow("SELECT type, COALESCE(sql, '') FROM sqlite_master WHERE name = 'citations'").Scan(&kind, &def)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	switch {
+	case kind == "view" && def == citationsView:
+		return nil
+	case kind == "view":
+		if _, err := tx.Exec("DROP VIEW citations"); err != nil {
+			return err
+		}
+	case kind == "table":
+		_, err := tx.Exec(`
+			INSERT OR IGNORE INTO paper_references (paper_id, position, arxiv_id)
+			SELECT from_id, ROW_NUMBER() OVER (PARTITION BY from_id ORDER BY rowid) - 1, to_id
+			FROM citations
+		`)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DROP TABLE citations"); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(citationsView); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,186 @@
+package arxiv
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestBibFields(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want map[string]string
+	}{
+		{
+			name: "braced",
+			body: `, title = {Attention Is All You Need}, year = 2017}`,
+			want: map[string]string{"title": "Attention Is All You Need", "year": "2017"},
+		},
+		{
+			name: "nested braces",
+			body: `, title={{BERT}: Pre-training of {Deep {Bidirectional}} Transformers}}`,
+			want: map[string]string{"title": "{BERT}: Pre-training of {Deep {Bidirectional}} Transformers"},
+		},
+		{
+			name: "escaped brace",
+			body: `, note = {a \} b}}`,
+			want: map[string]string{"note": `a \} b`},
+		},
+		{
+			name: "quoted",
+			body: `, Title = "Deep Residual Learning", Journal = "CVPR"}`,
+			want: map[string]string{"title": "Deep Residual Learning", "journal": "CVPR"},
+		},
+		{
+			name: "quoted with braced quote",
+			body: `, title = "The {"}Quote{"} Paper"}`,
+			want: map[string]string{"title": `The {"}Quote{"} Paper`},
+		},
+		{
+			name: "concatenation",
+			body: `, journal = nips # " 30", note = {Part } # {one}}`,
+			want: map[string]string{"journal": "nips 30", "note": "Part one"},
+		},
+		{
+			name: "newlines",
+			body: ",\n  author = {Doe, Jane and Roe, Richard},\n  year = {2020},\n}",
+			want: map[string]string{"author": "Doe, Jane and Roe, Richard", "year": "2020"},
+		},
+		{
+			name: "parentheses",
+			body: `, year = 1999)`,
+			want: map[string]string{"year": "1999"},
+		},
+		{
+			name: "empty",
+			body: `}`,
+			want: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := bibFields(tt.body)
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("bibFields(%q) = %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetex(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`\emph{Attention} is all you need`, "Attention is all you need"},
+		{`G{\"o}del, Escher, Bach`, "Godel, Escher, Bach"},
+		{`Smith~et~al.`, "Smith et al."},
+		{`pages 1--10 % comment`, "pages 1-10"},
+		{`50\% of \textbf{R\&D}`, "50% of R&D"},
+		{"``Quoted''", `"Quoted"`},
+		{`$O(n)$ time`, "O(n) time"},
+	}
+	for _, tt := range tests {
+		if got := detex(tt.in); got != tt.want {
+			t.Errorf("detex(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseBibliography(t *testing.T) {
+	tests := []struct {
+		name, file, text string
+		want             []BibReference
+	}{
+		{
+			name: "bibitem with label",
+			file: "main.bbl",
+			text: `\begin{thebibliography}{2}
+\bibitem[Vaswani et~al.(2017)]{vaswani2017}
+Ashish Vaswani, Noam Shazeer, and Niki Parmar.
+\newblock Attention is all you need.
+\newblock In \emph{NeurIPS}, 2017.
+
+\bibitem{he2016}
+K.~He and X.~Zhang.
+\newblock Deep residual learning.
+\newblock arXiv:1512.03385, 2016.
+\end{thebibliography}
+trailing text`,
+			want: []BibReference{
+				{
+					Key:     "vaswani2017",
+					Authors: "Ashish Vaswani, Noam Shazeer, Niki Parmar",
+					Title:   "Attention is all you need",
+					Venue:   "In NeurIPS, 2017",
+					Year:    2017,
+				},
+				{
+					Key:     "he2016",
+					Authors: "K. He, X. Zhang",
+					Title:   "Deep residual learning",
+					Venue:   "arXiv:1512.03385, 2016",
+					Year:    2016,
+					ArxivID: "1512.03385",
+				},
+			},
+		},
+		{
+			name: "bibitem with quoted title",
+			file: "x.bbl",
+			text: "\\bibitem{k} A. Author, ``A quoted title,'' Journal, 2019.",
+			want: []BibReference{
+				{Key: "k", Authors: "A. Author", Title: "A quoted title", Venue: "Journal, 2019", Year: 2019},
+			},
+		},
+		{
+			name: "bib entries",
+			file: "refs.bib",
+			text: `@string{nips = "NeurIPS"}
+@inproceedings{vaswani2017,
+  title = {Attention Is {All} You Need},
+  author = {Vaswani, Ashish and Shazeer, Noam},
+  booktitle = nips # " 30",
+  year = 2017,
+}
+@article{devlin2018,
+  title = "{BERT}: Pre-training",
+  journal = {arXiv preprint},
+  eprint = {1810.04805},
+  archivePrefix = {arXiv},
+  doi = {https://doi.org/10.18653/v1/N19-1423},
+  year = {2018}
+}`,
+			want: []BibReference{
+				{
+					Key:     "vaswani2017",
+					Authors: "Ashish Vaswani, Noam Shazeer",
+					Title:   "Attention Is All You Need",
+					Venue:   "nips 30",
+					Year:    2017,
+				},
+				{
+					Key:     "devlin2018",
+					Title:   "BERT: Pre-training",
+					Venue:   "arXiv preprint",
+					Year:    2018,
+					DOI:     "10.18653/v1/N19-1423",
+					ArxivID: "1810.04805",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseBibliography(tt.file, tt.text)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d entries, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, r := range got {
+				r.Raw = ""
+				if r != tt.want[i] {
+					t.Errorf("entry %d:\ngot  %+v\nwant %+v", i, r, tt.want[i])
+				}
+			}
+		})
+	}
+}
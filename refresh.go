@@ -0,0 +1,153 @@
+package arxiv
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetadataChange records a metadata field that changed when a paper was
+// re-fetched, e.g. a new title in v2 or a journal reference added after
+// publication.
+type MetadataChange struct {
+	Field    string
+	OldValue string
+	NewValue string
+	Changed  time.Time
+}
+
+// isStale reports whether the paper's metadata is older than the cache's
+// MaxAge.
+func (c *Cache) isStale(p *Paper) bool {
+	return c.maxAge > 0 && time.Since(p.MetadataUpdated) > c.maxAge
+}
+
+// StalePapers returns the IDs of papers whose metadata was fetched more than
+// olderThan ago, optionally restricted to a category or archive. A limit
+// of 0 returns all of them.
+func (c *Cache) StalePapers(ctx context.Context, olderThan time.Duration, category string, limit int) ([]string, error) {
+	cutoff := time.Now().Add(-olderThan).UTC().Format(time.RFC3339)
+	query := `
+		SELECT id FROM papers
+		WHERE (metadata_updated IS NULL OR julianday(metadata_updated) < julianday(?))
+	`
+	args := []any{cutoff}
+	if category != "" {
+		cond, catArgs := categoryMatch("categories", []string{category})
+		query += " AND " + cond
+		args = append(args, catArgs...)
+	}
+	query += " ORDER BY metadata_updated"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Refresh re-fetches the metadata of the given papers from the arXiv API,
+// regardless of age, in batches of up to 100 IDs. Changes to tracked fields
+// are recorded and can be read back with MetadataHistory. As with
+// FetchBatch, the error is non-nil only if ctx is done.
+func (c *Cache) Refresh(ctx context.Context, ids []string) (*BatchResult, error) {
+	res := &BatchResult{}
+	var valid []string
+	for _, id := range ids {
+		if !validID(id) {
+			res.Missing = append(res.Missing, MissingID{ID: id, Reason: ReasonMalformed})
+			continue
+		}
+		valid = append(valid, id)
+	}
+	err := c.fetchAll(ctx, valid, res)
+	return res, err
+}
+
+// MetadataHistory returns the recorded metadata changes for a paper,
+// oldest first.
+func (c *Cache) MetadataHistory(ctx context.Context, id string) ([]MetadataChange, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT field, old_value, new_value, changed
+		FROM metadata_history WHERE paper_id = ?
+		ORDER BY rowid
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []MetadataChange
+	for rows.Next() {
+		var ch MetadataChange
+		var changed string
+		if err := rows.Scan(&ch.Field, &ch.OldValue, &ch.NewValue, &changed); err != nil {
+			return nil, err
+		}
+		ch.Changed, _ = time.Parse(time.RFC3339, changed)
+		changes = append(changes, ch)
+	}
+	return changes, rows.Err()
+}
+
+// recordChanges compares p with the stored row, if any, and appends the
+// fields that differ to metadata_history. Whitespace differences are
+// ignored, since the API and OAI wrap text differently. It reports whether
+// the paper was already stored.
+func recordChanges(ctx context.Context, tx *sql.Tx, p *Paper, now string) (bool, error) {
+	var title, abstract, journalRef, doi sql.NullString
+	var version sql.NullInt64
+	err := tx.QueryRowContext(ctx, `
+		SELECT title, abstract, journal_ref, doi, version FROM papers WHERE id = ?
+	`, p.ID).Scan(&title, &abstract, &journalRef, &doi, &version)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	changes := [][3]string{
+		{"title", title.String, p.Title},
+		{"abstract", abstract.String, p.Abstract},
+		{"journal_ref", journalRef.String, p.JournalRef},
+		{"doi", doi.String, p.DOI},
+	}
+	if p.Version > 0 && version.Valid && version.Int64 > 0 && int64(p.Version) != version.Int64 {
+		changes = append(changes, [3]string{"version", strconv.FormatInt(version.Int64, 10), strconv.Itoa(p.Version)})
+	}
+
+	for _, ch := range changes {
+		if normalizeSpace(ch[1]) == normalizeSpace(ch[2]) {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO metadata_history (paper_id, field, old_value, new_value, changed)
+			VALUES (?, ?, ?, ?, ?)
+		`, p.ID, ch[0], ch[1], ch[2], now)
+		if err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,156 @@
+package arxiv
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRefreshHistory(t *testing.T) {
+	ctx := context.Background()
+	api, c := newFakeAPI(t)
+	const id = "2301.00001"
+	api.add(id, 1, "Diffusion Models", "")
+
+	tests := []struct {
+		name   string
+		update func(e *atomEntry)
+		want   [][3]string // New changes: field, old value, new value
+	}{
+		{
+			name: "first fetch",
+		},
+		{
+			name: "unchanged",
+		},
+		{
+			name:   "whitespace only",
+			update: func(e *atomEntry) { e.Title = "Diffusion\n  Models" },
+		},
+		{
+			name: "new version",
+			update: func(e *atomEntry) {
+				e.ID = "http://arxiv.org/abs/" + id + "v2"
+				e.Title = "Diffusion Models Beat GANs"
+			},
+			want: [][3]string{
+				{"title", "Diffusion\n  Models", "Diffusion Models Beat GANs"},
+				{"version", "1", "2"},
+			},
+		},
+		{
+			name: "published",
+			update: func(e *atomEntry) {
+				e.JournalRef = "NeurIPS 2021"
+				e.DOI = "10.5555/1234"
+			},
+			want: [][3]string{
+				{"journal_ref", "", "NeurIPS 2021"},
+				{"doi", "", "10.5555/1234"},
+			},
+		},
+		{
+			name:   "abstract revised",
+			update: func(e *atomEntry) { e.Summary = "A shorter abstract." },
+			want:   [][3]string{{"abstract", "Abstract of Diffusion Models", "A shorter abstract."}},
+		},
+	}
+	seen := 0
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.update != nil {
+				api.mu.Lock()
+				e := api.entries[id]
+				tt.update(&e)
+				api.entries[id] = e
+				api.mu.Unlock()
+			}
+			before := time.Now().Truncate(time.Second)
+			res, err := c.Refresh(ctx, []string{id})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Found) != 1 || res.Err() != nil {
+				t.Fatalf("Refresh = %+v", res)
+			}
+
+			history, err := c.MetadataHistory(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got [][3]string
+			for _, ch := range history[seen:] {
+				got = append(got, [3]string{ch.Field, ch.OldValue, ch.NewValue})
+				if ch.Changed.Before(before) {
+					t.Errorf("%s changed at %v, before the refresh", ch.Field, ch.Changed)
+				}
+			}
+			seen = len(history)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("new changes = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	p, err := c.GetPaper(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Version != 2 || p.Title != "Diffusion Models Beat GANs" || p.JournalRef != "NeurIPS 2021" {
+		t.Errorf("paper = %+v", p)
+	}
+}
+
+func TestStalePapers(t *testing.T) {
+	ctx := context.Background()
+	c, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ages := map[string]time.Duration{
+		"2301.00001":     time.Hour,
+		"2301.00002":     10 * 24 * time.Hour,
+		"2301.00003":     40 * 24 * time.Hour,
+		"hep-th/9901001": 400 * 24 * time.Hour,
+	}
+	for id, age := range ages {
+		if err := c.insertPapers(ctx, []Paper{{ID: id, Categories: "cs.LG"}}); err != nil {
+			t.Fatal(err)
+		}
+		_, err := c.db.Exec("UPDATE papers SET metadata_updated = ? WHERE id = ?", time.Now().Add(-age).UTC().Format(time.RFC3339), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.db.Exec("UPDATE papers SET categories = 'hep-th' WHERE id = 'hep-th/9901001'"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		olderThan time.Duration
+		category  string
+		limit     int
+		want      []string
+	}{
+		{"week", 7 * 24 * time.Hour, "", 0, []string{"hep-th/9901001", "2301.00003", "2301.00002"}},
+		{"month", 30 * 24 * time.Hour, "", 0, []string{"hep-th/9901001", "2301.00003"}},
+		{"limit", 0, "", 2, []string{"hep-th/9901001", "2301.00003"}},
+		{"category", 7 * 24 * time.Hour, "cs", 0, []string{"2301.00003", "2301.00002"}},
+		{"none", 1000 * 24 * time.Hour, "", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.StalePapers(ctx, tt.olderThan, tt.category, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("StalePapers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
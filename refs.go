@@ -2,9 +2,9 @@ package arxiv
 
 import (
 	"bufio"
-	"bytes"
+	"context"
+	"io/fs"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -39,17 +39,26 @@ var arxivIDPatterns = []*regexp.Regexp{
 }
 
 // ExtractReferences extracts arXiv paper IDs from .bbl, .bib, and .tex files in the source directory.
-// Falls back to PDF extraction using pdftotext if no refs found in text files.
+// Falls back to the text of PDF files if no refs are found in text files.
 func ExtractReferences(srcPath string) []string {
 	if srcPath == "" {
 		return nil
 	}
+	return ExtractReferencesFS(os.DirFS(srcPath))
+}
+
+// ExtractReferencesFS is like ExtractReferences but reads the source tree from fsys.
+func ExtractReferencesFS(fsys fs.FS) []string {
+	return extractReferences(context.Background(), fsys, PDFTextExtractor{})
+}
 
+// extractReferences is ExtractReferencesFS, reading PDFs with te.
+func extractReferences(ctx context.Context, fsys fs.FS, te TextExtractor) []string {
 	seen := make(map[string]bool)
 	var refs []string
 	var pdfFiles []string
 
-	filepath.WalkDir(srcPath, func(path string, d os.DirEntry, err error) error {
+	fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
 		if err != nil || d.IsDir() {
 			return nil
 		}
@@ -63,7 +72,7 @@ func ExtractReferences(srcPath string) []string {
 			return nil
 		}
 
-		ids := extractFromFile(path)
+		ids := extractFromFile(fsys, path)
 		for _, id := range ids {
 			// Normalize: strip version suffix for deduplication
 			normalized := normalizeArxivID(id)
@@ -77,14 +86,14 @@ func ExtractReferences(srcPath string) []string {
 
 	// Fallback: if no refs found in text files, try PDFs
 	if len(refs) == 0 && len(pdfFiles) > 0 {
-		refs = extractFromPDFs(pdfFiles, seen)
+		refs = extractFromPDFs(ctx, fsys, pdfFiles, seen, te)
 	}
 
 	return refs
 }
 
-func extractFromFile(path string) []string {
-	f, err := os.Open(path)
+func extractFromFile(fsys fs.FS, path string) []string {
+	f, err := fsys.Open(path)
 	if err != nil {
 		return nil
 	}
@@ -106,33 +115,42 @@ func extractFromFile(path string) []string {
 	return ids
 }
 
-// extractFromPDFs uses pdftotext to extract text from PDF files and find arXiv IDs.
-func extractFromPDFs(pdfFiles []string, seen map[string]bool) []string {
+// extractFromPDFs extracts the text of PDF files with te and finds arXiv IDs in it.
+func extractFromPDFs(ctx context.Context, fsys fs.FS, pdfFiles []string, seen map[string]bool, te TextExtractor) []string {
 	var refs []string
 
 	for _, pdfPath := range pdfFiles {
-		cmd := exec.Command("pdftotext", pdfPath, "-")
-		var out bytes.Buffer
-		cmd.Stdout = &out
-		if err := cmd.Run(); err != nil {
+		f, err := fsys.Open(pdfPath)
+		if err != nil {
 			continue
 		}
+		text, err := te.PDFText(ctx, f)
+		f.Close()
+		if err != nil {
+			continue
+		}
+		refs = append(refs, arxivIDsInText(text, seen)...)
+	}
 
-		text := out.String()
-		for _, pat := range arxivIDPatterns {
-			matches := pat.FindAllStringSubmatch(text, -1)
-			for _, m := range matches {
-				if len(m) > 1 {
-					normalized := normalizeArxivID(m[1])
-					if !seen[normalized] {
-						seen[normalized] = true
-						refs = append(refs, normalized)
-					}
+	return refs
+}
+
+// arxivIDsInText returns the arXiv IDs in text that are not yet in seen,
+// adding them to it.
+func arxivIDsInText(text string, seen map[string]bool) []string {
+	var refs []string
+	for _, pat := range arxivIDPatterns {
+		matches := pat.FindAllStringSubmatch(text, -1)
+		for _, m := range matches {
+			if len(m) > 1 {
+				normalized := normalizeArxivID(m[1])
+				if !seen[normalized] {
+					seen[normalized] = true
+					refs = append(refs, normalized)
 				}
 			}
 		}
 	}
-
 	return refs
 }
 
@@ -0,0 +1,360 @@
+package arxiv
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Confidence of references resolved to an arXiv paper by ResolveReferences.
+// References that give an arXiv ID themselves have confidence 1.
+const (
+	// ConfidenceDOI is the confidence of a DOI recorded for the paper
+	ConfidenceDOI = 0.95
+
+	// MinTitleConfidence is the lowest confidence at which a title match
+	// is accepted. A title match scores at most 0.9: 0.6 for the title,
+	// 0.2 for the first author and 0.1 for the year.
+	MinTitleConfidence = 0.7
+)
+
+// ResolveReferences links the references of a paper that give no arXiv
+// ID to cached papers, and returns how many it resolved. With paperID ""
+// it considers every unresolved reference in the cache, which is worth
+// doing after new papers have been synced.
+//
+// A reference is first looked up by DOI among the cached papers' journal
+// DOIs. Failing that, its title is searched in the full-text index and
+// candidates are scored by title similarity, first author and year.
+// Resolved references enter the citations view with their confidence.
+func (c *Cache) ResolveReferences(ctx context.Context, paperID string) (int, error) {
+	return c.resolveReferences(ctx, c.db, paperID)
+}
+
+// dbtx is the query interface shared by *sql.DB and *sql.Tx.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// resolveReferences is ResolveReferences, run on db.
+func (c *Cache) resolveReferences(ctx context.Context, db dbtx, paperID string) (int, error) {
+	refs, err := unresolvedReferences(ctx, db, paperID)
+	if err != nil {
+		return 0, err
+	}
+	return resolveAll(ctx, db, refs)
+}
+
+// unresolvedRef is a reference without an arXiv ID.
+type unresolvedRef struct {
+	paperID  string
+	position int
+	BibReference
+}
+
+// unresolvedReferences returns the references of paperID, or of every
+// paper if it is "", that have a DOI or title but no arXiv ID.
+func unresolvedReferences(ctx context.Context, db dbtx, paperID string) ([]unresolvedRef, error) {
+	query := `
+		SELECT paper_id, position, authors, title, year, doi
+		FROM paper_references
+		WHERE arxiv_id = '' AND (doi != '' OR title != '')
+	`
+	var args []any
+	if paperID != "" {
+		query += " AND paper_id = ?"
+		args = append(args, paperID)
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var refs []unresolvedRef
+	for rows.Next() {
+		var r unresolvedRef
+		if err := rows.Scan(&r.paperID, &r.position, &r.Authors, &r.Title, &r.Year, &r.DOI); err != nil {
+			return nil, err
+		}
+		refs = append(refs, r)
+	}
+	return refs, rows.Err()
+}
+
+// resolveAll resolves refs against the cache and records the matches.
+func resolveAll(ctx context.Context, db dbtx, refs []unresolvedRef) (int, error) {
+	resolved := 0
+	for _, r := range refs {
+		id, confidence, by, err := resolveReference(ctx, db, &r.BibReference)
+		if err != nil {
+			return resolved, err
+		}
+		if id == "" || id == r.paperID {
+			continue
+		}
+		_, err = db.ExecContext(ctx, `
+			UPDATE paper_references SET arxiv_id = ?, confidence = ?, resolved_by = ?
+			WHERE paper_id = ? AND position = ?
+		`, id, confidence, by, r.paperID, r.position)
+		if err != nil {
+			return resolved, err
+		}
+		resolved++
+	}
+	return resolved, nil
+}
+
+// resolveStateKey records in sync_state when resolveChanged last ran.
+const resolveStateKey = "references_resolved"
+
+// resolveChanged resolves the unresolved references, other than those of
+// the papers in skip, that could match a paper added or updated since it
+// last ran, or all of them on its first run. A reference is only looked up
+// if it shares its DOI with such a paper, or enough title words to reach
+// MinTitleConfidence.
+func (c *Cache) resolveChanged(ctx context.Context, db dbtx, skip map[string]bool) (int, error) {
+	start := time.Now().Format(time.RFC3339)
+	var since string
+	err := db.QueryRowContext(ctx, "SELECT value FROM sync_state WHERE key = ?", resolveStateKey).Scan(&since)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	refs, err := unresolvedReferences(ctx, db, "")
+	if err != nil {
+		return 0, err
+	}
+	refs = slices.DeleteFunc(refs, func(r unresolvedRef) bool { return skip[r.paperID] })
+	if since != "" && len(refs) > 0 {
+		refs, err = mayResolve(ctx, db, refs, since)
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := resolveAll(ctx, db, refs)
+	if err != nil {
+		return n, err
+	}
+	_, err = db.ExecContext(ctx, "INSERT OR REPLACE INTO sync_state (key, value) VALUES (?, ?)", resolveStateKey, start)
+	return n, err
+}
+
+// mayResolve returns the refs that could resolve to a paper whose
+// metadata was stored at or after since.
+func mayResolve(ctx context.Context, db dbtx, refs []unresolvedRef, since string) ([]unresolvedRef, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT COALESCE(title, ''), COALESCE(doi, '') FROM papers
+		WHERE julianday(metadata_updated) >= julianday(?)
+	`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	dois := make(map[string]bool)
+	var sizes []int                    // Distinct title words of each paper
+	postings := make(map[string][]int) // Word to the papers with it in their title
+	for rows.Next() {
+		var title, doi string
+		if err := rows.Scan(&title, &doi); err != nil {
+			return nil, err
+		}
+		if doi != "" {
+			dois[strings.ToLower(doi)] = true
+		}
+		words := wordSet(titleWords(title))
+		for w := range words {
+			postings[w] = append(postings[w], len(sizes))
+		}
+		sizes = append(sizes, len(words))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var out []unresolvedRef
+	for _, r := range refs {
+		if r.DOI != "" && dois[strings.ToLower(r.DOI)] {
+			out = append(out, r)
+			continue
+		}
+		words := wordSet(titleWords(r.Title))
+		if len(words) < 3 {
+			continue
+		}
+		common := make(map[int]int)
+		for w := range words {
+			for _, i := range postings[w] {
+				common[i]++
+			}
+		}
+		for i, n := range common {
+			// The best score the title could reach, as resolveReference
+			// computes it
+			similarity := 2 * float64(n) / float64(len(words)+sizes[i])
+			if 0.6*similarity+0.2+0.1 >= MinTitleConfidence {
+				out = append(out, r)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// wordSet returns the distinct words of words.
+func wordSet(words []string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}
+
+// resolveReference finds the cached paper a reference cites, returning
+// "" if there is no confident match.
+func resolveReference(ctx context.Context, db dbtx, r *BibReference) (id string, confidence float64, by string, err error) {
+	if r.DOI != "" {
+		err := db.QueryRowContext(ctx,
+			"SELECT id FROM papers WHERE doi = ? COLLATE NOCASE LIMIT 1", r.DOI).Scan(&id)
+		if err != nil && err != sql.ErrNoRows {
+			return "", 0, "", err
+		}
+		if id != "" {
+			return id, ConfidenceDOI, "doi", nil
+		}
+	}
+
+	words := titleWords(r.Title)
+	if len(words) < 3 {
+		// Too short to tell apart from other titles
+		return "", 0, "", nil
+	}
+	// Any word may be missing or misspelled; the ranking puts the titles
+	// sharing the most words first
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = `"` + w + `"`
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT p.id, p.title, p.authors, p.created
+		FROM papers p
+		JOIN papers_fts fts ON p.rowid = fts.rowid
+		WHERE papers_fts MATCH ?
+		ORDER BY rank
+		LIMIT 10
+	`, "title : ("+strings.Join(quoted, " OR ")+")")
+	if err != nil {
+		return "", 0, "", err
+	}
+	defer rows.Close()
+
+	var best float64
+	for rows.Next() {
+		var candID, title, authors, created string
+		if err := rows.Scan(&candID, &title, &authors, &created); err != nil {
+			return "", 0, "", err
+		}
+		score := 0.6 * titleSimilarity(words, titleWords(title))
+		if sameFirstAuthor(r.Authors, authors) {
+			score += 0.2
+		}
+		if year := atoiPrefix(created); r.Year > 0 && year > 0 && r.Year >= year-1 && r.Year <= year+3 {
+			// Journal versions often appear a few years after the preprint
+			score += 0.1
+		}
+		if score > best {
+			id, best = candID, score
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", 0, "", err
+	}
+	if best < MinTitleConfidence {
+		return "", 0, "", nil
+	}
+	return id, best, "title", nil
+}
+
+// titleWords returns the lower-cased words of a title, split as the
+// full-text index splits them.
+func titleWords(title string) []string {
+	return strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// titleSimilarity returns the Dice coefficient of two titles' word sets.
+func titleSimilarity(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	set := make(map[string]bool, len(a))
+	for _, w := range a {
+		set[w] = true
+	}
+	common := 0
+	seen := make(map[string]bool, len(b))
+	for _, w := range b {
+		if set[w] && !seen[w] {
+			common++
+		}
+		seen[w] = true
+	}
+	return 2 * float64(common) / float64(len(set)+len(seen))
+}
+
+// sameFirstAuthor reports whether two comma-separated author lists start
+// with the same family name. Names differing by one letter match, since
+// accents are often lost in bibliographies (Muller for Müller).
+func sameFirstAuthor(a, b string) bool {
+	first := func(authors string) string {
+		name, _, _ := strings.Cut(authors, ",")
+		family, _ := splitName(name)
+		return keyWord(family)
+	}
+	fa, fb := first(a), first(b)
+	if fa == "" || fb == "" {
+		return false
+	}
+	if fa == fb {
+		return true
+	}
+	return len(fa) > 3 && len(fb) > 3 && editDistance(fa, fb) <= 1
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// atoiPrefix parses the leading digits of s, such as the year of a date.
+func atoiPrefix(s string) int {
+	n := 0
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			break
+		}
+		n = n*10 + int(r-'0')
+	}
+	return n
+}
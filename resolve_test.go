@@ -0,0 +1,181 @@
+package arxiv
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestResolveReference(t *testing.T) {
+	ctx := context.Background()
+	c, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	date := func(y, m, d int) time.Time { return time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC) }
+	err = c.insertPapers(ctx, []Paper{
+		{ID: "1706.03762", Title: "Attention Is All You Need", Authors: "Ashish Vaswani, Noam Shazeer", Created: date(2017, 6, 12)},
+		{ID: "1512.03385", Title: "Deep Residual Learning for Image Recognition", Authors: "Kaiming He, Xiangyu Zhang",
+			Created: date(2015, 12, 10), DOI: "10.1109/CVPR.2016.90"},
+		{ID: "1901.00001", Title: "Neural Networks for Graph Classification", Authors: "Jane Smith", Created: date(2019, 1, 1)},
+		{ID: "2101.00001", Title: "Neural Networks for Graph Classification", Authors: "Bob Jones", Created: date(2021, 1, 1)},
+		{ID: "2001.00001", Title: "Kernel Methods in Particle Physics", Authors: "Thomas Müller", Created: date(2020, 1, 1)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		ref        BibReference
+		wantID     string
+		confidence float64
+		by         string
+	}{
+		{
+			name:       "doi",
+			ref:        BibReference{Title: "Something else entirely", DOI: "10.1109/cvpr.2016.90"},
+			wantID:     "1512.03385",
+			confidence: ConfidenceDOI,
+			by:         "doi",
+		},
+		{
+			name:       "unknown doi falls back to the title",
+			ref:        BibReference{Title: "Attention is all you need", Authors: "A. Vaswani", Year: 2017, DOI: "10.1/x"},
+			wantID:     "1706.03762",
+			confidence: 0.9,
+			by:         "title",
+		},
+		{
+			name:       "title, author and year",
+			ref:        BibReference{Title: "Attention Is All You Need", Authors: "Vaswani, Ashish and others", Year: 2017},
+			wantID:     "1706.03762",
+			confidence: 0.9,
+			by:         "title",
+		},
+		{
+			name:       "title and author",
+			ref:        BibReference{Title: "Attention is all you need.", Authors: "Ashish Vaswani"},
+			wantID:     "1706.03762",
+			confidence: 0.8,
+			by:         "title",
+		},
+		{
+			name:       "title and journal year",
+			ref:        BibReference{Title: "Deep residual learning for image recognition", Year: 2016},
+			wantID:     "1512.03385",
+			confidence: 0.7,
+			by:         "title",
+		},
+		{
+			name: "title alone",
+			ref:  BibReference{Title: "Deep residual learning for image recognition"},
+		},
+		{
+			name: "year out of range",
+			ref:  BibReference{Title: "Deep residual learning for image recognition", Year: 2020},
+		},
+		{
+			name:       "one word off",
+			ref:        BibReference{Title: "Attention Is What You Need", Authors: "Vaswani", Year: 2017},
+			wantID:     "1706.03762",
+			confidence: 0.6*0.8 + 0.3,
+			by:         "title",
+		},
+		{
+			name: "too few words in common",
+			ref:  BibReference{Title: "Residual Learning Revisited", Authors: "Kaiming He", Year: 2015},
+		},
+		{
+			name:       "accent lost",
+			ref:        BibReference{Title: "Kernel methods in particle physics", Authors: "T. Muller", Year: 2020},
+			wantID:     "2001.00001",
+			confidence: 0.9,
+			by:         "title",
+		},
+		{
+			name: "different first author",
+			ref:  BibReference{Title: "Attention Is All You Need", Authors: "Noam Shazeer"},
+		},
+		{
+			name:       "ambiguous title, told apart by author",
+			ref:        BibReference{Title: "Neural networks for graph classification", Authors: "B. Jones"},
+			wantID:     "2101.00001",
+			confidence: 0.8,
+			by:         "title",
+		},
+		{
+			name:       "ambiguous title, told apart by year",
+			ref:        BibReference{Title: "Neural networks for graph classification", Authors: "Smith, J.", Year: 2018},
+			wantID:     "1901.00001",
+			confidence: 0.9,
+			by:         "title",
+		},
+		{
+			name: "ambiguous title alone",
+			ref:  BibReference{Title: "Neural networks for graph classification"},
+		},
+		{
+			name: "short title",
+			ref:  BibReference{Title: "Attention", Authors: "Vaswani", Year: 2017},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, confidence, by, err := resolveReference(ctx, c.db, &tt.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id != tt.wantID || math.Abs(confidence-tt.confidence) > 1e-9 || by != tt.by {
+				t.Errorf("resolveReference(%+v) = %q, %v, %q; want %q, %v, %q",
+					tt.ref, id, confidence, by, tt.wantID, tt.confidence, tt.by)
+			}
+			if id != "" && confidence < MinTitleConfidence {
+				t.Errorf("accepted a match with confidence %v", confidence)
+			}
+		})
+	}
+}
+
+func TestSameFirstAuthor(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"Ashish Vaswani, Noam Shazeer", "Vaswani, A.", true},
+		{"A. Vaswani", "Ashish Vaswani", true},
+		{"Thomas Müller", "T. Muller", true},
+		{"Jürgen Schmidhuber", "J. Schmidhuber", true},
+		{"Kaiming He", "Kaiming Ho", false}, // Too short to allow a typo
+		{"Noam Shazeer, Ashish Vaswani", "Ashish Vaswani", false},
+		{"", "Ashish Vaswani", false},
+	}
+	for _, tt := range tests {
+		if got := sameFirstAuthor(tt.a, tt.b); got != tt.want {
+			t.Errorf("sameFirstAuthor(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestTitleSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"Attention Is All You Need", "attention is all you need", 1},
+		{"Attention Is All You Need", "Attention Is What You Need", 0.8},
+		{"Deep Residual Learning", "Residual Learning, Deep", 1},
+		{"the the the", "the", 1},
+		{"Graph Networks", "Image Recognition", 0},
+		{"", "Anything", 0},
+	}
+	for _, tt := range tests {
+		got := titleSimilarity(titleWords(tt.a), titleWords(tt.b))
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("titleSimilarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
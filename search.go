@@ -3,18 +3,39 @@ package arxiv
 import (
 	"context"
 	"strings"
-	"time"
 )
 
 // Search searches papers by title/abstract text using FTS5.
 func (c *Cache) Search(ctx context.Context, query, category string, limit int) ([]Paper, error) {
+	return c.SearchWithOptions(ctx, query, &SearchOptions{Category: category, Limit: limit})
+}
+
+// SearchOptions configures SearchWithOptions.
+type SearchOptions struct {
+	// Category keeps only papers listed in this category
+	Category string
+
+	// Licenses keeps only papers whose license it allows (default: any)
+	Licenses LicenseFilter
+
+	// Limit is the maximum number of results (default 20)
+	Limit int
+}
+
+// SearchWithOptions searches papers by title/abstract text using FTS5,
+// keeping those that match opts.
+func (c *Cache) SearchWithOptions(ctx context.Context, query string, opts *SearchOptions) ([]Paper, error) {
+	if opts == nil {
+		opts = &SearchOptions{}
+	}
+	category, licenses, limit := opts.Category, opts.Licenses, opts.Limit
 	if limit <= 0 {
 		limit = 20
 	}
 
 	sql := `
 		SELECT p.id, p.created, p.updated, p.title, p.abstract, p.authors, p.categories,
-		       p.comments, p.journal_ref, p.doi, p.license, p.pdf_downloaded, p.src_downloaded
+		       p.comments, p.journal_ref, p.doi, p.license, COALESCE(p.primary_category, ''), p.pdf_downloaded, p.src_downloaded
 		FROM papers p
 		JOIN papers_fts fts ON p.rowid = fts.rowid
 		WHERE papers_fts MATCH ?
@@ -26,6 +47,15 @@ func (c *Cache) Search(ctx context.Context, query, category string, limit int) (
 		args = append(args, category)
 	}
 
+	if len(licenses) > 0 {
+		cond, licenseArgs, err := c.licenseMatch(ctx, "p.license", licenses)
+		if err != nil {
+			return nil, err
+		}
+		sql += " AND " + cond
+		args = append(args, licenseArgs...)
+	}
+
 	sql += " ORDER BY rank LIMIT ?"
 	args = append(args, limit)
 
@@ -37,25 +67,11 @@ func (c *Cache) Search(ctx context.Context, query, category string, limit int) (
 
 	var papers []Paper
 	for rows.Next() {
-		var p Paper
-		var created, updated string
-		var pdfDl, srcDl int
-
-		err := rows.Scan(
-			&p.ID, &created, &updated, &p.Title, &p.Abstract, &p.Authors,
-			&p.Categories, &p.Comments, &p.JournalRef, &p.DOI, &p.License,
-			&pdfDl, &srcDl,
-		)
+		p, err := scanPaperRow(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		p.Created, _ = time.Parse("2006-01-02", created)
-		p.Updated, _ = time.Parse("2006-01-02", updated)
-		p.PDFDownloaded = pdfDl == 1
-		p.SourceDownloaded = srcDl == 1
-
-		papers = append(papers, p)
+		papers = append(papers, *p)
 	}
 
 	return papers, rows.Err()
@@ -69,7 +85,7 @@ func (c *Cache) SearchByAuthor(ctx context.Context, author string, limit int) ([
 
 	sql := `
 		SELECT id, created, updated, title, abstract, authors, categories,
-		       comments, journal_ref, doi, license, pdf_downloaded, src_downloaded
+		       comments, journal_ref, doi, license, COALESCE(primary_category, ''), pdf_downloaded, src_downloaded
 		FROM papers
 		WHERE authors LIKE '%' || ? || '%'
 		ORDER BY created DESC
@@ -84,25 +100,11 @@ func (c *Cache) SearchByAuthor(ctx context.Context, author string, limit int) ([
 
 	var papers []Paper
 	for rows.Next() {
-		var p Paper
-		var created, updated string
-		var pdfDl, srcDl int
-
-		err := rows.Scan(
-			&p.ID, &created, &updated, &p.Title, &p.Abstract, &p.Authors,
-			&p.Categories, &p.Comments, &p.JournalRef, &p.DOI, &p.License,
-			&pdfDl, &srcDl,
-		)
+		p, err := scanPaperRow(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		p.Created, _ = time.Parse("2006-01-02", created)
-		p.Updated, _ = time.Parse("2006-01-02", updated)
-		p.PDFDownloaded = pdfDl == 1
-		p.SourceDownloaded = srcDl == 1
-
-		papers = append(papers, p)
+		papers = append(papers, *p)
 	}
 
 	return papers, rows.Err()
@@ -170,7 +172,7 @@ func (c *Cache) ListPapers(ctx context.Context, category string, offset, limit i
 
 	sql := `
 		SELECT id, created, updated, title, abstract, authors, categories,
-		       comments, journal_ref, doi, license, pdf_downloaded, src_downloaded
+		       comments, journal_ref, doi, license, COALESCE(primary_category, ''), pdf_downloaded, src_downloaded
 		FROM papers
 	`
 	var args []any
@@ -191,25 +193,11 @@ func (c *Cache) ListPapers(ctx context.Context, category string, offset, limit i
 
 	var papers []Paper
 	for rows.Next() {
-		var p Paper
-		var created, updated string
-		var pdfDl, srcDl int
-
-		err := rows.Scan(
-			&p.ID, &created, &updated, &p.Title, &p.Abstract, &p.Authors,
-			&p.Categories, &p.Comments, &p.JournalRef, &p.DOI, &p.License,
-			&pdfDl, &srcDl,
-		)
+		p, err := scanPaperRow(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		p.Created, _ = time.Parse("2006-01-02", created)
-		p.Updated, _ = time.Parse("2006-01-02", updated)
-		p.PDFDownloaded = pdfDl == 1
-		p.SourceDownloaded = srcDl == 1
-
-		papers = append(papers, p)
+		papers = append(papers, *p)
 	}
 
 	return papers, rows.Err()
@@ -217,9 +205,36 @@ func (c *Cache) ListPapers(ctx context.Context, category string, offset, limit i
 
 // ListPapersFiltered lists papers with various filter options.
 func (c *Cache) ListPapersFiltered(ctx context.Context, category string, srcOnly, all bool, limit int) ([]Paper, error) {
+	return c.ListPapersWithOptions(ctx, &ListOptions{Category: category, SourceOnly: srcOnly, All: all, Limit: limit})
+}
+
+// ListOptions configures ListPapersWithOptions.
+type ListOptions struct {
+	// Category keeps only papers listed in this category
+	Category string
+
+	// Licenses keeps only papers whose license it allows (default: any)
+	Licenses LicenseFilter
+
+	// SourceOnly keeps only papers whose source has been downloaded
+	SourceOnly bool
+
+	// All includes metadata-only papers without a title
+	All bool
+
+	// Limit is the maximum number of results (default: no limit)
+	Limit int
+}
+
+// ListPapersWithOptions lists the papers matching opts, newest id first.
+func (c *Cache) ListPapersWithOptions(ctx context.Context, opts *ListOptions) ([]Paper, error) {
+	if opts == nil {
+		opts = &ListOptions{}
+	}
+	category, licenses, srcOnly, all, limit := opts.Category, opts.Licenses, opts.SourceOnly, opts.All, opts.Limit
 	sql := `
 		SELECT id, created, updated, title, abstract, authors, categories,
-		       comments, journal_ref, doi, license, pdf_downloaded, src_downloaded
+		       comments, journal_ref, doi, license, COALESCE(primary_category, ''), pdf_downloaded, src_downloaded
 		FROM papers
 		WHERE 1=1
 	`
@@ -230,6 +245,15 @@ func (c *Cache) ListPapersFiltered(ctx context.Context, category string, srcOnly
 		args = append(args, category)
 	}
 
+	if len(licenses) > 0 {
+		cond, licenseArgs, err := c.licenseMatch(ctx, "license", licenses)
+		if err != nil {
+			return nil, err
+		}
+		sql += " AND " + cond
+		args = append(args, licenseArgs...)
+	}
+
 	if srcOnly {
 		sql += " AND src_downloaded = 1"
 	} else if !all {
@@ -252,25 +276,11 @@ func (c *Cache) ListPapersFiltered(ctx context.Context, category string, srcOnly
 
 	var papers []Paper
 	for rows.Next() {
-		var p Paper
-		var created, updated string
-		var pdfDl, srcDl int
-
-		err := rows.Scan(
-			&p.ID, &created, &updated, &p.Title, &p.Abstract, &p.Authors,
-			&p.Categories, &p.Comments, &p.JournalRef, &p.DOI, &p.License,
-			&pdfDl, &srcDl,
-		)
+		p, err := scanPaperRow(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		p.Created, _ = time.Parse("2006-01-02", created)
-		p.Updated, _ = time.Parse("2006-01-02", updated)
-		p.PDFDownloaded = pdfDl == 1
-		p.SourceDownloaded = srcDl == 1
-
-		papers = append(papers, p)
+		papers = append(papers, *p)
 	}
 
 	return papers, rows.Err()
@@ -333,13 +343,11 @@ func (c *Cache) DownloadCategory(ctx context.Context, category string, limit int
 			opts.Progress(id, i+1, len(ids))
 		}
 
+		// Requests are paced by the cache's rate limiter
 		if err := c.DownloadPaper(ctx, id, opts); err != nil {
 			// Log and continue
 			continue
 		}
-
-		// Rate limit
-		time.Sleep(3 * time.Second)
 	}
 
 	return nil
@@ -0,0 +1,104 @@
+package arxiv
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// upsertPaperSQL inserts or updates a paper's metadata. Unlike INSERT OR
+// REPLACE it leaves download state alone, and it keeps the stored license,
+// primary category and version when the source doesn't report them (the
+// API has no license, OAI has no version).
+const upsertPaperSQL = `
+	INSERT INTO papers
+	(id, created, updated, title, abstract, authors, categories, comments, journal_ref, doi, license,
+	 primary_category, version, metadata_updated)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(id) DO UPDATE SET
+		created = excluded.created,
+		updated = excluded.updated,
+		title = excluded.title,
+		abstract = excluded.abstract,
+		authors = excluded.authors,
+		categories = excluded.categories,
+		comments = excluded.comments,
+		journal_ref = excluded.journal_ref,
+		doi = excluded.doi,
+		license = COALESCE(NULLIF(excluded.license, ''), papers.license),
+		primary_category = COALESCE(NULLIF(excluded.primary_category, ''), papers.primary_category),
+		version = CASE WHEN excluded.version > 0 THEN excluded.version ELSE papers.version END,
+		metadata_updated = excluded.metadata_updated
+`
+
+// paperArgs returns the arguments for upsertPaperSQL.
+func paperArgs(p *Paper, now string) []any {
+	return []any{
+		p.ID,
+		p.Created.Format("2006-01-02"),
+		p.Updated.Format("2006-01-02"),
+		p.Title,
+		p.Abstract,
+		p.Authors,
+		p.Categories,
+		p.Comments,
+		p.JournalRef,
+		p.DOI,
+		p.License,
+		p.Primary,
+		p.Version,
+		now,
+	}
+}
+
+// storeAuthors replaces the paper's rows in paper_authors. Rows are only
+// written when at least one affiliation is known; the authors column
+// already holds the names.
+func storeAuthors(ctx context.Context, tx *sql.Tx, p *Paper) error {
+	hasAffiliation := false
+	for _, a := range p.AuthorList {
+		if len(a.Affiliations) > 0 {
+			hasAffiliation = true
+			break
+		}
+	}
+	if !hasAffiliation {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM paper_authors WHERE paper_id = ?", p.ID); err != nil {
+		return err
+	}
+	for i, a := range p.AuthorList {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO paper_authors (paper_id, position, name, affiliation) VALUES (?, ?, ?, ?)",
+			p.ID, i, a.Name, strings.Join(a.Affiliations, "; "))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadAuthors fills p.AuthorList from paper_authors.
+func (c *Cache) loadAuthors(ctx context.Context, p *Paper) error {
+	rows, err := c.db.QueryContext(ctx,
+		"SELECT name, affiliation FROM paper_authors WHERE paper_id = ? ORDER BY position", p.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a Author
+		var aff sql.NullString
+		if err := rows.Scan(&a.Name, &aff); err != nil {
+			return err
+		}
+		if aff.String != "" {
+			a.Affiliations = strings.Split(aff.String, "; ")
+		}
+		p.AuthorList = append(p.AuthorList, a)
+	}
+	return rows.Err()
+}
@@ -2,6 +2,7 @@ package arxiv
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -34,7 +35,7 @@ func (c *Cache) SyncMetadata(ctx context.Context, opts *SyncOptions) error {
 		opts.BatchSize = 1000
 	}
 
-	client := NewOAIClient()
+	client := c.oaiClient()
 
 	// Check for existing resumption token
 	var resumptionToken string
@@ -61,6 +62,10 @@ func (c *Cache) SyncMetadata(ctx context.Context, opts *SyncOptions) error {
 		}
 
 		resp, err := client.ListRecords(ctx, opts.Set, opts.From, opts.Until, resumptionToken)
+		if errors.Is(err, ErrNoRecords) {
+			// Nothing changed since the last sync
+			break
+		}
 		if err != nil {
 			// Save state for resume on rate limit
 			if resumptionToken != "" {
@@ -88,9 +93,6 @@ func (c *Cache) SyncMetadata(ctx context.Context, opts *SyncOptions) error {
 		if resp.ResumptionToken != "" {
 			c.saveResumptionToken(ctx, resp.ResumptionToken)
 			resumptionToken = resp.ResumptionToken
-
-			// arXiv rate limit: wait between requests
-			time.Sleep(3 * time.Second)
 		} else {
 			// No more records
 			break
@@ -117,6 +119,8 @@ func (c *Cache) saveResumptionToken(ctx context.Context, token string) {
 	c.db.ExecContext(ctx, "INSERT OR REPLACE INTO sync_state (key, value) VALUES ('resumption_token', ?)", token)
 }
 
+// insertPapers stores a batch of papers and then evaluates saved watches
+// against the papers that were not in the cache before.
 func (c *Cache) insertPapers(ctx context.Context, papers []Paper) error {
 	tx, err := c.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -124,36 +128,40 @@ func (c *Cache) insertPapers(ctx context.Context, papers []Paper) error {
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.PrepareContext(ctx, `
-		INSERT OR REPLACE INTO papers
-		(id, created, updated, title, abstract, authors, categories, comments, journal_ref, doi, license, metadata_updated)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`)
+	stmt, err := tx.PrepareContext(ctx, upsertPaperSQL)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
 	now := time.Now().Format(time.RFC3339)
-	for _, p := range papers {
-		_, err := stmt.ExecContext(ctx,
-			p.ID,
-			p.Created.Format("2006-01-02"),
-			p.Updated.Format("2006-01-02"),
-			p.Title,
-			p.Abstract,
-			p.Authors,
-			p.Categories,
-			p.Comments,
-			p.JournalRef,
-			p.DOI,
-			p.License,
-			now,
-		)
+	var added []string
+	for i := range papers {
+		p := &papers[i]
+		existed, err := recordChanges(ctx, tx, p, now)
 		if err != nil {
 			return err
 		}
+		if !existed {
+			added = append(added, p.ID)
+		}
+		if _, err := stmt.ExecContext(ctx, paperArgs(p, now)...); err != nil {
+			return err
+		}
+		if err := storeAuthors(ctx, tx, p); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
 	}
 
-	return tx.Commit()
+	if err := c.evaluateWatches(ctx, added); err != nil {
+		log.Printf("evaluate watches: %v", err)
+	}
+	if err := c.applyPolicies(ctx, added); err != nil {
+		log.Printf("apply download policies: %v", err)
+	}
+	return nil
 }
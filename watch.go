@@ -0,0 +1,247 @@
+package arxiv
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Watch is a saved search. Papers that match it when they are first added
+// to the cache by SyncMetadata or NewSubmissions are recorded as alerts.
+type Watch struct {
+	ID int64
+
+	// Query is an FTS5 query over titles and abstracts (empty matches all)
+	Query string
+
+	// Categories restricts matches to papers in any of these categories or
+	// archives
+	Categories []string
+
+	// Authors restricts matches to papers by any of these authors
+	// (substring match)
+	Authors []string
+
+	// Enqueue adds the source of every matching paper to the download queue
+	Enqueue bool
+
+	Created time.Time
+
+	// Unread is the number of unread alerts for this watch
+	Unread int
+}
+
+// Alert is a paper that matched a watch.
+type Alert struct {
+	WatchID int64
+	Query   string
+	Paper   Paper
+	Created time.Time
+	Read    bool
+}
+
+// AddWatch saves a watch and returns its ID.
+func (c *Cache) AddWatch(ctx context.Context, w *Watch) (int64, error) {
+	if w.Query != "" {
+		// Reject FTS5 syntax errors now rather than at every sync
+		var n int
+		err := c.db.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM (SELECT 1 FROM papers_fts WHERE papers_fts MATCH ? LIMIT 1)", w.Query).Scan(&n)
+		if err != nil {
+			return 0, fmt.Errorf("invalid query %q: %w", w.Query, err)
+		}
+	}
+	res, err := c.db.ExecContext(ctx, `
+		INSERT INTO watches (query, categories, authors, enqueue, created)
+		VALUES (?, ?, ?, ?, ?)
+	`, w.Query, strings.Join(w.Categories, " "), strings.Join(w.Authors, "\n"), w.Enqueue,
+		time.Now().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// RemoveWatch deletes a watch and its alerts.
+func (c *Cache) RemoveWatch(ctx context.Context, id int64) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM watches WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM alerts WHERE watch_id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Watches returns all saved watches with their unread alert counts.
+func (c *Cache) Watches(ctx context.Context) ([]Watch, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT w.id, w.query, w.categories, w.authors, w.enqueue, w.created,
+		       (SELECT COUNT(*) FROM alerts a WHERE a.watch_id = w.id AND a.read = 0)
+		FROM watches w
+		ORDER BY w.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var watches []Watch
+	for rows.Next() {
+		var w Watch
+		var cats, authors, created string
+		if err := rows.Scan(&w.ID, &w.Query, &cats, &authors, &w.Enqueue, &created, &w.Unread); err != nil {
+			return nil, err
+		}
+		w.Categories = strings.Fields(cats)
+		if authors != "" {
+			w.Authors = strings.Split(authors, "\n")
+		}
+		w.Created, _ = time.Parse(time.RFC3339, created)
+		watches = append(watches, w)
+	}
+	return watches, rows.Err()
+}
+
+// Alerts returns alerts, newest first. If unreadOnly is set, alerts that
+// have been marked read are skipped.
+func (c *Cache) Alerts(ctx context.Context, unreadOnly bool) ([]Alert, error) {
+	query := `
+		SELECT a.watch_id, w.query, a.created, a.read,
+		       p.id, p.created, p.updated, p.title, p.abstract, p.authors, p.categories,
+		       p.comments, p.journal_ref, p.doi, p.license, COALESCE(p.primary_category, ''), p.pdf_downloaded, p.src_downloaded
+		FROM alerts a
+		JOIN watches w ON w.id = a.watch_id
+		JOIN papers p ON p.id = a.paper_id
+	`
+	if unreadOnly {
+		query += " WHERE a.read = 0"
+	}
+	query += " ORDER BY a.created DESC, p.id DESC"
+
+	rows, err := c.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []Alert
+	for rows.Next() {
+		var a Alert
+		var alertCreated string
+		p, err := scanPaperRow(rows, &a.WatchID, &a.Query, &alertCreated, &a.Read)
+		if err != nil {
+			return nil, err
+		}
+		a.Paper = *p
+		a.Created, _ = time.Parse(time.RFC3339, alertCreated)
+
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+// MarkAlertsRead marks all alerts as read.
+func (c *Cache) MarkAlertsRead(ctx context.Context) error {
+	_, err := c.db.ExecContext(ctx, "UPDATE alerts SET read = 1 WHERE read = 0")
+	return err
+}
+
+// evaluateWatches records alerts for the papers in ids that match a watch.
+// It is called with the IDs of papers that were just added to the cache, so
+// each paper alerts at most once per watch.
+func (c *Cache) evaluateWatches(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	watches, err := c.Watches(ctx)
+	if err != nil || len(watches) == 0 {
+		return err
+	}
+
+	idList, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Format(time.RFC3339)
+
+	var errs []error
+	for _, w := range watches {
+		query := `
+			SELECT p.id FROM papers p
+			WHERE p.id IN (SELECT value FROM json_each(?))
+		`
+		args := []any{string(idList)}
+		if w.Query != "" {
+			query += " AND p.rowid IN (SELECT rowid FROM papers_fts WHERE papers_fts MATCH ?)"
+			args = append(args, w.Query)
+		}
+		if len(w.Categories) > 0 {
+			cond, catArgs := categoryMatch("p.categories", w.Categories)
+			query += " AND " + cond
+			args = append(args, catArgs...)
+		}
+		if len(w.Authors) > 0 {
+			var conds []string
+			for _, author := range w.Authors {
+				conds = append(conds, "p.authors LIKE '%' || ? || '%'")
+				args = append(args, author)
+			}
+			query += " AND (" + strings.Join(conds, " OR ") + ")"
+		}
+
+		matches, err := c.queryIDs(ctx, query, args...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("watch %d: %w", w.ID, err))
+			continue
+		}
+
+		for _, id := range matches {
+			_, err := c.db.ExecContext(ctx, `
+				INSERT OR IGNORE INTO alerts (watch_id, paper_id, created) VALUES (?, ?, ?)
+			`, w.ID, id, now)
+			if err != nil {
+				return err
+			}
+			if w.Enqueue {
+				if err := c.Enqueue(ctx, id, QueueSource); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// queryIDs runs a query returning a single column of paper IDs.
+func (c *Cache) queryIDs(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
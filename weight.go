@@ -0,0 +1,45 @@
+package arxiv
+
+import "strings"
+
+// InfluentialWeight is the edge weight from which a citation is considered
+// influential: cited twice in the method or experiments, or three times
+// elsewhere in the body.
+const InfluentialWeight = 3
+
+// sectionWeights scale a mention by the section it is in, matched by
+// keywords in the section heading. Citations in the method and
+// experiments usually build on a paper; those in related work and
+// background usually only acknowledge it.
+var sectionWeights = []struct {
+	keywords []string
+	weight   float64
+}{
+	{[]string{"related", "background", "prior work", "previous work", "literature"}, 0.5},
+	{[]string{"method", "approach", "model", "architecture", "algorithm", "framework",
+		"experiment", "evaluation", "result", "implementation", "training", "setup", "analysis"}, 2},
+}
+
+// sectionWeight returns the weight of one mention in the given section.
+func sectionWeight(section string) float64 {
+	section = strings.ToLower(section)
+	for _, sw := range sectionWeights {
+		for _, kw := range sw.keywords {
+			if strings.Contains(section, kw) {
+				return sw.weight
+			}
+		}
+	}
+	return 1
+}
+
+// referenceWeights returns the weight of each cited key: the sum of its
+// mentions' section weights. References never cited in the text, such as
+// those known only from a PDF, keep the default weight of 1.
+func referenceWeights(contexts []CitationContext) map[string]float64 {
+	weights := make(map[string]float64)
+	for _, cc := range contexts {
+		weights[cc.Key] += sectionWeight(cc.Section)
+	}
+	return weights
+}
@@ -0,0 +1,112 @@
+package arxiv
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestSectionWeight(t *testing.T) {
+	tests := []struct {
+		section string
+		want    float64
+	}{
+		{"Related Work", 0.5},
+		{"Background and Prior Work", 0.5},
+		{"Our Method", 2},
+		{"EXPERIMENTS", 2},
+		{"Model Architecture", 2},
+		{"Introduction", 1},
+		{"Abstract", 1},
+		{"", 1},
+		{"Related Methods", 0.5}, // The first matching group wins
+	}
+	for _, tt := range tests {
+		if got := sectionWeight(tt.section); got != tt.want {
+			t.Errorf("sectionWeight(%q) = %v, want %v", tt.section, got, tt.want)
+		}
+	}
+}
+
+func TestReferenceWeights(t *testing.T) {
+	ctx := context.Background()
+	c, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	mention := func(key, section string) CitationContext {
+		return CitationContext{Key: key, Section: section, Sentence: "[" + key + "]"}
+	}
+	refs := []BibReference{
+		{Position: 1, Key: "method", ArxivID: "2001.00001"},
+		{Position: 2, Key: "related", ArxivID: "2001.00002"},
+		{Position: 3, Key: "intro", ArxivID: "2001.00003"},
+		{Position: 4, Key: "uncited", ArxivID: "2001.00004"},
+		{Position: 5, Key: "", ArxivID: "2001.00005"},
+		// The same paper under two keys
+		{Position: 6, Key: "dup1", ArxivID: "2001.00006"},
+		{Position: 7, Key: "dup2", ArxivID: "2001.00006"},
+	}
+	contexts := []CitationContext{
+		mention("method", "Method"),
+		mention("method", "Experiments"),
+		mention("related", "Related Work"),
+		mention("related", "Related Work"),
+		mention("related", "Related Work"),
+		mention("intro", "Introduction"),
+		mention("intro", "Introduction"),
+		mention("intro", "Conclusion"),
+		mention("dup1", "Introduction"),
+		mention("dup2", "Results"),
+		mention("", "Method"),
+	}
+	if err := c.storeReferences(ctx, "2301.00001", refs, contexts); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id          string
+		mentions    int
+		weight      float64
+		influential bool
+	}{
+		{"2001.00001", 2, 4, true},
+		{"2001.00002", 3, 1.5, false},
+		{"2001.00003", 3, 3, true},
+		{"2001.00004", 0, 1, false},
+		{"2001.00005", 0, 1, false},
+		{"2001.00006", 2, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			var mentions int
+			var weight float64
+			var influential bool
+			err := c.db.QueryRow("SELECT mentions, weight, influential FROM citations WHERE from_id = '2301.00001' AND to_id = ?", tt.id).
+				Scan(&mentions, &weight, &influential)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mentions != tt.mentions || weight != tt.weight || influential != tt.influential {
+				t.Errorf("citation = %d mentions, weight %v, influential %v; want %d, %v, %v",
+					mentions, weight, influential, tt.mentions, tt.weight, tt.influential)
+			}
+		})
+	}
+
+	// References are ordered by weight
+	got, err := c.References(ctx, "2301.00001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, r := range got {
+		ids = append(ids, r.ID)
+	}
+	want := []string{"2001.00001", "2001.00006", "2001.00003", "2001.00002", "2001.00005", "2001.00004"}
+	if !slices.Equal(ids, want) {
+		t.Errorf("References = %v, want %v", ids, want)
+	}
+}